@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/Golem-Base/op-probe/internal"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+var CallCommand = &cli.Command{
+	Name:  "call",
+	Usage: "Reads contract state with eth_call, ABI encoding the arguments and decoding the returned values",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "rpc-url",
+			Usage:    "Url for execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "Address of the contract to call",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "sig",
+			Usage:    "Method to call as name(types), with the return types to decode as name(types)(types) for methods the OP Stack contract bindings do not know, or as a bare name such as respectedGameType",
+			Required: true,
+		},
+		&cli.StringSliceFlag{
+			Name:  "args",
+			Usage: "Arguments of the method in order: addresses and bytes as hex, integers in decimal or 0x prefixed hex",
+		},
+		&cli.Uint64Flag{
+			Name:  "block",
+			Usage: "Block number to read the state at (default: latest block)",
+		},
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "Address the call is made from, for methods depending on msg.sender",
+		},
+	},
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := context.Background()
+
+		method, err := internal.ParseCallSignature(c.String("sig"))
+		if err != nil {
+			return err
+		}
+		data, err := internal.PackCall(method, c.StringSlice("args"))
+		if err != nil {
+			return err
+		}
+
+		to, err := internal.SafeParseAddress(c.String("to"))
+		if err != nil {
+			return fmt.Errorf("invalid to address: %w", err)
+		}
+		var from common.Address
+		if c.IsSet("from") {
+			from, err = internal.SafeParseAddress(c.String("from"))
+			if err != nil {
+				return fmt.Errorf("invalid from address: %w", err)
+			}
+		}
+		var blockNumber *big.Int
+		if c.IsSet("block") {
+			blockNumber = new(big.Int).SetUint64(c.Uint64("block"))
+		}
+
+		rpcUrl := c.String("rpc-url")
+		client, _, err := internal.ConnectClient(ctx, c, rpcUrl, "chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", rpcUrl, err)
+		}
+
+		result := &callResult{To: to, Method: method.Sig, Block: blockNumber}
+		output.Result = result
+
+		log.Info("calling contract", "to", to, "method", method.Sig, "block", blockNumber)
+
+		readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+		defer cancel()
+
+		returnData, err := client.CallContract(readCtx, ethereum.CallMsg{From: from, To: &to, Data: data}, blockNumber)
+		if err != nil {
+			return fmt.Errorf("call to %s failed: %w", method.Sig, err)
+		}
+		result.ReturnData = returnData
+
+		// Methods called without known return types only have their raw return data printed
+		if len(method.Outputs) == 0 {
+			fmt.Println(hexutil.Encode(returnData))
+			return nil
+		}
+
+		values, err := method.Outputs.Unpack(returnData)
+		if err != nil {
+			return fmt.Errorf("could not decode the return data of %s: %w", method.Sig, err)
+		}
+		for _, value := range values {
+			formatted := internal.FormatCallValue(value)
+			result.Values = append(result.Values, formatted)
+			fmt.Println(formatted)
+		}
+
+		return nil
+	}),
+}
+
+type callResult struct {
+	To         common.Address `json:"to"`
+	Method     string         `json:"method"`
+	Block      *big.Int       `json:"block,omitempty"`
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	Values     []string       `json:"values,omitempty"`
+}
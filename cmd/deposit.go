@@ -7,13 +7,10 @@ import (
 
 	"github.com/Golem-Base/op-probe/internal"
 
-	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/receipts"
-	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/transactions"
-	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/wait"
-	"github.com/ethereum-optimism/optimism/op-node/rollup/derive"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/urfave/cli/v2"
 )
@@ -25,29 +22,30 @@ var DepositCommand = &cli.Command{
 	// Example flags
 	Flags: []cli.Flag{
 		&cli.StringFlag{
-			Name:     "private-key",
-			Usage:    "Private key of address to send test transaction from",
-			Required: true,
+			Name:  "private-key",
+			Usage: "Private key of address to send test transaction from, or - to read it from stdin, required unless --impersonate is set",
+		},
+		&cli.StringFlag{
+			Name:  "impersonate",
+			Usage: "Address to send from without its private key, on dev nodes supporting account impersonation (anvil, hardhat) or holding it unlocked",
 		},
 		&cli.StringFlag{
 			Name:     "l1-rpc-url",
-			Usage:    "Url for L1 execution client",
+			Usage:    "Url for L1 execution client, or comma separated urls to fail over between",
 			Required: true,
 		},
 		&cli.StringFlag{
 			Name:     "l2-rpc-url",
-			Usage:    "Url for L2 execution client",
+			Usage:    "Url for L2 execution client, or comma separated urls to fail over between",
 			Required: true,
 		},
 		&cli.StringFlag{
-			Name:     "optimism-portal-address",
-			Usage:    "Contract address for the OptimismPortal (* or proxy)",
-			Required: true,
+			Name:  "optimism-portal-address",
+			Usage: "Contract address for the OptimismPortal (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
 		},
 		&cli.StringFlag{
-			Name:     "l1-standard-bridge-address",
-			Usage:    "Contract address for the L1StandardBridge (* or proxy)",
-			Required: true,
+			Name:  "l1-standard-bridge-address",
+			Usage: "Contract address for the L1StandardBridge (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
 		},
 		&cli.StringFlag{
 			Name:     "amount",
@@ -59,8 +57,42 @@ var DepositCommand = &cli.Command{
 			Usage:    "Address to receive amount",
 			Required: true,
 		},
+		&cli.Uint64Flag{
+			Name:  "l2-gas-limit",
+			Usage: "Minimum gas limit for executing the deposit on L2",
+			Value: uint64(internal.RECEIVE_DEFAULT_GAS_LIMIT),
+		},
+		&cli.BoolFlag{
+			Name:  "l1-only",
+			Usage: "Succeed once the L1 deposit is mined, logging the derived L2 transaction hash without waiting for it",
+		},
+		&cli.Uint64Flag{
+			Name:  "nonce",
+			Usage: "Nonce of the first transaction sent (default: pending nonce of the sender)",
+		},
+		&cli.StringFlag{
+			Name:  "tx-type",
+			Usage: "Force the transaction type, legacy or dynamic, for nodes rejecting the other (default: dynamic once the chain has a base fee)",
+		},
+		&cli.StringFlag{
+			Name:  "gas-price",
+			Usage: "Gas price of legacy transactions in wei (default: suggested by the node)",
+		},
+		&cli.StringFlag{
+			Name:  "max-fee-per-gas",
+			Usage: "Fee cap of dynamic fee transactions in wei (default: twice the base fee plus the tip)",
+		},
+		&cli.StringFlag{
+			Name:  "max-priority-fee-per-gas",
+			Usage: "Tip of dynamic fee transactions in wei (default: suggested by the node)",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "Format of the deposit result printed to stdout, text logs only the balance differentials and json prints the full result",
+			Value: "text",
+		},
 	},
-	Action: func(c *cli.Context) error {
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
 		ctx := context.Background()
 
 		amount, err := internal.ParseUint256BigInt(c.String("amount"))
@@ -68,118 +100,234 @@ var DepositCommand = &cli.Command{
 			return err
 		}
 
-		privateKey, err := crypto.HexToECDSA(c.String("private-key"))
+		l2GasLimit, err := internal.SafeParseUint32("l2-gas-limit", c.Uint64("l2-gas-limit"))
+		if err != nil {
+			return err
+		}
+		if l2GasLimit == 0 {
+			return fmt.Errorf("l2-gas-limit must be greater than 0")
+		}
+
+		outputFormat := c.String("output")
+		if outputFormat != "text" && outputFormat != "json" {
+			return fmt.Errorf("unknown output %q, expected text or json", outputFormat)
+		}
+
+		sender, privateKey, err := internal.SenderAccount(c)
 		if err != nil {
-			return fmt.Errorf("failed to parse private-key: %w", err)
+			return err
 		}
 
 		l1RpcUrl := c.String("l1-rpc-url")
-		l1Client, l1ChainId, err := internal.ConnectClient(ctx, l1RpcUrl)
+		l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
 		if err != nil {
 			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
 		}
 
 		l2RpcUrl := c.String("l2-rpc-url")
-		l2Client, _, err := internal.ConnectClient(ctx, l2RpcUrl)
+		l2Client, l2ChainId, err := internal.ConnectClient(ctx, c, l2RpcUrl, "l2-chain-id")
 		if err != nil {
 			return fmt.Errorf("could not connect to client at %s: %w", l2RpcUrl, err)
 		}
 
-		sender := crypto.PubkeyToAddress(privateKey.PublicKey)
+		if err := internal.ValidateChainIds(c, l1ChainId, l2ChainId); err != nil {
+			return err
+		}
 
 		recipient, err := internal.SafeParseAddress(c.String("recipient"))
 		if err != nil {
 			return fmt.Errorf("could not parse recipient address: %w", err)
 		}
 
-		senderPreBalance, err := l1Client.BalanceAt(ctx, sender, nil)
-		recipientPreBalance, err := l2Client.BalanceAt(ctx, recipient, nil)
+		result := &depositResult{Sender: sender, Recipient: recipient, Amount: amount}
+		output.Result = result
+		output.SetAccount(sender)
+		output.Amount = amount
+
+		optimismPortalAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "optimism-portal-address")
+		if err != nil {
+			return fmt.Errorf("could not resolve OptimismPortal address: %w", err)
+		}
+		l1StandardBridgeAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "l1-standard-bridge-address")
+		if err != nil {
+			return fmt.Errorf("could not resolve L1StandardBridge address: %w", err)
+		}
 
 		contracts, err := internal.NewDepositContracts(
 			ctx,
 			l1Client,
 			l2Client,
-			c.String("optimism-portal-address"),
-			c.String("l1-standard-bridge-address"),
+			optimismPortalAddress,
+			l1StandardBridgeAddress,
 		)
 		if err != nil {
 			return fmt.Errorf("could not instantiate deposit contracts: %w", err)
 		}
 
-		opts, err := bind.NewKeyedTransactorWithChainID(privateKey, l1ChainId)
+		var opts *bind.TransactOpts
+		if privateKey == nil {
+			opts, err = internal.NewImpersonatingTransactor(ctx, c, l1Client, sender)
+		} else {
+			opts, err = internal.NewTransactor(ctx, c, l1Client, privateKey, l1ChainId)
+		}
 		if err != nil {
-			return fmt.Errorf("could not setup transactor: %w", err)
+			return err
 		}
-		opts.Value = amount
 
-		log.Info("executing l1StandardBridge.bridgeETH transaction")
+		if err := depositETH(ctx, l1Client, l2Client, contracts, opts, l2GasLimit, c.Bool("l1-only"), result); err != nil {
+			return err
+		}
+		output.AddTx(result.L1TxHash)
+		output.AddTx(result.L2TxHash)
 
-		tx, err := transactions.PadGasEstimate(opts, 1.5, func(opts *bind.TransactOpts) (*types.Transaction, error) {
-			return contracts.L1StandardBridge.DepositETHTo(opts, recipient, internal.RECEIVE_DEFAULT_GAS_LIMIT, []byte{})
-		})
-		if err != nil {
-			return fmt.Errorf("could not construct calldata for DepositETH: %w", err)
+		if outputFormat == "json" {
+			return internal.PrintJSON(result)
 		}
+		output.Primary = result.L1TxHash
 
-		log.Info("sent transaction, waiting for confirmation", "tx", tx.Hash().Hex())
+		return nil
+	}),
+}
 
-		receipt, err := wait.ForReceiptOK(ctx, l1Client, tx.Hash())
-		if err != nil {
-			if statusErr, ok := err.(*wait.ReceiptStatusError); ok {
-				log.Error("bridgeETH transaction trace", "tx", tx.Hash().Hex(), "trace", statusErr.TxTrace)
-				return fmt.Errorf("failure in bridge transaction execution: %w", err)
-			} else {
-				return fmt.Errorf("failed to get bridge transaction receipt: %w", err)
-			}
-		}
+// depositETH bridges result.Amount from result.Sender on L1 to result.Recipient on L2 through the L1StandardBridge and
+// waits for the deposit on L2, recording the transactions and balance differentials on result. With l1Only the L2
+// deposit transaction is only derived, not waited for.
+func depositETH(ctx context.Context, l1Client, l2Client *ethclient.Client, contracts *internal.DepositContracts, opts *bind.TransactOpts, l2GasLimit uint32, l1Only bool, result *depositResult) error {
+	sender, recipient, amount := result.Sender, result.Recipient, result.Amount
 
-		log.Info("transaction has been mined successfully", "receipt", receipt)
+	balanceCtx, cancelBalance := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancelBalance()
 
-		transactionDepositedEvent, err := receipts.FindLog(receipt.Logs, contracts.OptimismPortal.ParseTransactionDeposited)
-		if err != nil {
-			return fmt.Errorf("could not parse OptimismPortal.TransactionDeposited event from the receipt logs: %w", err)
-		}
+	senderPreBalance, err := l1Client.BalanceAt(balanceCtx, sender, nil)
+	if err != nil {
+		return fmt.Errorf("could not fetch L1 balance of sender %s: %w", sender.Hex(), err)
+	}
+	recipientPreBalance, err := l2Client.BalanceAt(balanceCtx, recipient, nil)
+	if err != nil {
+		return fmt.Errorf("could not fetch L2 balance of recipient %s: %w", recipient.Hex(), err)
+	}
+	result.SenderPreBalance = senderPreBalance
+	result.RecipientPreBalance = recipientPreBalance
 
-		log.Info("found TransactionDeposited event in receiptLog", "event", transactionDepositedEvent.Raw)
+	opts.Value = amount
 
-		// The L2 special deposit transaction can be dervied from the TransactionDeposited logs
-		depositTx, err := derive.UnmarshalDepositLogEvent(&transactionDepositedEvent.Raw)
-		if err != nil {
-			return fmt.Errorf("encountered error deriving the deposit transaction type from the OptimismPortal.TransactionDeposited event: %w", err)
-		}
+	log.Info("executing l1StandardBridge.bridgeETH transaction")
 
-		log.Info("successfully derived the L2 deposit transaction", "depositTx", depositTx)
+	result.Timings = internal.NewDepositTimings()
+	receipt, err := internal.SendTransaction(ctx, l1Client, opts, "L1StandardBridge.DepositETHTo", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return contracts.L1StandardBridge.DepositETHTo(opts, recipient, l2GasLimit, []byte{})
+	})
+	if err != nil {
+		return err
+	}
 
-		depositTxHash := types.NewTx(depositTx).Hash()
+	result.L1TxHash = receipt.TxHash
+	result.L1BlockNumber = receipt.BlockNumber.Uint64()
+	result.L1Receipt = receipt
+	result.L1Gas = internal.NewGasReport(receipt)
+	result.Timings.L1Mined()
 
-		log.Info("waiting for deposit transaction reciept on L2", "tx", depositTxHash)
+	log.Info("transaction has been mined successfully", "receipt", receipt)
 
-		receipt, err = wait.ForReceiptOK(ctx, l2Client, depositTxHash)
+	if l1Only {
+		deposit, err := internal.DecodeL2Deposit(contracts.OptimismPortal, receipt)
 		if err != nil {
-			if statusErr, ok := err.(*wait.ReceiptStatusError); ok {
-				log.Error("deposit transaction trace", "tx", tx.Hash().Hex(), "trace", statusErr.TxTrace)
-				return fmt.Errorf("failure in deposit execution: %w", err)
-			} else {
-				return fmt.Errorf("found error waiting for deposit receipt: %w", err)
-			}
+			return err
 		}
+		result.L2TxHash = deposit.L2TxHash
+		result.Deposit = deposit
+		log.Info("deposit accepted on L1, not waiting for the L2 deposit transaction", "l2Tx", deposit.L2TxHash.Hex())
+		return nil
+	}
 
-		log.Info("deposit transaction successfully propogated to L2", "receipt", receipt)
+	estimateCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+	internal.CheckDepositGasLimit(estimateCtx, l2Client, recipient, amount, l2GasLimit)
 
-		senderPostBalance, err := l1Client.BalanceAt(ctx, sender, nil)
-		recipientPostBalance, err := l2Client.BalanceAt(ctx, recipient, nil)
+	deposit, receipt, err := internal.WaitForL2Deposit(ctx, l2Client, contracts.OptimismPortal, receipt)
+	if deposit != nil {
+		result.L2TxHash = deposit.L2TxHash
+		result.Deposit = deposit
+	}
+	if err != nil {
+		return err
+	}
+	result.L2BlockNumber = receipt.BlockNumber.Uint64()
+	result.L2Receipt = receipt
+	result.Timings.L2Mined()
 
-		senderDiff := new(big.Int).Sub(senderPreBalance, senderPostBalance)
-		recipientDiff := new(big.Int).Sub(recipientPostBalance, recipientPreBalance)
-		gasSpent := new(big.Int).Sub(senderDiff, recipientDiff)
+	// The deposit went through at this point, which a failed balance read reports so that it is not sent again
+	balanceCtx, cancelBalance = context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancelBalance()
 
-		log.Info(
-			"Balance differentials",
-			"recipient L2 balance (+)", internal.FormatWei(recipientDiff),
-			"sender L1 balance (-)", internal.FormatWei(senderDiff),
-			"gas", internal.FormatWei(gasSpent),
-		)
+	senderPostBalance, err := l1Client.BalanceAt(balanceCtx, sender, nil)
+	if err != nil {
+		return fmt.Errorf("deposit succeeded but the L1 balance of sender %s could not be fetched: %w", sender.Hex(), err)
+	}
+	recipientPostBalance, err := l2Client.BalanceAt(balanceCtx, recipient, nil)
+	if err != nil {
+		return fmt.Errorf("deposit succeeded but the L2 balance of recipient %s could not be fetched: %w", recipient.Hex(), err)
+	}
 
-		return nil
-	},
+	senderDiff := new(big.Int).Sub(senderPreBalance, senderPostBalance)
+	recipientDiff := new(big.Int).Sub(recipientPostBalance, recipientPreBalance)
+	gasSpent := new(big.Int).Sub(senderDiff, recipientDiff)
+
+	result.SenderPostBalance = senderPostBalance
+	result.RecipientPostBalance = recipientPostBalance
+	result.SenderDiff = senderDiff
+	result.RecipientDiff = recipientDiff
+	result.GasSpent = gasSpent
+	result.Formatted = &depositFormattedBalances{
+		SenderPreBalance:     internal.FormatWei(senderPreBalance),
+		SenderPostBalance:    internal.FormatWei(senderPostBalance),
+		RecipientPreBalance:  internal.FormatWei(recipientPreBalance),
+		RecipientPostBalance: internal.FormatWei(recipientPostBalance),
+		SenderDiff:           internal.FormatWei(senderDiff),
+		RecipientDiff:        internal.FormatWei(recipientDiff),
+		GasSpent:             internal.FormatWei(gasSpent),
+	}
+
+	log.Info(
+		"Balance differentials",
+		"recipient L2 balance (+)", internal.FormatWei(recipientDiff),
+		"sender L1 balance (-)", internal.FormatWei(senderDiff),
+		"gas", internal.FormatWei(gasSpent),
+	)
+
+	return nil
+}
+
+type depositResult struct {
+	Sender               common.Address            `json:"sender"`
+	Recipient            common.Address            `json:"recipient"`
+	Amount               *big.Int                  `json:"amount"`
+	L1TxHash             common.Hash               `json:"l1TxHash"`
+	L1BlockNumber        uint64                    `json:"l1BlockNumber"`
+	L1Receipt            *types.Receipt            `json:"l1Receipt"`
+	L1Gas                *internal.GasReport       `json:"l1Gas,omitempty"`
+	L2TxHash             common.Hash               `json:"l2TxHash"`
+	L2BlockNumber        uint64                    `json:"l2BlockNumber,omitempty"`
+	L2Receipt            *types.Receipt            `json:"l2Receipt,omitempty"`
+	Deposit              *internal.DecodedDeposit  `json:"deposit,omitempty"`
+	Timings              *internal.DepositTimings  `json:"timings,omitempty"`
+	SenderPreBalance     *big.Int                  `json:"senderPreBalance"`
+	SenderPostBalance    *big.Int                  `json:"senderPostBalance"`
+	RecipientPreBalance  *big.Int                  `json:"recipientPreBalance"`
+	RecipientPostBalance *big.Int                  `json:"recipientPostBalance"`
+	SenderDiff           *big.Int                  `json:"senderDiff,omitempty"`
+	RecipientDiff        *big.Int                  `json:"recipientDiff,omitempty"`
+	GasSpent             *big.Int                  `json:"gasSpent"`
+	Formatted            *depositFormattedBalances `json:"formatted,omitempty"`
+}
+
+// depositFormattedBalances holds the balances of a depositResult formatted in ETH
+type depositFormattedBalances struct {
+	SenderPreBalance     string `json:"senderPreBalance"`
+	SenderPostBalance    string `json:"senderPostBalance"`
+	RecipientPreBalance  string `json:"recipientPreBalance"`
+	RecipientPostBalance string `json:"recipientPostBalance"`
+	SenderDiff           string `json:"senderDiff"`
+	RecipientDiff        string `json:"recipientDiff"`
+	GasSpent             string `json:"gasSpent"`
 }
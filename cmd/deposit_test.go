@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/Golem-Base/op-probe/internal"
+	"github.com/Golem-Base/op-probe/internal/testutil"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newDepositTest starts a fake OP Stack with a funded sender and returns what depositETH is called with
+func newDepositTest(t *testing.T) (*testutil.OPStack, *internal.DepositContracts, *bind.TransactOpts, *depositResult) {
+	t.Helper()
+	stack := testutil.NewOPStack(t)
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	sender := crypto.PubkeyToAddress(privateKey.PublicKey)
+	stack.L1.SetBalance(sender, big.NewInt(params.Ether))
+
+	contracts, err := internal.NewDepositContracts(context.Background(), stack.L1.Client(), stack.L2.Client(), testutil.OptimismPortalAddress, testutil.L1StandardBridgeAddress)
+	if err != nil {
+		t.Fatalf("could not instantiate deposit contracts: %v", err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(privateKey, stack.L1.ChainID)
+	if err != nil {
+		t.Fatalf("could not create transactor: %v", err)
+	}
+
+	result := &depositResult{
+		Sender:    sender,
+		Recipient: common.HexToAddress("0x000000000000000000000000000000000000beef"),
+		Amount:    big.NewInt(params.GWei),
+	}
+	return stack, contracts, opts, result
+}
+
+func TestDepositETH(t *testing.T) {
+	stack, contracts, opts, result := newDepositTest(t)
+
+	err := depositETH(context.Background(), stack.L1.Client(), stack.L2.Client(), contracts, opts, internal.RECEIVE_DEFAULT_GAS_LIMIT, false, result)
+	if err != nil {
+		t.Fatalf("depositETH: %v", err)
+	}
+
+	if sent := stack.L1.Sent(); len(sent) != 1 || sent[0].Hash() != result.L1TxHash {
+		t.Fatalf("expected the deposit as the only L1 transaction, sent %d", len(sent))
+	}
+	if result.Deposit == nil || result.L2Receipt == nil || result.L2Receipt.TxHash != result.Deposit.L2TxHash {
+		t.Fatalf("the L2 deposit transaction was not recorded: %+v", result.Deposit)
+	}
+	if result.RecipientDiff.Cmp(result.Amount) != 0 {
+		t.Errorf("recipient was credited %s, want %s", result.RecipientDiff, result.Amount)
+	}
+	wantGas := new(big.Int).Mul(result.L1Receipt.EffectiveGasPrice, big.NewInt(testutil.GasUsed))
+	if result.GasSpent.Cmp(wantGas) != 0 {
+		t.Errorf("gas spent is %s, want %s", result.GasSpent, wantGas)
+	}
+}
+
+func TestDepositETHL1Only(t *testing.T) {
+	stack, contracts, opts, result := newDepositTest(t)
+
+	err := depositETH(context.Background(), stack.L1.Client(), stack.L2.Client(), contracts, opts, internal.RECEIVE_DEFAULT_GAS_LIMIT, true, result)
+	if err != nil {
+		t.Fatalf("depositETH: %v", err)
+	}
+	if result.Deposit == nil || result.L2TxHash != result.Deposit.L2TxHash {
+		t.Fatalf("the derived L2 deposit transaction was not recorded: %+v", result.Deposit)
+	}
+	if result.L2Receipt != nil {
+		t.Errorf("the L2 deposit was waited for with l1Only")
+	}
+}
+
+func TestDepositETHPreBalanceError(t *testing.T) {
+	stack, contracts, opts, result := newDepositTest(t)
+	stack.L1.BalanceErr = func(common.Address) error { return errors.New("balance unavailable") }
+
+	err := depositETH(context.Background(), stack.L1.Client(), stack.L2.Client(), contracts, opts, internal.RECEIVE_DEFAULT_GAS_LIMIT, false, result)
+	if err == nil {
+		t.Fatal("depositETH succeeded without the sender balance")
+	}
+	if sent := stack.L1.Sent(); len(sent) != 0 {
+		t.Errorf("sent %d transactions after failing to read the sender balance", len(sent))
+	}
+}
+
+func TestDepositETHPostBalanceError(t *testing.T) {
+	stack, contracts, opts, result := newDepositTest(t)
+	// The balance of the recipient is only unavailable once the deposit went through
+	stack.L2.BalanceErr = func(common.Address) error {
+		if len(stack.L1.Sent()) > 0 {
+			return errors.New("balance unavailable")
+		}
+		return nil
+	}
+
+	err := depositETH(context.Background(), stack.L1.Client(), stack.L2.Client(), contracts, opts, internal.RECEIVE_DEFAULT_GAS_LIMIT, false, result)
+	if err == nil {
+		t.Fatal("depositETH succeeded without the recipient balance")
+	}
+	if !strings.Contains(err.Error(), "deposit succeeded") {
+		t.Errorf("error does not report the deposit as succeeded: %v", err)
+	}
+	if result.L2Receipt == nil {
+		t.Errorf("the L2 deposit receipt was not recorded before the balance read failed")
+	}
+}
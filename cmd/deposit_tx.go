@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/Golem-Base/op-probe/internal"
+
+	"github.com/ethereum-optimism/optimism/op-e2e/bindings"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+var DepositTxCommand = &cli.Command{
+	Name:  "deposit-tx",
+	Usage: "Sends an arbitrary L1 to L2 deposit transaction through OptimismPortal.depositTransaction",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "private-key",
+			Usage:    "Private key of address to send test transaction from, or - to read it from stdin",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "l1-rpc-url",
+			Usage:    "Url for L1 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "l2-rpc-url",
+			Usage:    "Url for L2 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "optimism-portal-address",
+			Usage: "Contract address for the OptimismPortal (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.StringFlag{
+			Name:  "to",
+			Usage: "L2 address to call, required unless --is-creation is set",
+		},
+		&cli.StringFlag{
+			Name:  "value",
+			Usage: "Amount to send with the L2 call (wei), also minted on L2 from the L1 transaction value",
+			Value: "0",
+		},
+		&cli.Uint64Flag{
+			Name:  "gas-limit",
+			Usage: "Gas limit of the L2 transaction",
+			Value: uint64(internal.RECEIVE_DEFAULT_GAS_LIMIT),
+		},
+		&cli.BoolFlag{
+			Name:  "is-creation",
+			Usage: "Deploy a contract on L2 with --data as init code",
+		},
+		&cli.StringFlag{
+			Name:  "data",
+			Usage: "Calldata of the L2 transaction, 0x prefixed hex",
+			Value: "0x",
+		},
+		&cli.Uint64Flag{
+			Name:  "nonce",
+			Usage: "Nonce of the first transaction sent (default: pending nonce of the sender)",
+		},
+	},
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := context.Background()
+
+		value, err := internal.ParseUint256BigInt(c.String("value"))
+		if err != nil {
+			return err
+		}
+
+		gasLimit := c.Uint64("gas-limit")
+		if gasLimit == 0 {
+			return fmt.Errorf("gas-limit must be greater than 0")
+		}
+
+		data, err := hexutil.Decode(c.String("data"))
+		if err != nil {
+			return fmt.Errorf("could not decode data: %w", err)
+		}
+
+		isCreation := c.Bool("is-creation")
+		to := internal.ZeroAddress
+		if isCreation {
+			if c.IsSet("to") {
+				return fmt.Errorf("to must not be set with is-creation")
+			}
+		} else {
+			if !c.IsSet("to") {
+				return fmt.Errorf("to is required unless is-creation is set")
+			}
+			to, err = internal.SafeParseAddress(c.String("to"))
+			if err != nil {
+				return fmt.Errorf("could not parse to address: %w", err)
+			}
+		}
+
+		privateKey, err := internal.LoadSigner(c)
+		if err != nil {
+			return err
+		}
+
+		l1RpcUrl := c.String("l1-rpc-url")
+		l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
+		}
+
+		l2RpcUrl := c.String("l2-rpc-url")
+		l2Client, l2ChainId, err := internal.ConnectClient(ctx, c, l2RpcUrl, "l2-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l2RpcUrl, err)
+		}
+
+		if err := internal.ValidateChainIds(c, l1ChainId, l2ChainId); err != nil {
+			return err
+		}
+
+		optimismPortalAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "optimism-portal-address")
+		if err != nil {
+			return fmt.Errorf("could not resolve OptimismPortal address: %w", err)
+		}
+		optimismPortal, err := bindings.NewOptimismPortal(optimismPortalAddress, l1Client)
+		if err != nil {
+			return fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+		}
+
+		sender := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+		result := &depositTxResult{Sender: sender, To: to, Value: value, GasLimit: gasLimit, IsCreation: isCreation, Data: data}
+		output.Result = result
+		output.SetAccount(sender)
+		output.Amount = value
+
+		opts, err := internal.NewTransactor(ctx, c, l1Client, privateKey, l1ChainId)
+		if err != nil {
+			return err
+		}
+		opts.Value = value
+
+		log.Info("executing OptimismPortal.depositTransaction transaction", "to", to, "value", value, "gasLimit", gasLimit, "isCreation", isCreation)
+
+		result.Timings = internal.NewDepositTimings()
+		receipt, err := internal.SendTransaction(ctx, l1Client, opts, "OptimismPortal.DepositTransaction", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return optimismPortal.DepositTransaction(opts, to, value, gasLimit, isCreation, data)
+		})
+		if err != nil {
+			return err
+		}
+
+		result.L1TxHash = receipt.TxHash
+		output.Primary = receipt.TxHash
+		output.AddTx(receipt.TxHash)
+		result.L1Receipt = receipt
+		result.L1Gas = internal.NewGasReport(receipt)
+		result.Timings.L1Mined()
+
+		deposit, receipt, err := internal.WaitForL2Deposit(ctx, l2Client, optimismPortal, receipt)
+		if deposit != nil {
+			result.L2TxHash = deposit.L2TxHash
+			result.Deposit = deposit
+		}
+		if err != nil {
+			return err
+		}
+		output.AddTx(deposit.L2TxHash)
+		result.L2Receipt = receipt
+		result.Timings.L2Mined()
+
+		if isCreation {
+			log.Info("deposit transaction created contract on L2", "address", receipt.ContractAddress)
+		}
+
+		return nil
+	}),
+}
+
+type depositTxResult struct {
+	Sender     common.Address           `json:"sender"`
+	To         common.Address           `json:"to"`
+	Value      *big.Int                 `json:"value"`
+	GasLimit   uint64                   `json:"gasLimit"`
+	IsCreation bool                     `json:"isCreation"`
+	Data       hexutil.Bytes            `json:"data"`
+	L1TxHash   common.Hash              `json:"l1TxHash"`
+	L1Receipt  *types.Receipt           `json:"l1Receipt"`
+	L1Gas      *internal.GasReport      `json:"l1Gas,omitempty"`
+	L2TxHash   common.Hash              `json:"l2TxHash"`
+	L2Receipt  *types.Receipt           `json:"l2Receipt"`
+	Deposit    *internal.DecodedDeposit `json:"deposit,omitempty"`
+	Timings    *internal.DepositTimings `json:"timings,omitempty"`
+}
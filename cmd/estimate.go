@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"fmt"
+
+	estimate_cmd "github.com/Golem-Base/op-probe/cmd/estimate"
+	"github.com/urfave/cli/v2"
+)
+
+var EstimateCommand = &cli.Command{
+	Name:  "estimate",
+	Usage: "Estimates the cost and timing of bridging operations without sending transactions",
+	Subcommands: []*cli.Command{
+		estimate_cmd.WithdrawCommand,
+	},
+	Action: func(cCtx *cli.Context) error {
+		fmt.Println("Estimate command requires a subcommand: withdraw")
+		cli.ShowSubcommandHelp(cCtx)
+		return nil
+	},
+}
@@ -0,0 +1,265 @@
+package estimate_cmd
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Golem-Base/op-probe/bindings"
+	"github.com/Golem-Base/op-probe/internal"
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+var WithdrawCommand = &cli.Command{
+	Name:  "withdraw",
+	Usage: "Estimates the L1 gas cost and waiting time to prove and finalize a withdrawal",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "account",
+			Usage:    "Address that will prove and finalize the withdrawal",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "l1-rpc-url",
+			Usage:    "Url for L1 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "l2-rpc-url",
+			Usage:    "Url for L2 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "tx",
+			Usage:    "The L2 withdrawal transaction hash",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "dispute-game-factory-address",
+			Usage: "Contract address for DisputeGameFactory (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.StringFlag{
+			Name:  "optimism-portal-address",
+			Usage: "Contract address for OptimismPortal (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.StringFlag{
+			Name:  "proof-variant",
+			Usage: "Withdrawal proof to generate: fault-proofs, or withdrawals-root for Isthmus chains whose L2 account proofs do not verify against the state root",
+			Value: internal.ProofVariantFaultProofs,
+		},
+	},
+	Action: func(c *cli.Context) error {
+		ctx := context.Background()
+
+		if err := internal.ValidateProofVariant(c.String("proof-variant")); err != nil {
+			return err
+		}
+
+		l1RpcUrl := c.String("l1-rpc-url")
+		l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
+		}
+
+		l2RpcUrl := c.String("l2-rpc-url")
+		l2Client, l2ChainId, err := internal.ConnectClient(ctx, c, l2RpcUrl, "l2-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l2RpcUrl, err)
+		}
+
+		if err := internal.ValidateChainIds(c, l1ChainId, l2ChainId); err != nil {
+			return err
+		}
+
+		account, err := internal.SafeParseAddress(c.String("account"))
+		if err != nil {
+			return fmt.Errorf("could not parse account: %w", err)
+		}
+
+		withdrawalTxHash, err := internal.SafeParseHash(c.String("tx"))
+		if err != nil {
+			return fmt.Errorf("could not parse tx: %w", err)
+		}
+
+		disputeGameFactoryAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "dispute-game-factory-address")
+		if err != nil {
+			return fmt.Errorf("could not resolve DisputeGameFactory address: %w", err)
+		}
+		disputeGameFactory, err := opNodeBindings.NewDisputeGameFactory(disputeGameFactoryAddress, l1Client)
+		if err != nil {
+			return fmt.Errorf("could not instantiate DisputeGameFactory contract: %w", err)
+		}
+
+		optimismPortalAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "optimism-portal-address")
+		if err != nil {
+			return fmt.Errorf("could not resolve OptimismPortal address: %w", err)
+		}
+		optimismPortal, err := internal.NewOptimismPortal2(ctx, c, l1Client, optimismPortalAddress)
+		if err != nil {
+			return fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+		}
+		optimismPortalABI, err := bindingspreview.OptimismPortal2MetaData.GetAbi()
+		if err != nil {
+			return fmt.Errorf("could not get OptimismPortal abi: %w", err)
+		}
+
+		proofMaturityDelaySeconds, err := optimismPortal.ProofMaturityDelaySeconds(&bind.CallOpts{Context: ctx})
+		if err != nil {
+			return fmt.Errorf("could not call OptimismPortal.ProofMaturityDelaySeconds: %w", err)
+		}
+		proofMaturityDelay := time.Duration(proofMaturityDelaySeconds.Int64() * int64(time.Second))
+
+		finalityDelaySeconds, err := optimismPortal.DisputeGameFinalityDelaySeconds(&bind.CallOpts{Context: ctx})
+		if err != nil {
+			return fmt.Errorf("could not call OptimismPortal.DisputeGameFinalityDelaySeconds: %w", err)
+		}
+		finalityDelay := time.Duration(finalityDelaySeconds.Int64() * int64(time.Second))
+
+		// Receipts are cached for the run so the proof generation reuses the withdrawal receipt fetched below
+		l2Receipts := internal.NewReceiptCache(l2Client, internal.DefaultReceiptCacheSize)
+
+		withdrawalTxReceipt, err := l2Receipts.TransactionReceipt(ctx, withdrawalTxHash)
+		if err != nil {
+			return fmt.Errorf("could not get receipt for withdrawal event in transaction: %s: %w", withdrawalTxHash.Hex(), err)
+		}
+
+		game, err := internal.FindLatestGame(&bind.CallOpts{Context: ctx}, &disputeGameFactory.DisputeGameFactoryCaller, &optimismPortal.OptimismPortal2Caller)
+		if err != nil {
+			return fmt.Errorf("failed to find latest game: %w", err)
+		}
+
+		gameL2BlockNumber, err := internal.SearchResultL2BlockNumber(game)
+		if err != nil {
+			return err
+		}
+
+		// Without a game covering the withdrawal there is nothing to prove against and no game clock running yet
+		if gameL2BlockNumber.Uint64() < withdrawalTxReceipt.BlockNumber.Uint64() {
+			log.Info(
+				"Withdrawal estimate",
+				"gameProposed", false,
+				"blocksUntilGame", withdrawalTxReceipt.BlockNumber.Uint64()-gameL2BlockNumber.Uint64(),
+				"proofMaturityDelay", proofMaturityDelay,
+				"disputeGameFinalityDelay", finalityDelay,
+				"earliestFinalizableIn", "unknown",
+			)
+			return nil
+		}
+
+		latestGame, err := disputeGameFactory.GameAtIndex(&bind.CallOpts{Context: ctx}, game.Index)
+		if err != nil {
+			return fmt.Errorf("could not fetch DisputeGameFactory.GameAtIndex: %w", err)
+		}
+		permissionedDisputeGame, err := bindings.NewPermissionedDisputeGame(latestGame.Proxy, l1Client)
+		if err != nil {
+			return fmt.Errorf("could not construct permissioned dispute game")
+		}
+		_maxClockDuration, err := permissionedDisputeGame.MaxClockDuration(&bind.CallOpts{Context: ctx})
+		if err != nil {
+			return fmt.Errorf("PermissionedDisputeGame.MaxClockDuration failed: %w", err)
+		}
+		maxClockDuration := time.Duration(_maxClockDuration * uint64(time.Second))
+
+		gasPrice, err := l1Client.SuggestGasPrice(ctx)
+		if err != nil {
+			return fmt.Errorf("could not fetch L1 gas price: %w", err)
+		}
+
+		params, err := internal.ProveWithdrawalParametersForGame(
+			ctx,
+			c.String("proof-variant"),
+			gethclient.New(l2Client.Client()),
+			l2Receipts,
+			l2Client,
+			withdrawalTxHash,
+			game,
+		)
+		if err != nil {
+			return fmt.Errorf("could not generate fault proofs for withdrawal: %w", err)
+		}
+
+		withdrawalTransaction := bindingspreview.TypesWithdrawalTransaction{
+			Nonce:    params.Nonce,
+			Sender:   params.Sender,
+			Target:   params.Target,
+			Value:    params.Value,
+			GasLimit: params.GasLimit,
+			Data:     params.Data,
+		}
+
+		proveData, err := optimismPortalABI.Pack(
+			"proveWithdrawalTransaction",
+			withdrawalTransaction,
+			params.L2OutputIndex,
+			bindingspreview.TypesOutputRootProof{
+				Version:                  params.OutputRootProof.Version,
+				StateRoot:                params.OutputRootProof.StateRoot,
+				MessagePasserStorageRoot: params.OutputRootProof.MessagePasserStorageRoot,
+				LatestBlockhash:          params.OutputRootProof.LatestBlockhash,
+			},
+			params.WithdrawalProof,
+		)
+		if err != nil {
+			return fmt.Errorf("could not encode OptimismPortal.ProveWithdrawalTransaction call: %w", err)
+		}
+		proveGas, err := l1Client.EstimateGas(ctx, ethereum.CallMsg{From: account, To: &optimismPortalAddress, Data: proveData})
+		if err != nil {
+			return fmt.Errorf("could not estimate gas for OptimismPortal.ProveWithdrawalTransaction: %w", err)
+		}
+
+		finalizeData, err := optimismPortalABI.Pack("finalizeWithdrawalTransaction", withdrawalTransaction)
+		if err != nil {
+			return fmt.Errorf("could not encode OptimismPortal.FinalizeWithdrawalTransaction call: %w", err)
+		}
+
+		proveCost := new(big.Int).Mul(new(big.Int).SetUint64(proveGas), gasPrice)
+		totalCost := new(big.Int).Set(proveCost)
+
+		// Finalizing only succeeds once the withdrawal is proven and matured, so until then the estimate reverts
+		// and the finalize cost is reported as unknown rather than counted as free
+		finalizeFields := []any{"finalizeGas", "unknown", "finalizeCost", "unknown"}
+		finalizeGas, err := l1Client.EstimateGas(ctx, ethereum.CallMsg{From: account, To: &optimismPortalAddress, Data: finalizeData})
+		if err != nil {
+			log.Warn("could not estimate gas for OptimismPortal.FinalizeWithdrawalTransaction against the current state, the total cost only includes the prove transaction", "error", err)
+		} else {
+			finalizeCost := new(big.Int).Mul(new(big.Int).SetUint64(finalizeGas), gasPrice)
+			totalCost.Add(totalCost, finalizeCost)
+			finalizeFields = []any{"finalizeGas", finalizeGas, "finalizeCost", internal.FormatWei(finalizeCost)}
+		}
+
+		// The proof matures from the moment it is submitted, while the covering game has been running its clock
+		// since it was created and then has to pass the finality delay, so the later of the two bounds finalization
+		earliestFinalizableAt := time.Now().Add(proofMaturityDelay)
+		gameFinalAt := time.Unix(int64(latestGame.Timestamp), 0).Add(maxClockDuration + finalityDelay)
+		if gameFinalAt.After(earliestFinalizableAt) {
+			earliestFinalizableAt = gameFinalAt
+		}
+
+		fields := []any{
+			"gameProposed", true,
+			"disputeGame", latestGame.Proxy,
+			"gasPrice", internal.FormatWei(gasPrice),
+			"proveGas", proveGas,
+			"proveCost", internal.FormatWei(proveCost),
+		}
+		fields = append(fields, finalizeFields...)
+		fields = append(fields,
+			"totalCost", internal.FormatWei(totalCost),
+			"proofMaturityDelay", proofMaturityDelay,
+			"disputeGameFinalityDelay", finalityDelay,
+			"maxClockDuration", maxClockDuration,
+			"earliestFinalizableIn", time.Until(earliestFinalizableAt),
+			"earliestFinalizableAt", earliestFinalizableAt,
+		)
+		log.Info("Withdrawal estimate", fields...)
+
+		return nil
+	},
+}
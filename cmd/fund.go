@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Golem-Base/op-probe/internal"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+var FundCommand = &cli.Command{
+	Name:  "fund",
+	Usage: "Transfers the same amount of ETH from a faucet key to each of a list of recipients",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "rpc-url",
+			Usage:    "Url for exection client",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "private-key",
+			Usage:    "Private key of the faucet address to fund the recipients from, or - to read it from stdin",
+			Required: true,
+		},
+		&cli.StringSliceFlag{
+			Name:  "recipients",
+			Usage: "Comma separated addresses to fund, may be repeated",
+		},
+		&cli.StringFlag{
+			Name:  "recipients-file",
+			Usage: "Path to a file containing addresses to fund, one per line",
+		},
+		&cli.StringFlag{
+			Name:     "amount-each",
+			Usage:    "Amount to send to each recipient in wei",
+			Required: true,
+		},
+		&cli.Uint64Flag{
+			Name:  "gas-limit",
+			Usage: "Gas limit of each transfer (default: padded gas estimate)",
+		},
+		&cli.IntFlag{
+			Name:  "concurrency",
+			Usage: "Maximum number of transfers whose receipts are waited for in parallel",
+			Value: 8,
+		},
+		&cli.Uint64Flag{
+			Name:  "nonce",
+			Usage: "Nonce of the first transaction sent (default: pending nonce of the sender)",
+		},
+	},
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := context.Background()
+
+		amount, err := internal.ParseUint256BigInt(c.String("amount-each"))
+		if err != nil {
+			return err
+		}
+
+		concurrency := c.Int("concurrency")
+		if concurrency < 1 {
+			return fmt.Errorf("concurrency must be at least 1, got %d", concurrency)
+		}
+
+		recipients, err := readRecipients(c)
+		if err != nil {
+			return err
+		}
+
+		privateKey, err := internal.LoadSigner(c)
+		if err != nil {
+			return err
+		}
+
+		rpcUrl := c.String("rpc-url")
+		client, chainId, err := internal.ConnectClient(ctx, c, rpcUrl, "chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", rpcUrl, err)
+		}
+
+		opts, err := internal.NewTransactor(ctx, c, client, privateKey, chainId)
+		if err != nil {
+			return err
+		}
+		// Transfers are sent back to back, so the nonces are tracked here rather than read from the pending state
+		if opts.Nonce == nil {
+			pendingNonce, err := client.PendingNonceAt(ctx, opts.From)
+			if err != nil {
+				return fmt.Errorf("could not fetch pending nonce: %w", err)
+			}
+			opts.Nonce = new(big.Int).SetUint64(pendingNonce)
+		}
+
+		results := make([]*fundResult, len(recipients))
+		output.Result = results
+		output.SetAccount(opts.From)
+
+		log.Info("funding recipients", "faucet", opts.From, "recipients", len(recipients), "amountEach", internal.FormatWei(amount))
+
+		// Transfers are sent one at a time, in nonce order, then their receipts are waited for by a bounded pool
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for i, recipient := range recipients {
+			results[i] = &fundResult{Recipient: recipient, Amount: amount}
+
+			tx, err := internal.BroadcastValueTx(ctx, client, opts, recipient, amount, nil, c.Uint64("gas-limit"))
+			if errors.Is(err, internal.ErrSenderBalanceLow) {
+				// The remaining transfers would fail the same way, so they are not attempted
+				log.Error("faucet balance is below min-balance, not funding the remaining recipients", "error", err)
+				for j := i; j < len(recipients); j++ {
+					results[j] = &fundResult{Recipient: recipients[j], Amount: amount, Error: err.Error()}
+				}
+				break
+			}
+			if err != nil {
+				log.Error("failed to send transfer, continuing with the remaining recipients", "recipient", recipient, "error", err)
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].TxHash = tx.Hash()
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(result *fundResult, tx *types.Transaction) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				sent, err := internal.WaitForValueTx(ctx, client, opts, tx)
+				if err != nil {
+					log.Error("transfer failed", "recipient", result.Recipient, "tx", tx.Hash().Hex(), "error", err)
+					result.Error = err.Error()
+					return
+				}
+				result.Receipt = sent.Receipt
+				result.Gas = internal.NewGasReport(sent.Receipt)
+			}(results[i], tx)
+		}
+		wg.Wait()
+
+		var errs []error
+		var funded []common.Hash
+		for _, result := range results {
+			if result.Error != "" {
+				log.Info("funding summary", "recipient", result.Recipient, "result", "failed", "error", result.Error)
+				errs = append(errs, fmt.Errorf("recipient %s: %s", result.Recipient.Hex(), result.Error))
+			} else {
+				log.Info("funding summary", "recipient", result.Recipient, "result", "funded", "tx", result.TxHash.Hex())
+				funded = append(funded, result.TxHash)
+			}
+		}
+		output.Primary = funded
+		output.TxHashes = funded
+		output.Amount = new(big.Int).Mul(amount, big.NewInt(int64(len(funded))))
+		log.Info("funded recipients", "funded", len(funded), "failed", len(errs))
+		if len(errs) > 0 {
+			return fmt.Errorf("%d of %d recipients failed to be funded: %w", len(errs), len(results), errors.Join(errs...))
+		}
+
+		return nil
+	}),
+}
+
+type fundResult struct {
+	Recipient common.Address      `json:"recipient"`
+	Amount    *big.Int            `json:"amount"`
+	TxHash    common.Hash         `json:"txHash,omitempty"`
+	Error     string              `json:"error,omitempty"`
+	Receipt   *types.Receipt      `json:"receipt,omitempty"`
+	Gas       *internal.GasReport `json:"gas,omitempty"`
+}
+
+// readRecipients collects the addresses passed with --recipients and --recipients-file
+func readRecipients(c *cli.Context) ([]common.Address, error) {
+	var recipients []common.Address
+	for _, recipient := range c.StringSlice("recipients") {
+		address, err := internal.SafeParseAddress(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse --recipients: %w", err)
+		}
+		recipients = append(recipients, address)
+	}
+
+	if path := c.String("recipients-file"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read recipients file %s: %w", path, err)
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			address, err := internal.SafeParseAddress(line)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %s line %d: %w", path, i+1, err)
+			}
+			recipients = append(recipients, address)
+		}
+	}
+
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("at least one recipient must be provided with --recipients or --recipients-file")
+	}
+
+	return recipients, nil
+}
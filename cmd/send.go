@@ -3,19 +3,21 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"math/big"
 
 	"github.com/Golem-Base/op-probe/internal"
 
-	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/transactions"
-	"github.com/ethereum-optimism/optimism/op-service/txmgr"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/urfave/cli/v2"
 )
 
 var SendCommand = &cli.Command{
 	Name:  "send",
-	Usage: "Waits for the client to produce blocks and attempts to transfer ETH",
+	Usage: "Waits for the client to produce blocks and attempts to transfer ETH, optionally calling the recipient with --data",
 
 	// Example flags
 	Flags: []cli.Flag{
@@ -25,9 +27,12 @@ var SendCommand = &cli.Command{
 			Required: true,
 		},
 		&cli.StringFlag{
-			Name:     "private-key",
-			Usage:    "Private key of address to send test transaction from",
-			Required: true,
+			Name:  "private-key",
+			Usage: "Private key of address to send test transaction from, or - to read it from stdin, required unless --impersonate is set",
+		},
+		&cli.StringFlag{
+			Name:  "impersonate",
+			Usage: "Address to send from without its private key, on dev nodes supporting account impersonation (anvil, hardhat) or holding it unlocked",
 		},
 		&cli.StringFlag{
 			Name:     "amount",
@@ -39,8 +44,37 @@ var SendCommand = &cli.Command{
 			Usage:    "Address to receive amount",
 			Required: true,
 		},
+		&cli.StringFlag{
+			Name:  "data",
+			Usage: "Calldata to call the recipient with, 0x prefixed hex",
+			Value: "0x",
+		},
+		&cli.Uint64Flag{
+			Name:  "gas-limit",
+			Usage: "Gas limit of the transaction (default: padded gas estimate)",
+		},
+		&cli.Uint64Flag{
+			Name:  "nonce",
+			Usage: "Nonce of the first transaction sent (default: pending nonce of the sender)",
+		},
+		&cli.StringFlag{
+			Name:  "tx-type",
+			Usage: "Force the transaction type, legacy or dynamic, for nodes rejecting the other (default: dynamic once the chain has a base fee)",
+		},
+		&cli.StringFlag{
+			Name:  "gas-price",
+			Usage: "Gas price of legacy transactions in wei (default: suggested by the node)",
+		},
+		&cli.StringFlag{
+			Name:  "max-fee-per-gas",
+			Usage: "Fee cap of dynamic fee transactions in wei (default: twice the base fee plus the tip)",
+		},
+		&cli.StringFlag{
+			Name:  "max-priority-fee-per-gas",
+			Usage: "Tip of dynamic fee transactions in wei (default: suggested by the node)",
+		},
 	},
-	Action: func(c *cli.Context) error {
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
 		ctx := context.Background()
 
 		amount, err := internal.ParseUint256BigInt(c.String("amount"))
@@ -48,15 +82,18 @@ var SendCommand = &cli.Command{
 			return err
 		}
 
-		privateKey, err := crypto.HexToECDSA(c.String("private-key"))
+		data, err := hexutil.Decode(c.String("data"))
 		if err != nil {
-			return fmt.Errorf("failed to parse private-key: %w", err)
+			return fmt.Errorf("could not decode data: %w", err)
 		}
 
-		sender := crypto.PubkeyToAddress(privateKey.PublicKey)
+		sender, privateKey, err := internal.SenderAccount(c)
+		if err != nil {
+			return err
+		}
 
 		rpcUrl := c.String("rpc-url")
-		client, _, err := internal.ConnectClient(ctx, rpcUrl)
+		client, chainId, err := internal.ConnectClient(ctx, c, rpcUrl, "chain-id")
 		if err != nil {
 			return fmt.Errorf("could not connect to client at %s: %w", rpcUrl, err)
 		}
@@ -66,17 +103,46 @@ var SendCommand = &cli.Command{
 			return fmt.Errorf("could not parse recipient address: %w", err)
 		}
 
-		log.Info("sending transaction", "amount", amount, "sender", sender, "recipient", recipient)
+		result := &sendResult{Sender: sender, Recipient: recipient, Amount: amount, Data: data}
+		output.Result = result
+		output.SetAccount(sender)
+		output.Amount = amount
+
+		log.Info("sending transaction", "amount", amount, "sender", sender, "recipient", recipient, "dataSize", len(data))
 
-		candidate := txmgr.TxCandidate{
-			To:       &internal.ZeroAddress,
-			GasLimit: 21000,
-			Value:    amount,
+		var opts *bind.TransactOpts
+		if privateKey == nil {
+			opts, err = internal.NewImpersonatingTransactor(ctx, c, client, sender)
+		} else {
+			opts, err = internal.NewTransactor(ctx, c, client, privateKey, chainId)
+		}
+		if err != nil {
+			return err
 		}
-		_, receipt, err := transactions.SendTx(ctx, client, candidate, privateKey)
 
-		log.Info("successfully sent transaction", "tx", receipt.TxHash.Hex())
+		sent, err := internal.SendValueTx(ctx, client, opts, recipient, amount, data, c.Uint64("gas-limit"))
+		if err != nil {
+			return err
+		}
+
+		result.TxHash = sent.Tx.Hash()
+		output.Primary = sent.Tx.Hash()
+		output.AddTx(sent.Tx.Hash())
+		result.Receipt = sent.Receipt
+		result.Gas = internal.NewGasReport(sent.Receipt)
+
+		log.Info("successfully sent transaction", "tx", sent.Tx.Hash().Hex())
 
 		return nil
-	},
+	}),
+}
+
+type sendResult struct {
+	Sender    common.Address      `json:"sender"`
+	Recipient common.Address      `json:"recipient"`
+	Amount    *big.Int            `json:"amount"`
+	Data      hexutil.Bytes       `json:"data,omitempty"`
+	TxHash    common.Hash         `json:"txHash"`
+	Receipt   *types.Receipt      `json:"receipt"`
+	Gas       *internal.GasReport `json:"gas,omitempty"`
 }
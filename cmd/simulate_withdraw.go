@@ -0,0 +1,355 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/Golem-Base/op-probe/bindings"
+	"github.com/Golem-Base/op-probe/internal"
+
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+var SimulateWithdrawCommand = &cli.Command{
+	Name:  "simulate-withdraw",
+	Usage: "Simulates proving and finalizing a withdrawal against the current L1 state with eth_call, without sending transactions",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "account",
+			Usage:    "Address that would prove and finalize the withdrawal",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "l1-rpc-url",
+			Usage:    "Url for L1 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "l2-rpc-url",
+			Usage:    "Url for L2 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "tx",
+			Usage:    "The L2 withdrawal transaction hash",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "dispute-game-factory-address",
+			Usage: "Contract address for DisputeGameFactory (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.StringFlag{
+			Name:  "optimism-portal-address",
+			Usage: "Contract address for OptimismPortal (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.StringFlag{
+			Name:  "proof-variant",
+			Usage: "Withdrawal proof to generate: fault-proofs, or withdrawals-root for Isthmus chains whose L2 account proofs do not verify against the state root",
+			Value: internal.ProofVariantFaultProofs,
+		},
+		&cli.BoolFlag{
+			Name:  "skip-clocks",
+			Usage: "Simulate the prove, the game resolution and the finalize in sequence with eth_simulateV1, advancing the L1 timestamp past the game clock, proof maturity and finality delays",
+		},
+	},
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := context.Background()
+
+		if err := internal.ValidateProofVariant(c.String("proof-variant")); err != nil {
+			return err
+		}
+
+		l1RpcUrl := c.String("l1-rpc-url")
+		l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
+		}
+
+		l2RpcUrl := c.String("l2-rpc-url")
+		l2Client, l2ChainId, err := internal.ConnectClient(ctx, c, l2RpcUrl, "l2-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l2RpcUrl, err)
+		}
+
+		if err := internal.ValidateChainIds(c, l1ChainId, l2ChainId); err != nil {
+			return err
+		}
+
+		account, err := internal.SafeParseAddress(c.String("account"))
+		if err != nil {
+			return fmt.Errorf("could not parse account: %w", err)
+		}
+		output.SetAccount(account)
+
+		withdrawalTxHash, err := internal.SafeParseHash(c.String("tx"))
+		if err != nil {
+			return fmt.Errorf("could not parse tx: %w", err)
+		}
+
+		disputeGameFactoryAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "dispute-game-factory-address")
+		if err != nil {
+			return fmt.Errorf("could not resolve DisputeGameFactory address: %w", err)
+		}
+		disputeGameFactory, err := opNodeBindings.NewDisputeGameFactory(disputeGameFactoryAddress, l1Client)
+		if err != nil {
+			return fmt.Errorf("could not instantiate DisputeGameFactory contract: %w", err)
+		}
+
+		optimismPortalAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "optimism-portal-address")
+		if err != nil {
+			return fmt.Errorf("could not resolve OptimismPortal address: %w", err)
+		}
+		optimismPortal, err := internal.NewOptimismPortal2(ctx, c, l1Client, optimismPortalAddress)
+		if err != nil {
+			return fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+		}
+
+		result := &simulateWithdrawResult{WithdrawalTxHash: withdrawalTxHash, SkipClocks: c.Bool("skip-clocks")}
+		output.Result = result
+
+		// Receipts are cached for the run so the proof generation reuses the withdrawal receipt fetched below
+		l2Receipts := internal.NewReceiptCache(l2Client, internal.DefaultReceiptCacheSize)
+
+		withdrawalTxReceipt, err := l2Receipts.TransactionReceipt(ctx, withdrawalTxHash)
+		if err != nil {
+			return fmt.Errorf("could not get receipt for withdrawal event in transaction: %s: %w", withdrawalTxHash.Hex(), err)
+		}
+		messagePassedEvent, err := withdrawals.ParseMessagePassed(withdrawalTxReceipt)
+		if err != nil {
+			return fmt.Errorf("could not parse the MessagePassed event from the withdrawal transaction hash")
+		}
+		result.WithdrawalHash = messagePassedEvent.WithdrawalHash
+
+		err = internal.CheckNotFinalized(&bind.CallOpts{Context: ctx}, &optimismPortal.OptimismPortal2Caller, messagePassedEvent.WithdrawalHash)
+		if errors.Is(err, internal.ErrAlreadyFinalized) {
+			log.Info("withdrawal has already been finalized, nothing to simulate", "withdrawal hash", common.Bytes2Hex(messagePassedEvent.WithdrawalHash[:]))
+			result.AlreadyFinalized = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		game, err := internal.FindLatestGameCovering(&bind.CallOpts{Context: ctx}, &disputeGameFactory.DisputeGameFactoryCaller, &optimismPortal.OptimismPortal2Caller, withdrawalTxReceipt.BlockNumber.Uint64())
+		if err != nil {
+			return fmt.Errorf("failed to find latest game: %w", err)
+		}
+		gameL2BlockNumber, err := internal.SearchResultL2BlockNumber(game)
+		if err != nil {
+			return err
+		}
+		if gameL2BlockNumber.Uint64() < withdrawalTxReceipt.BlockNumber.Uint64() {
+			return fmt.Errorf("%w, %d blocks remaining", internal.ErrGameNotProposed, withdrawalTxReceipt.BlockNumber.Uint64()-gameL2BlockNumber.Uint64())
+		}
+		result.DisputeGame = internal.SearchResultGameProxy(game)
+
+		params, err := internal.ProveWithdrawalParametersForGame(
+			ctx,
+			c.String("proof-variant"),
+			gethclient.New(l2Client.Client()),
+			l2Receipts,
+			l2Client,
+			withdrawalTxHash,
+			game,
+		)
+		if err != nil {
+			return fmt.Errorf("could not generate fault proofs for withdrawal: %w", err)
+		}
+
+		prove, finalize, err := withdrawalCalls(account, optimismPortalAddress, params)
+		if err != nil {
+			return err
+		}
+
+		if result.SkipClocks {
+			result.Calls, err = simulateWithdrawalSkippingClocks(ctx, l1Client, optimismPortal, account, result.DisputeGame, prove, finalize)
+			if err != nil {
+				return err
+			}
+		} else {
+			result.Calls = append(result.Calls, internal.SimulateCall(ctx, l1Client, prove))
+
+			// Without the prove applied, finalizing can only succeed for a withdrawal the account already proved
+			proven, err := optimismPortal.ProvenWithdrawals(&bind.CallOpts{Context: ctx}, messagePassedEvent.WithdrawalHash, account)
+			if err != nil {
+				return fmt.Errorf("could not fetch proven withdrawal: %w", err)
+			}
+			if proven.Timestamp != 0 {
+				result.Calls = append(result.Calls, internal.SimulateCall(ctx, l1Client, finalize))
+			} else {
+				log.Info("withdrawal has not been proven by the account yet, simulate with --skip-clocks to include the finalize")
+			}
+		}
+
+		var failed []string
+		for _, call := range result.Calls {
+			if call.Success {
+				log.Info("simulated call succeeded", "call", call.Name, "gasUsed", call.GasUsed)
+			} else {
+				log.Error("simulated call reverted", "call", call.Name, "reason", call.Reason)
+				failed = append(failed, fmt.Sprintf("%s would revert with %s", call.Name, call.Reason))
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("simulated withdrawal failed: %s", strings.Join(failed, "; "))
+		}
+
+		return nil
+	}),
+}
+
+type simulateWithdrawResult struct {
+	WithdrawalTxHash common.Hash                  `json:"withdrawalTxHash"`
+	WithdrawalHash   common.Hash                  `json:"withdrawalHash"`
+	DisputeGame      common.Address               `json:"disputeGame"`
+	SkipClocks       bool                         `json:"skipClocks"`
+	AlreadyFinalized bool                         `json:"alreadyFinalized,omitempty"`
+	Calls            []*internal.SimulationResult `json:"calls"`
+}
+
+// withdrawalCalls encodes the OptimismPortal prove and finalize calls of the withdrawal proven by params, sent from
+// account
+func withdrawalCalls(account common.Address, optimismPortalAddress common.Address, params withdrawals.ProvenWithdrawalParameters) (internal.SimulatedCall, internal.SimulatedCall, error) {
+	optimismPortalABI, err := bindingspreview.OptimismPortal2MetaData.GetAbi()
+	if err != nil {
+		return internal.SimulatedCall{}, internal.SimulatedCall{}, fmt.Errorf("could not get OptimismPortal abi: %w", err)
+	}
+
+	withdrawalTransaction := bindingspreview.TypesWithdrawalTransaction{
+		Nonce:    params.Nonce,
+		Sender:   params.Sender,
+		Target:   params.Target,
+		Value:    params.Value,
+		GasLimit: params.GasLimit,
+		Data:     params.Data,
+	}
+	proveData, err := optimismPortalABI.Pack(
+		"proveWithdrawalTransaction",
+		withdrawalTransaction,
+		params.L2OutputIndex,
+		bindingspreview.TypesOutputRootProof{
+			Version:                  params.OutputRootProof.Version,
+			StateRoot:                params.OutputRootProof.StateRoot,
+			MessagePasserStorageRoot: params.OutputRootProof.MessagePasserStorageRoot,
+			LatestBlockhash:          params.OutputRootProof.LatestBlockhash,
+		},
+		params.WithdrawalProof,
+	)
+	if err != nil {
+		return internal.SimulatedCall{}, internal.SimulatedCall{}, fmt.Errorf("could not encode OptimismPortal.ProveWithdrawalTransaction call: %w", err)
+	}
+	finalizeData, err := optimismPortalABI.Pack("finalizeWithdrawalTransaction", withdrawalTransaction)
+	if err != nil {
+		return internal.SimulatedCall{}, internal.SimulatedCall{}, fmt.Errorf("could not encode OptimismPortal.FinalizeWithdrawalTransaction call: %w", err)
+	}
+
+	prove := internal.SimulatedCall{Name: "OptimismPortal.ProveWithdrawalTransaction", From: account, To: optimismPortalAddress, Data: proveData}
+	finalize := internal.SimulatedCall{Name: "OptimismPortal.FinalizeWithdrawalTransaction", From: account, To: optimismPortalAddress, Data: finalizeData}
+	return prove, finalize, nil
+}
+
+// simulateWithdrawalSkippingClocks simulates the prove in the next block, the resolution of the dispute game once its
+// clock has expired and the proof would have matured, and the finalize once the finality delay has passed. Claims and
+// games that are already resolved are not resolved again.
+func simulateWithdrawalSkippingClocks(ctx context.Context, l1Client *ethclient.Client, optimismPortal *bindingspreview.OptimismPortal2, account common.Address, gameProxy common.Address, prove internal.SimulatedCall, finalize internal.SimulatedCall) ([]*internal.SimulationResult, error) {
+	opts := &bind.CallOpts{Context: ctx}
+
+	head, err := l1Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch L1 head: %w", err)
+	}
+	proofMaturityDelaySeconds, err := optimismPortal.ProofMaturityDelaySeconds(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not call OptimismPortal.ProofMaturityDelaySeconds: %w", err)
+	}
+	finalityDelaySeconds, err := optimismPortal.DisputeGameFinalityDelaySeconds(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not call OptimismPortal.DisputeGameFinalityDelaySeconds: %w", err)
+	}
+
+	permissionedDisputeGame, err := bindings.NewPermissionedDisputeGame(gameProxy, l1Client)
+	if err != nil {
+		return nil, fmt.Errorf("could not construct permissioned dispute game")
+	}
+	createdAt, err := permissionedDisputeGame.CreatedAt(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch DisputeGame.CreatedAt: %w", err)
+	}
+	maxClockDuration, err := permissionedDisputeGame.MaxClockDuration(opts)
+	if err != nil {
+		return nil, fmt.Errorf("PermissionedDisputeGame.MaxClockDuration failed: %w", err)
+	}
+	resolveCalls, err := gameResolutionCalls(opts, permissionedDisputeGame, account, gameProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	// The portal requires the delays to have strictly passed, hence the extra second on every step
+	proveTime := head.Time + 1
+	resolveTime := max(proveTime+proofMaturityDelaySeconds.Uint64(), createdAt+maxClockDuration) + 1
+	finalizeTime := resolveTime + finalityDelaySeconds.Uint64() + 1
+	log.Info("simulating withdrawal with advanced L1 timestamps", "proveTime", proveTime, "resolveTime", resolveTime, "finalizeTime", finalizeTime)
+
+	return internal.SimulateBlocks(ctx, l1Client, []internal.SimulatedBlock{
+		{Time: proveTime, Calls: []internal.SimulatedCall{prove}},
+		{Time: resolveTime, Calls: resolveCalls},
+		{Time: finalizeTime, Calls: []internal.SimulatedCall{finalize}},
+	})
+}
+
+// gameResolutionCalls encodes the calls resolving the claims of the game that are not resolved yet, children before
+// their parents, followed by the resolution of the game itself. A game that is no longer in progress needs none.
+func gameResolutionCalls(opts *bind.CallOpts, game *bindings.PermissionedDisputeGame, account common.Address, gameProxy common.Address) ([]internal.SimulatedCall, error) {
+	status, err := game.Status(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch DisputeGame.Status: %w", err)
+	}
+	if internal.GameStatus(status) != internal.GameStatusInProgress {
+		return nil, nil
+	}
+
+	gameABI, err := bindings.PermissionedDisputeGameMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("could not get PermissionedDisputeGame abi: %w", err)
+	}
+	claimCount, err := game.ClaimDataLen(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch DisputeGame.ClaimDataLen: %w", err)
+	}
+
+	var calls []internal.SimulatedCall
+	// Claims are appended after their parents, so walking them backwards resolves children first
+	for i := claimCount.Int64() - 1; i >= 0; i-- {
+		index := big.NewInt(i)
+		resolved, err := game.ResolvedSubgames(opts, index)
+		if err != nil {
+			return nil, fmt.Errorf("PermissionedDisputeGame.ResolvedSubgame failed: %w", err)
+		}
+		if resolved {
+			continue
+		}
+		data, err := gameABI.Pack("resolveClaim", index, common.Big0)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode PermissionedDisputeGame.ResolveClaim call: %w", err)
+		}
+		calls = append(calls, internal.SimulatedCall{Name: fmt.Sprintf("PermissionedDisputeGame.ResolveClaim(%d)", i), From: account, To: gameProxy, Data: data})
+	}
+
+	data, err := gameABI.Pack("resolve")
+	if err != nil {
+		return nil, fmt.Errorf("could not encode PermissionedDisputeGame.Resolve call: %w", err)
+	}
+	calls = append(calls, internal.SimulatedCall{Name: "PermissionedDisputeGame.Resolve", From: account, To: gameProxy, Data: data})
+	return calls, nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Golem-Base/op-probe/internal"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+var SubmitRawCommand = &cli.Command{
+	Name:  "submit-raw",
+	Usage: "Broadcasts a pre-signed raw transaction, such as one built with --build-only and signed offline, and waits for its receipt",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "rpc-url",
+			Usage:    "Url for exection client",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "signed-tx",
+			Usage:    "The signed transaction, RLP encoded as 0x prefixed hex",
+			Required: true,
+		},
+	},
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := context.Background()
+
+		rawTx, err := hexutil.Decode(c.String("signed-tx"))
+		if err != nil {
+			return fmt.Errorf("could not decode signed-tx: %w", err)
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(rawTx); err != nil {
+			return fmt.Errorf("could not parse signed-tx: %w", err)
+		}
+
+		rpcUrl := c.String("rpc-url")
+		client, chainId, err := internal.ConnectClient(ctx, c, rpcUrl, "chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", rpcUrl, err)
+		}
+
+		if tx.Protected() && tx.ChainId().Cmp(chainId) != 0 {
+			return fmt.Errorf("signed-tx is for chain id %s but the client at %s is on chain id %s", tx.ChainId(), rpcUrl, chainId)
+		}
+
+		sender, err := types.Sender(types.LatestSignerForChainID(chainId), tx)
+		if err != nil {
+			return fmt.Errorf("could not recover the sender of signed-tx: %w", err)
+		}
+
+		result := &submitRawResult{Sender: sender, TxHash: tx.Hash()}
+		output.Result = result
+		output.SetAccount(sender)
+		output.Amount = tx.Value()
+
+		log.Info("broadcasting signed transaction", "sender", sender, "to", tx.To(), "nonce", tx.Nonce(), "tx", tx.Hash().Hex())
+
+		if err := client.SendTransaction(ctx, tx); err != nil {
+			return fmt.Errorf("could not broadcast signed-tx: %w", err)
+		}
+
+		receipt, err := internal.WaitForReceipt(ctx, client, "submit-raw", tx.Hash())
+		if err != nil {
+			return err
+		}
+		result.Receipt = receipt
+		output.Primary = receipt.TxHash
+		output.AddTx(receipt.TxHash)
+		result.Gas = internal.NewGasReport(receipt)
+
+		log.Info("successfully submitted transaction", "tx", receipt.TxHash.Hex())
+
+		return nil
+	}),
+}
+
+type submitRawResult struct {
+	Sender  common.Address      `json:"sender"`
+	TxHash  common.Hash         `json:"txHash"`
+	Receipt *types.Receipt      `json:"receipt"`
+	Gas     *internal.GasReport `json:"gas,omitempty"`
+}
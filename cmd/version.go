@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+var VersionCommand = &cli.Command{
+	Name:  "version",
+	Usage: "Prints the build information of the probe",
+	Action: func(c *cli.Context) error {
+		fmt.Printf("version: %v\n", c.App.Metadata["version"])
+		fmt.Printf("commit: %v\n", c.App.Metadata["commit"])
+		fmt.Printf("build date: %v\n", c.App.Metadata["date"])
+		return nil
+	},
+}
@@ -14,9 +14,13 @@ var WithdrawCommand = &cli.Command{
 		withdraw_cmd.InitCommand,
 		withdraw_cmd.ProveCommand,
 		withdraw_cmd.FinalizeCommand,
+		withdraw_cmd.ProveAndFinalizeCommand,
+		withdraw_cmd.WatchCommand,
+		withdraw_cmd.ResolveGameCommand,
+		withdraw_cmd.DiagnoseCommand,
 	},
 	Action: func(cCtx *cli.Context) error {
-		fmt.Println("Withdraw command requires a subcommand: list, init, prove, or finalize")
+		fmt.Println("Withdraw command requires a subcommand: list, init, prove, finalize, prove-and-finalize, watch, resolve-game, or diagnose")
 		cli.ShowSubcommandHelp(cCtx)
 		return nil
 	},
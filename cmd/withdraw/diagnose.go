@@ -0,0 +1,149 @@
+package withdraw_cmd
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/Golem-Base/op-probe/internal"
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+var DiagnoseCommand = &cli.Command{
+	Name:  "diagnose",
+	Usage: "Compares the output root computed from the L2 node for the L2 block of a dispute game with the root claimed by the game, the usual cause of proofs that revert",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "l1-rpc-url",
+			Usage:    "Url for L1 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "l2-rpc-url",
+			Usage:    "Url for L2 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "dispute-game-factory-address",
+			Usage: "Contract address for DisputeGameFactory (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.StringFlag{
+			Name:  "optimism-portal-address",
+			Usage: "Contract address for OptimismPortal (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.Uint64Flag{
+			Name:  "game-index",
+			Usage: "Index of the dispute game to check (default: latest game of the respected game type)",
+		},
+	},
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := context.Background()
+
+		l1RpcUrl := c.String("l1-rpc-url")
+		l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
+		}
+
+		l2RpcUrl := c.String("l2-rpc-url")
+		l2Client, l2ChainId, err := internal.ConnectClient(ctx, c, l2RpcUrl, "l2-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l2RpcUrl, err)
+		}
+
+		if err := internal.ValidateChainIds(c, l1ChainId, l2ChainId); err != nil {
+			return err
+		}
+
+		disputeGameFactoryAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "dispute-game-factory-address")
+		if err != nil {
+			return fmt.Errorf("could not resolve DisputeGameFactory address: %w", err)
+		}
+		disputeGameFactory, err := opNodeBindings.NewDisputeGameFactory(disputeGameFactoryAddress, l1Client)
+		if err != nil {
+			return fmt.Errorf("could not instantiate DisputeGameFactory contract: %w", err)
+		}
+
+		optimismPortalAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "optimism-portal-address")
+		if err != nil {
+			return fmt.Errorf("could not resolve OptimismPortal address: %w", err)
+		}
+		optimismPortal, err := internal.NewOptimismPortal2(ctx, c, l1Client, optimismPortalAddress)
+		if err != nil {
+			return fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+		}
+
+		readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+		defer cancel()
+
+		var game *opNodeBindings.IDisputeGameFactoryGameSearchResult
+		if c.IsSet("game-index") {
+			game, err = internal.GameAtIndex(&bind.CallOpts{Context: readCtx}, &disputeGameFactory.DisputeGameFactoryCaller, l1Client, new(big.Int).SetUint64(c.Uint64("game-index")))
+		} else {
+			game, err = internal.FindLatestGame(&bind.CallOpts{Context: readCtx}, &disputeGameFactory.DisputeGameFactoryCaller, &optimismPortal.OptimismPortal2Caller)
+		}
+		if err != nil {
+			return err
+		}
+
+		l2BlockNumber, err := internal.SearchResultL2BlockNumber(game)
+		if err != nil {
+			return err
+		}
+
+		computed, err := internal.ComputeOutputRoot(readCtx, gethclient.New(l2Client.Client()), l2Client, l2BlockNumber)
+		if err != nil {
+			return err
+		}
+
+		result := &diagnoseResult{
+			GameIndex:   game.Index,
+			DisputeGame: internal.SearchResultGameProxy(game),
+			L2Block:     l2BlockNumber,
+			RootClaim:   game.RootClaim,
+			Computed:    computed,
+			Match:       computed.OutputRoot == common.Hash(game.RootClaim),
+		}
+		output.Result = result
+		output.Primary = result.Match
+
+		// A withdrawals root differing from the account storage root points at an L2 node whose account proofs
+		// cannot be trusted, which the withdrawals-root proof variant avoids
+		if computed.WithdrawalsRoot != nil && *computed.WithdrawalsRoot != computed.MessagePasserStorageRoot {
+			log.Warn("withdrawals root of the L2 block header differs from the L2ToL1MessagePasser storage root of the L2 node, try --proof-variant withdrawals-root",
+				"withdrawalsRoot", computed.WithdrawalsRoot.Hex(),
+				"messagePasserStorageRoot", computed.MessagePasserStorageRoot.Hex(),
+			)
+		}
+
+		if !result.Match {
+			log.Error("output root mismatch, proofs against this game built from this L2 node revert",
+				"game", game.Index,
+				"l2Block", l2BlockNumber,
+				"rootClaim", common.Hash(game.RootClaim).Hex(),
+				"computedOutputRoot", computed.OutputRoot.Hex(),
+				"stateRoot", computed.StateRoot.Hex(),
+				"messagePasserStorageRoot", computed.MessagePasserStorageRoot.Hex(),
+				"blockHash", computed.BlockHash.Hex(),
+			)
+			return fmt.Errorf("%w: game %d at L2 block %d", internal.ErrOutputRootMismatch, game.Index, l2BlockNumber)
+		}
+
+		log.Info("output root matches the root claim of the game", "game", game.Index, "l2Block", l2BlockNumber, "outputRoot", computed.OutputRoot.Hex())
+		return nil
+	}),
+}
+
+type diagnoseResult struct {
+	GameIndex   *big.Int                 `json:"gameIndex"`
+	DisputeGame common.Address           `json:"disputeGame"`
+	L2Block     *big.Int                 `json:"l2Block"`
+	RootClaim   common.Hash              `json:"rootClaim"`
+	Computed    *internal.ComputedOutput `json:"computed"`
+	Match       bool                     `json:"match"`
+}
@@ -2,339 +2,972 @@ package withdraw_cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/big"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/Golem-Base/op-probe/bindings"
 	"github.com/Golem-Base/op-probe/internal"
+	e2eBindings "github.com/ethereum-optimism/optimism/op-e2e/bindings"
+	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/receipts"
 	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/transactions"
-	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/wait"
 	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
 	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
-	opNodePreviewBindings "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
 	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/ethclient/gethclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/urfave/cli/v2"
 )
 
-// TODO The `resolveClaim()` and `resolve()` functionality would be called by the challenger service so	it may be advisable to add a flag to wait for the challenger
 var FinalizeCommand = &cli.Command{
 	Name:  "finalize",
-	Usage: "Finalizes a withdrawal transaction, assumes the private-key is the prover",
+	Usage: "Finalizes one or more withdrawal transactions, proven by the sender unless --prover is set",
 	Flags: []cli.Flag{
 		&cli.StringFlag{
-			Name:     "private-key",
-			Usage:    "Private key of address to send test transaction from",
-			Required: true,
+			Name:  "private-key",
+			Usage: "Private key of address to send test transaction from, or - to read it from stdin, required unless --build-only is set. It signs and pays for the transactions, and is the prover of the withdrawals unless --prover is set",
+		},
+		&cli.StringFlag{
+			Name:  "prover",
+			Usage: "Address that proved the withdrawals when it is not the sender, whose proofs are checked and finalized through OptimismPortal.finalizeWithdrawalTransactionExternalProof so that another account can pay for the finalization (default: the sender)",
 		},
 		&cli.StringFlag{
 			Name:     "l1-rpc-url",
-			Usage:    "Url for L1 execution client",
+			Usage:    "Url for L1 execution client, or comma separated urls to fail over between",
 			Required: true,
 		},
 		&cli.StringFlag{
 			Name:     "l2-rpc-url",
-			Usage:    "Url for L2 execution client",
+			Usage:    "Url for L2 execution client, or comma separated urls to fail over between",
 			Required: true,
 		},
+		&cli.StringSliceFlag{
+			Name:  "tx",
+			Usage: "The L2 withdrawal transaction hash, may be repeated to finalize several withdrawals",
+		},
 		&cli.StringFlag{
-			Name:     "tx",
-			Usage:    "The L2 withdrawal transaction hash",
-			Required: true,
+			Name:  "tx-file",
+			Usage: "Path to a file containing L2 withdrawal transaction hashes, one per line",
+		},
+		&cli.StringSliceFlag{
+			Name:  "withdrawal-hash",
+			Usage: "The withdrawal hash, as reported by init, to finalize in place of --tx, may be repeated",
+		},
+		&cli.Uint64Flag{
+			Name:  "from-block",
+			Usage: "L2 block to start scanning for the transactions of --withdrawal-hash from, such as a block shortly before the withdrawals were initiated",
 		},
 		&cli.StringFlag{
-			Name:     "dispute-game-factory-address",
-			Usage:    "Contract address for DisputeGameFactory (* or proxy)",
-			Required: true,
+			Name:  "dispute-game-factory-address",
+			Usage: "Contract address for DisputeGameFactory (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
 		},
 		&cli.StringFlag{
-			Name:     "optimism-portal-address",
-			Usage:    "Contract address for OptimismPortal (* or proxy)",
-			Required: true,
+			Name:  "l2-output-oracle-address",
+			Usage: "Contract address for the L2OutputOracle (* or proxy) of a chain without fault proofs, finalizing through the legacy OptimismPortal once its finalization period has passed",
+		},
+		&cli.StringFlag{
+			Name:  "optimism-portal-address",
+			Usage: "Contract address for OptimismPortal (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.BoolFlag{
+			Name:  "skip-game-resolution",
+			Usage: "Do not send ResolveClaim or Resolve transactions, only finalize withdrawals whose dispute game is already resolved",
+		},
+		&cli.BoolFlag{
+			Name:  "simulate-resolve",
+			Usage: "Simulate ResolveClaim and Resolve with eth_call before sending them, failing the withdrawal with the decoded revert reason instead of sending a transaction that would revert",
+			Value: true,
+		},
+		&cli.BoolFlag{
+			Name:  "wait-for-challenger",
+			Usage: "Poll until the challenger service resolves the dispute game instead of sending ResolveClaim or Resolve transactions or exiting early",
+		},
+		&cli.DurationFlag{
+			Name:  "poll-interval",
+			Usage: "Interval between checks of the dispute game with --wait-for-challenger",
+			Value: 12 * time.Second,
+		},
+		&cli.DurationFlag{
+			Name:  "max-poll-interval",
+			Usage: "Back off exponentially from poll-interval up to this interval between checks with --wait-for-challenger, 0 polls at a fixed interval",
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "Maximum time to wait for the dispute game of each withdrawal to be resolved with --wait-for-challenger",
+			Value: time.Hour,
+		},
+		&cli.StringFlag{
+			Name:  "dump-proof",
+			Usage: "Directory to write the generated withdrawal proof parameters to as JSON before submitting, for debugging reverts",
+		},
+		&cli.BoolFlag{
+			Name:  "fee-recipient-check",
+			Usage: "Fail when the recipient of a finalized withdrawal was credited less on L1 than the withdrawal pays out",
+		},
+		&cli.StringFlag{
+			Name:  "time-source",
+			Usage: "Clock the proof maturity and finality delays are compared against: wall for the local clock, or chain for the timestamp of the latest L1 block on dev chains with skewed or fast-forwarded time",
+			Value: internal.TimeSourceWall,
+		},
+		&cli.BoolFlag{
+			Name:  "strict",
+			Usage: "Exit with an error when a withdrawal is not ready to be finalized yet instead of skipping it",
+		},
+		&cli.Uint64Flag{
+			Name:  "nonce",
+			Usage: "Nonce of the first transaction sent (default: pending nonce of the sender)",
+		},
+		&cli.BoolFlag{
+			Name:  "build-only",
+			Usage: "Print the unsigned transaction as JSON instead of signing and sending it, to be signed offline and broadcast with submit-raw",
+		},
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "Address the transaction is built for with --build-only, in place of --private-key",
 		},
 	},
-	Action: func(c *cli.Context) error {
-		ctx := context.Background()
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := c.Context
 
-		privateKey, err := crypto.HexToECDSA(c.String("private-key"))
+		account, privateKey, err := internal.SenderAccount(c)
 		if err != nil {
-			return fmt.Errorf("failed to parse private-key: %w", err)
+			return err
 		}
+		output.SetAccount(account)
 
-		account := crypto.PubkeyToAddress(privateKey.PublicKey)
+		if err := internal.ValidateTimeSource(c.String("time-source")); err != nil {
+			return err
+		}
+		useOracle, err := internal.UseL2OutputOracle(c)
+		if err != nil {
+			return err
+		}
+
+		prover, err := proverAccount(c, account, useOracle)
+		if err != nil {
+			return err
+		}
 
 		l1RpcUrl := c.String("l1-rpc-url")
-		l1Client, l1ChainId, err := internal.ConnectClient(ctx, l1RpcUrl)
+		l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
 		if err != nil {
 			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
 		}
 
 		l2RpcUrl := c.String("l2-rpc-url")
-		l2Client, _, err := internal.ConnectClient(ctx, l2RpcUrl)
+		l2Client, l2ChainId, err := internal.ConnectClient(ctx, c, l2RpcUrl, "l2-chain-id")
 		if err != nil {
 			return fmt.Errorf("could not connect to client at %s: %w", l2RpcUrl, err)
 		}
 
-		preBalance, err := l1Client.BalanceAt(ctx, account, nil)
-		if err != nil {
-			return fmt.Errorf("could not fetch balance: %w", err)
+		if err := internal.ValidateChainIds(c, l1ChainId, l2ChainId); err != nil {
+			return err
 		}
 
-		withdrawalTxHash := common.HexToHash(c.String("tx"))
-
-		disputeGameFactoryAddress, err := internal.SafeParseAddress(c.String("dispute-game-factory-address"))
-		if err != nil {
-			return fmt.Errorf("could not parse DisputeGameFactory address: %w", err)
+		var withdrawalTxHashes []common.Hash
+		if c.IsSet("withdrawal-hash") {
+			if c.IsSet("tx") || c.IsSet("tx-file") {
+				return fmt.Errorf("withdrawal-hash cannot be combined with tx or tx-file")
+			}
+			withdrawalTxHashes, err = lookupWithdrawalTxHashes(ctx, c, l2Client)
+		} else if c.IsSet("from-block") {
+			return fmt.Errorf("from-block requires withdrawal-hash")
+		} else {
+			withdrawalTxHashes, err = readWithdrawalTxHashes(c)
 		}
-		disputeGameFactory, err := opNodeBindings.NewDisputeGameFactory(disputeGameFactoryAddress, l1Client)
 		if err != nil {
-			return fmt.Errorf("could not instantiate DisputeGameFactory contract: %w", err)
+			return err
 		}
 
-		optimismPortalAddress, err := internal.SafeParseAddress(c.String("optimism-portal-address"))
-		if err != nil {
-			return fmt.Errorf("could not parse OptimismPortal address: %w", err)
+		// Chains without fault proofs finalize through the legacy portal, which only waits out the finalization period
+		// of the L2OutputOracle
+		var w *Withdrawer
+		var l2OutputOracle *opNodeBindings.L2OutputOracle
+		var legacyPortal *opNodeBindings.OptimismPortal
+		if useOracle {
+			optimismPortalAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "optimism-portal-address")
+			if err != nil {
+				return fmt.Errorf("could not resolve OptimismPortal address: %w", err)
+			}
+			l2OutputOracle, legacyPortal, err = newOracleContracts(ctx, c, l1Client, optimismPortalAddress)
+			if err != nil {
+				return err
+			}
+			w, err = NewWithdrawer(l1Client, l2Client, l1ChainId, l2ChainId, internal.ZeroAddress, internal.ZeroAddress)
+		} else {
+			w, err = newFaultProofWithdrawer(ctx, c, l1Client, l2Client, l1ChainId, l2ChainId)
 		}
-		optimismPortal, err := opNodePreviewBindings.NewOptimismPortal2(optimismPortalAddress, l1Client)
 		if err != nil {
-			return fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+			return err
 		}
 
-		withdrawalTxReceipt, err := l2Client.TransactionReceipt(ctx, withdrawalTxHash)
+		buildOnly := c.Bool("build-only")
+		var opts *bind.TransactOpts
+		if buildOnly {
+			opts, err = internal.NewUnsignedTransactor(ctx, c, l1Client, account)
+		} else {
+			opts, err = internal.NewTransactor(ctx, c, l1Client, privateKey, l1ChainId)
+		}
 		if err != nil {
-			return fmt.Errorf("could not get receipt for withdrawal event in transaction: %s: %w", withdrawalTxHash.Hex(), err)
+			return err
 		}
 
-		messagePassedEvent, err := withdrawals.ParseMessagePassed(withdrawalTxReceipt)
-		if err != nil {
-			return fmt.Errorf("could not parse the MessagePassed event from the withdrawal transaction hash")
+		f := w.newFinalizer(opts, FinalizeConfig{
+			Prover: prover,
+			// Resolve transactions would need signing as well, so with --build-only the game must already be resolved
+			SkipGameResolution: c.Bool("skip-game-resolution") || buildOnly,
+			BuildOnly:          buildOnly,
+			SimulateResolve:    c.Bool("simulate-resolve"),
+			WaitForChallenger:  c.Bool("wait-for-challenger"),
+			PollInterval:       c.Duration("poll-interval"),
+			MaxPollInterval:    c.Duration("max-poll-interval"),
+			ChallengerTimeout:  c.Duration("timeout"),
+			DumpProofDir:       c.String("dump-proof"),
+			CheckRecipient:     c.Bool("fee-recipient-check"),
+			TimeSource:         c.String("time-source"),
+		})
+		f.l2OutputOracle = l2OutputOracle
+		f.legacyPortal = legacyPortal
+
+		results := make([]*finalizeResult, 0, len(withdrawalTxHashes))
+		output.Result = &results
+		var errs, notReady []error
+		for _, withdrawalTxHash := range withdrawalTxHashes {
+			log.Info("processing withdrawal", "tx", withdrawalTxHash.Hex())
+
+			result := &finalizeResult{TxHash: withdrawalTxHash, Outcome: "skipped", GasSpent: new(big.Int)}
+			err := f.finalizeWithdrawal(ctx, result)
+			if errors.Is(err, internal.ErrAlreadyFinalized) {
+				result.Outcome = "already-finalized"
+			} else if internal.IsRetryable(err) {
+				// Withdrawals that are not ready yet are left for a later run without failing the command, unless --strict is set
+				log.Info("withdrawal is not ready to be finalized yet", "tx", withdrawalTxHash.Hex(), "reason", err)
+				result.Error = err.Error()
+				notReady = append(notReady, fmt.Errorf("withdrawal %s: %w", withdrawalTxHash.Hex(), err))
+			} else if err != nil {
+				log.Error("failed to finalize withdrawal, continuing with the remaining withdrawals", "tx", withdrawalTxHash.Hex(), "error", err)
+				result.Outcome = "failed"
+				result.Error = err.Error()
+				errs = append(errs, fmt.Errorf("withdrawal %s: %w", withdrawalTxHash.Hex(), err))
+				if errors.Is(err, internal.ErrSenderBalanceLow) {
+					log.Error("sender balance is below min-balance, not finalizing the remaining withdrawals")
+					results = append(results, result)
+					break
+				}
+			} else if result.Receipt != nil {
+				result.Outcome = "finalized"
+			} else if result.UnsignedTx != nil {
+				result.Outcome = "built"
+			}
+			results = append(results, result)
 		}
-		proven, err := optimismPortal.ProvenWithdrawals(
-			&bind.CallOpts{},
-			messagePassedEvent.WithdrawalHash,
-			account,
-		)
-		if err != nil {
-			return fmt.Errorf("could not fetch proven withdrawal: %w", err)
+
+		finalizedTxHashes := make([]common.Hash, 0, len(results))
+		for _, result := range results {
+			if result.Receipt != nil {
+				finalizedTxHashes = append(finalizedTxHashes, result.Receipt.TxHash)
+			}
+			if result.Error != "" {
+				log.Info("withdrawal summary", "tx", result.TxHash.Hex(), "result", result.Outcome, "error", result.Error)
+			} else {
+				log.Info("withdrawal summary", "tx", result.TxHash.Hex(), "result", result.Outcome)
+			}
 		}
-		provenTimestamp := time.Unix(int64(proven.Timestamp), 0)
-		if proven.Timestamp == 0 {
-			return fmt.Errorf("withdrawal has not been previously proven")
+		if !buildOnly {
+			output.Primary = finalizedTxHashes
+			output.TxHashes = finalizedTxHashes
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%d of %d withdrawals failed to finalize: %w", len(errs), len(results), errors.Join(errs...))
+		}
+		// With --strict a pipeline can tell withdrawals left for a later run from finalized ones by the exit code
+		if c.Bool("strict") && len(notReady) > 0 {
+			return fmt.Errorf("%d of %d withdrawals are not ready to be finalized: %w", len(notReady), len(results), errors.Join(notReady...))
 		}
 
-		log.Info("withdrawal has been proven",
-			"proved_at", time.Unix(int64(proven.Timestamp), 0),
-		)
-
-		opts, err := bind.NewKeyedTransactorWithChainID(privateKey, l1ChainId)
-		if err != nil {
-			return fmt.Errorf("could not setup transactor: %w", err)
+		if buildOnly {
+			unsignedTxs := make([]*internal.UnsignedTransaction, 0, len(results))
+			for _, result := range results {
+				if result.UnsignedTx != nil {
+					unsignedTxs = append(unsignedTxs, result.UnsignedTx)
+				}
+			}
+			return internal.PrintJSON(unsignedTxs)
 		}
 
-		permissionedDisputeGame, err := bindings.NewPermissionedDisputeGame(proven.DisputeGameProxy, l1Client)
+		return nil
+	}),
+}
+
+// FinalizeConfig holds the settings of Withdrawer.Finalize
+type FinalizeConfig struct {
+	// Prover is the account whose proofs are finalized, the sender when zero
+	Prover common.Address
+	// SkipGameResolution only finalizes withdrawals whose dispute game is already resolved, without sending ResolveClaim
+	// or Resolve transactions
+	SkipGameResolution bool
+	// BuildOnly sets the unsigned finalize transaction on the result instead of sending it
+	BuildOnly bool
+	// SimulateResolve simulates resolve transactions with eth_call before sending them
+	SimulateResolve bool
+	// WaitForChallenger polls until the challenger service resolves the dispute game, for up to ChallengerTimeout
+	WaitForChallenger bool
+	PollInterval      time.Duration
+	MaxPollInterval   time.Duration
+	ChallengerTimeout time.Duration
+	// DumpProofDir is the directory the proof of the withdrawal is written to when set
+	DumpProofDir string
+	// CheckRecipient fails when the recipient was credited less on L1 than the withdrawal pays out
+	CheckRecipient bool
+	// TimeSource is the clock the proof maturity and finality delays are compared against
+	TimeSource string
+}
+
+// finalizer holds the sender and settings shared across every withdrawal finalized in a single invocation
+type finalizer struct {
+	*Withdrawer
+	FinalizeConfig
+	account common.Address
+	opts    *bind.TransactOpts
+	// l2OutputOracle and legacyPortal are set instead of disputeGameFactory and optimismPortal with
+	// --l2-output-oracle-address
+	l2OutputOracle *opNodeBindings.L2OutputOracle
+	legacyPortal   *opNodeBindings.OptimismPortal
+}
+
+// newFinalizer returns a finalizer sending from the account of opts
+func (w *Withdrawer) newFinalizer(opts *bind.TransactOpts, cfg FinalizeConfig) *finalizer {
+	if cfg.Prover == internal.ZeroAddress {
+		cfg.Prover = opts.From
+	}
+	if cfg.TimeSource == "" {
+		cfg.TimeSource = internal.TimeSourceWall
+	}
+	return &finalizer{Withdrawer: w, FinalizeConfig: cfg, account: opts.From, opts: opts}
+}
+
+// Finalize advances the withdrawal initiated in result.TxHash as far as possible against the fault proof contracts of
+// w, sending from the account of opts. The finalize transaction receipt is set on result only when the withdrawal was
+// finalized by this call.
+func (w *Withdrawer) Finalize(ctx context.Context, opts *bind.TransactOpts, cfg FinalizeConfig, result *finalizeResult) error {
+	if w.optimismPortal == nil {
+		return fmt.Errorf("withdrawer has no OptimismPortal to finalize withdrawals through")
+	}
+	if result.GasSpent == nil {
+		result.GasSpent = new(big.Int)
+	}
+	return w.newFinalizer(opts, cfg).finalizeWithdrawal(ctx, result)
+}
+
+type finalizeResult struct {
+	TxHash      common.Hash                   `json:"txHash"`
+	Outcome     string                        `json:"outcome"`
+	Error       string                        `json:"error,omitempty"`
+	UnsignedTx  *internal.UnsignedTransaction `json:"unsignedTx,omitempty"`
+	L1Token     *common.Address               `json:"l1Token,omitempty"`
+	Recipient   common.Address                `json:"recipient"`
+	Expected    *big.Int                      `json:"expected"`
+	Receipt     *types.Receipt                `json:"receipt,omitempty"`
+	Gas         *internal.GasReport           `json:"gas,omitempty"`
+	PreBalance  *big.Int                      `json:"preBalance,omitempty"`
+	PostBalance *big.Int                      `json:"postBalance,omitempty"`
+	GasSpent    *big.Int                      `json:"gasSpent"`
+	Credited    *big.Int                      `json:"credited,omitempty"`
+}
+
+// finalizeWithdrawal advances the withdrawal initiated in result.TxHash as far as possible, setting the finalize
+// transaction receipt on result only when the withdrawal was finalized by this call. With --build-only the unsigned
+// finalize transaction is set instead.
+func (f *finalizer) finalizeWithdrawal(ctx context.Context, result *finalizeResult) error {
+	withdrawalTxHash := result.TxHash
+
+	// Reads share a deadline, transaction confirmations are only bounded by the command context
+	readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+
+	withdrawalTxReceipt, err := f.l2Receipts.TransactionReceipt(readCtx, withdrawalTxHash)
+	if err != nil {
+		return fmt.Errorf("could not get receipt for withdrawal event in transaction: %s: %w", withdrawalTxHash.Hex(), err)
+	}
+
+	asset, err := f.withdrawnAsset(readCtx, withdrawalTxReceipt)
+	if err != nil {
+		return err
+	}
+	if asset.token != nil {
+		result.L1Token = &asset.address
+	}
+	result.Recipient = asset.holder
+	result.Expected = asset.amount
+
+	preBalance, err := asset.balance(readCtx, f.l1Client)
+	if err != nil {
+		return fmt.Errorf("could not fetch balance: %w", err)
+	}
+	result.PreBalance = preBalance
+
+	messagePassedEvent, err := withdrawals.ParseMessagePassed(withdrawalTxReceipt)
+	if err != nil {
+		return fmt.Errorf("could not parse the MessagePassed event from the withdrawal transaction hash")
+	}
+	if f.l2OutputOracle != nil {
+		finalize, err := f.oracleFinalizeTx(readCtx, messagePassedEvent)
 		if err != nil {
-			return fmt.Errorf("could not construct permissioned dispute game")
+			return err
+		}
+		return f.submitFinalize(ctx, result, asset, preBalance, finalize)
+	}
+	// An already finalized withdrawal is reported before any resolve transaction could be sent for it
+	if err := internal.CheckNotFinalized(&bind.CallOpts{Context: readCtx}, &f.optimismPortal.OptimismPortal2Caller, messagePassedEvent.WithdrawalHash); err != nil {
+		if errors.Is(err, internal.ErrAlreadyFinalized) {
+			log.Info("withdrawal proof has already been finalized, exiting...", "withdrawal hash", common.Bytes2Hex(messagePassedEvent.WithdrawalHash[:]))
 		}
-		_maxClockDuration, err := permissionedDisputeGame.MaxClockDuration(&bind.CallOpts{})
+		return err
+	}
+	log.Info("withdrawal proof has not been finalized, continuing...")
+
+	proven, err := f.provenWithdrawal(&bind.CallOpts{Context: readCtx}, messagePassedEvent.WithdrawalHash)
+	if err != nil {
+		return err
+	}
+	provenTimestamp := time.Unix(int64(proven.Timestamp), 0)
+
+	log.Info("withdrawal has been proven",
+		"proved_at", time.Unix(int64(proven.Timestamp), 0),
+	)
+
+	permissionedDisputeGame, err := bindings.NewPermissionedDisputeGame(proven.DisputeGameProxy, f.l1Client)
+	if err != nil {
+		return fmt.Errorf("could not construct permissioned dispute game")
+	}
+
+	// A blacklisted or otherwise invalidated game would only surface as an opaque CheckWithdrawal revert
+	reason, err := internal.InvalidGameReason(&bind.CallOpts{Context: readCtx}, f.optimismPortal, proven.DisputeGameProxy, permissionedDisputeGame)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("the game your withdrawal was proven against is no longer valid, %s; re-prove required", reason)
+	}
+	// With --skip-game-resolution the game is expected to have been resolved already, typically by the challenger
+	// service, so no resolve transactions are attempted. With --wait-for-challenger that resolution is waited for.
+	if f.WaitForChallenger {
+		if err := f.waitForChallengerResolution(ctx, permissionedDisputeGame); err != nil {
+			return err
+		}
+	} else if !f.SkipGameResolution {
+		done, err := f.resolveGame(ctx, result, permissionedDisputeGame)
+		if err != nil || done {
+			return err
+		}
+	}
+
+	// Waiting for the challenger or for resolve transactions to be mined may outlast the deadline of the reads above
+	readCtx, cancel = context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+
+	disputeGameResolvedAt, err := permissionedDisputeGame.ResolvedAt(&bind.CallOpts{Context: readCtx})
+	if err != nil {
+		return fmt.Errorf("could not fetch DisputeGame.Status: %w", err)
+	}
+	disputeGameResolvedAtTime := time.Unix(int64(disputeGameResolvedAt), 0)
+
+	if disputeGameResolvedAt == 0 {
+		log.Info("PermissionedDisputeGame has not been resolved and game resolution is skipped, exiting...")
+		return internal.ErrGameNotResolved
+	}
+
+	disputeGameStatus, err := internal.CheckGameNotChallenged(&bind.CallOpts{Context: readCtx}, proven.DisputeGameProxy, permissionedDisputeGame)
+	if errors.Is(err, internal.ErrGameChallenged) {
+		log.Error("PermissionedDisputeGame resolved against the proposer", "status", disputeGameStatus.String(), "resolvedAt", disputeGameResolvedAtTime, "game", proven.DisputeGameProxy)
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("PermissionedDisputeGame has been resolved, continuing...", "status", disputeGameStatus.String(), "resolvedAt", disputeGameResolvedAtTime)
+
+	proofMaturityDelaySeconds, err := f.optimismPortal.ProofMaturityDelaySeconds(&bind.CallOpts{Context: readCtx})
+	if err != nil {
+		return fmt.Errorf("could not call OptimismPortal.ProofMaturityDelaySeconds: %w", err)
+	}
+	proofMaturityDelay := time.Duration(proofMaturityDelaySeconds.Int64() * int64(time.Second))
+
+	finalityDelaySeconds, err := f.optimismPortal.DisputeGameFinalityDelaySeconds(&bind.CallOpts{Context: readCtx})
+	if err != nil {
+		return fmt.Errorf("could not call OptimismPortal.DisputeGameFinalityDelaySeconds: %w", err)
+	}
+	finalityDelay := time.Duration(finalityDelaySeconds.Int64() * int64(time.Second))
+
+	proofMaturityTime := provenTimestamp.Add(proofMaturityDelay)
+	finalityDelayTime := disputeGameResolvedAtTime.Add(finalityDelay)
+	now, err := internal.Now(readCtx, f.l1Client, f.TimeSource)
+	if err != nil {
+		return err
+	}
+	untilProofMaturityTime := proofMaturityTime.Sub(now)
+	untilFinalityDelayTime := finalityDelayTime.Sub(now)
+
+	if untilProofMaturityTime > 0 || untilFinalityDelayTime > 0 {
+		log.Info("either the proof has not matured long enough or the finality period has not passed, exiting...",
+			"proofMaturityTime", proofMaturityTime,
+			"finalityDelayTime", finalityDelayTime,
+			"until proofMaturityTime", untilProofMaturityTime,
+			"until finalityDelayTime", untilFinalityDelayTime,
+		)
+		if untilProofMaturityTime > 0 {
+			return fmt.Errorf("%w, matures at %s", internal.ErrProofNotMatured, proofMaturityTime)
+		}
+		return fmt.Errorf("%w, elapses at %s", internal.ErrFinalityNotElapsed, finalityDelayTime)
+	} else {
+		log.Info("the withdrawal proof has matured long enough and the finality period has passed, continuing...",
+			"proofMaturityTime", proofMaturityTime,
+			"finalityDelayTime", finalityDelayTime,
+			"since proofMaturityTime", -untilProofMaturityTime,
+			"since finalityDelayTime", -untilFinalityDelayTime,
+		)
+	}
+
+	log.Info("calling OptimismPortal.CheckWithdrawal to validate that withdrawal can be finalized")
+	err = f.optimismPortal.CheckWithdrawal(&bind.CallOpts{Context: readCtx}, messagePassedEvent.WithdrawalHash, f.Prover)
+	if err != nil {
+		log.Info("Optimism.CheckWithdrawal failed, exiting...", "error", err)
+		return fmt.Errorf("call to OptimismPortal.CheckWithdrawal failed: %w", err)
+	} else {
+		log.Info("call to Optimism.CheckWithdrawal succeeded, proceeding with finalizeWithdrawal transaction...")
+	}
+
+	// The finalize transaction only needs the withdrawal itself, the proof is generated for --dump-proof alone
+	if f.DumpProofDir != "" {
+		// The dumped proof is the one finalized, made against the game the withdrawal was proven with rather than
+		// against the latest game
+		game, err := f.provenGame(&bind.CallOpts{Context: readCtx}, proven.DisputeGameProxy, permissionedDisputeGame)
 		if err != nil {
-			return fmt.Errorf("PermissionedDisputeGame.GetChallengerDuration failed: %w", err)
+			return err
 		}
-		maxClockDuration := time.Duration(_maxClockDuration * uint64(time.Second))
-
-		isClaimResolved, err := permissionedDisputeGame.ResolvedSubgames(&bind.CallOpts{}, common.Big0)
+		params, err := f.proofs.ProveWithdrawalParametersForGame(
+			readCtx,
+			internal.ProofVariantFaultProofs,
+			gethclient.New(f.l2Client.Client()),
+			f.l2Receipts,
+			f.l2Client,
+			withdrawalTxHash,
+			game,
+		)
 		if err != nil {
-			return fmt.Errorf("PermissionedDisputeGame.ResolvedSubgame failed: %w", err)
+			return fmt.Errorf("could not generate fault proofs for withdrawal: %w", err)
+		}
+		if _, err := internal.DumpProof(f.DumpProofDir, withdrawalTxHash, params); err != nil {
+			return err
 		}
-		if !isClaimResolved {
-			log.Info("PermissionedDisputeGame has not resolved any subgames")
+	}
+
+	withdrawal := bindingspreview.TypesWithdrawalTransaction{
+		Nonce:    messagePassedEvent.Nonce,
+		Sender:   messagePassedEvent.Sender,
+		Target:   messagePassedEvent.Target,
+		Value:    messagePassedEvent.Value,
+		GasLimit: messagePassedEvent.GasLimit,
+		Data:     messagePassedEvent.Data,
+	}
+	return f.submitFinalize(ctx, result, asset, preBalance, f.finalizeTx(withdrawal))
+}
 
-			_challengerDuration, err := permissionedDisputeGame.GetChallengerDuration(&bind.CallOpts{}, common.Big0)
-			if err != nil {
-				return fmt.Errorf("PermissionedDisputeGame.GetChallengerDuration failed: %w", err)
-			}
-			challengerDuration := time.Duration(_challengerDuration * uint64(time.Second))
-
-			if challengerDuration < maxClockDuration {
-				log.Info("challenger duration period has not passed, exiting...",
-					"challengerDuration", challengerDuration,
-					"maxClockDuration", maxClockDuration,
-				)
-				return nil
-			} else {
-				log.Info("challenger duration period has passed, continuing...",
-					"challengerDuration", challengerDuration,
-					"maxClockDuration", maxClockDuration,
-				)
-			}
+// provenGame looks up the factory entry of the game at gameProxy, searching for the game proposed at its L2 block
+// with its root claim
+func (f *finalizer) provenGame(opts *bind.CallOpts, gameProxy common.Address, game *bindings.PermissionedDisputeGame) (*opNodeBindings.IDisputeGameFactoryGameSearchResult, error) {
+	l2BlockNumber, err := game.L2BlockNumber(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch DisputeGame.L2BlockNumber: %w", err)
+	}
+	rootClaim, err := game.RootClaim(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch DisputeGame.RootClaim: %w", err)
+	}
+	outputRoot := common.Hash(rootClaim)
+	result, err := internal.FindGame(opts, &f.disputeGameFactory.DisputeGameFactoryCaller, &f.optimismPortal.OptimismPortal2Caller, l2BlockNumber, &outputRoot)
+	if err != nil {
+		return nil, fmt.Errorf("could not find the game the withdrawal was proven against: %w", err)
+	}
+	if proxy := internal.SearchResultGameProxy(result); proxy != gameProxy {
+		return nil, fmt.Errorf("the game the withdrawal was proven against, %s, is not the game found at its L2 block, %s", gameProxy.Hex(), proxy.Hex())
+	}
+	return result, nil
+}
 
-			tx, err := transactions.PadGasEstimate(opts, 1.5, func(opts *bind.TransactOpts) (*types.Transaction, error) {
-				return permissionedDisputeGame.ResolveClaim(opts, common.Big0, common.Big0)
-			})
-			if err != nil {
-				return fmt.Errorf("failed to send PermissionedDisputeGame.ResolveClaim(): %w", err)
-			}
+// proverAccount returns the account whose proofs finalize finalizes: --prover when set, otherwise the sender
+func proverAccount(c *cli.Context, sender common.Address, useOracle bool) (common.Address, error) {
+	if !c.IsSet("prover") {
+		return sender, nil
+	}
+	// The legacy portal records proofs by withdrawal alone, so any account finalizes them
+	if useOracle {
+		return internal.ZeroAddress, fmt.Errorf("prover cannot be combined with l2-output-oracle-address")
+	}
+	prover, err := internal.SafeParseAddress(c.String("prover"))
+	if err != nil {
+		return internal.ZeroAddress, fmt.Errorf("could not parse prover address: %w", err)
+	}
+	if prover != sender {
+		log.Info("finalizing withdrawals proven by another account", "prover", prover, "sender", sender)
+	}
+	return prover, nil
+}
 
-			receipt, err := wait.ForReceiptOK(ctx, l1Client, tx.Hash())
-			if err != nil {
-				if statusErr, ok := err.(*wait.ReceiptStatusError); ok {
-					log.Error("transaction trace", "tx", tx.Hash().Hex(), "trace", statusErr.TxTrace)
-					return fmt.Errorf("failure in transaction execution: %w", err)
-				} else {
-					return fmt.Errorf("failed to get transaction receipt: %w", err)
-				}
-			}
+// provenWithdrawal is the proof OptimismPortal2 stores for a withdrawal and its prover
+type provenWithdrawal struct {
+	DisputeGameProxy common.Address
+	Timestamp        uint64
+}
 
-			log.Info("successfully executed PermissionedDisputeGame.Resolve, exiting...", "tx", receipt.TxHash.Hex())
-			return nil
-		} else {
-			log.Info("PermissionedDisputeGame has already resolved subgames, continuing...")
+// provenWithdrawal reads the proof the prover submitted for withdrawalHash, failing with ErrNotProven when there is none
+func (f *finalizer) provenWithdrawal(opts *bind.CallOpts, withdrawalHash common.Hash) (*provenWithdrawal, error) {
+	proven, err := f.optimismPortal.ProvenWithdrawals(opts, withdrawalHash, f.Prover)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch proven withdrawal: %w", err)
+	}
+	if proven.Timestamp == 0 {
+		return nil, internal.ErrNotProven
+	}
+	return &provenWithdrawal{DisputeGameProxy: proven.DisputeGameProxy, Timestamp: proven.Timestamp}, nil
+}
+
+// finalizeTx builds the finalize transaction of withdrawal. finalizeWithdrawalTransaction looks up the proof of the
+// sender, the proof of another prover has to be named through finalizeWithdrawalTransactionExternalProof.
+func (f *finalizer) finalizeTx(withdrawal bindingspreview.TypesWithdrawalTransaction) transactions.TxBuilder {
+	return func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		if f.Prover != f.account {
+			return f.optimismPortal.FinalizeWithdrawalTransactionExternalProof(opts, withdrawal, f.Prover)
 		}
+		return f.optimismPortal.FinalizeWithdrawalTransaction(opts, withdrawal)
+	}
+}
 
-		disputeGameResolvedAt, err := permissionedDisputeGame.ResolvedAt(&bind.CallOpts{})
+// submitFinalize sends the finalize transaction built by finalize and reports the balance change it caused on the
+// holder of asset, or sets the unsigned transaction on result with --build-only
+func (f *finalizer) submitFinalize(ctx context.Context, result *finalizeResult, asset *asset, preBalance *big.Int, finalize transactions.TxBuilder) error {
+	if f.BuildOnly {
+		unsigned, err := internal.BuildUnsignedTransaction(f.opts, f.l1ChainId, "OptimismPortal.FinalizeWithdrawalTransaction", finalize)
 		if err != nil {
-			return fmt.Errorf("could not fetch DisputeGame.Status: %w", err)
+			return err
 		}
-		disputeGameResolvedAtTime := time.Unix(int64(disputeGameResolvedAt), 0)
-
-		if disputeGameResolvedAt == 0 {
-			log.Info("disputeGame unresolved, calling PermissionedDisputeGame.Resolve()")
+		result.UnsignedTx = unsigned
+		return nil
+	}
+
+	log.Info("calling OptimismPortal.FinalizeWithdrawalTransaction")
+	receipt, err := f.send(ctx, result, "OptimismPortal.FinalizeWithdrawalTransaction", finalize)
+	if err != nil {
+		return err
+	}
+	log.Info("successfully executed OptimismPortal.FinalizedWithdrawalTransaction(), exiting...", "tx", receipt.TxHash.Hex())
+	result.Receipt = receipt
+	result.Gas = internal.NewGasReport(receipt)
+
+	balanceCtx, cancelBalance := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancelBalance()
+
+	postBalance, err := asset.balance(balanceCtx, f.l1Client)
+	if err != nil {
+		return fmt.Errorf("could not fetch balance: %w", err)
+	}
+	result.PostBalance = postBalance
+
+	// The balance of a holder that also paid for the transactions of this run is reduced by their fees, which are added
+	// back to report the credited value. Transactions sent in earlier runs happened before preBalance was read.
+	credited := new(big.Int).Sub(postBalance, preBalance)
+	if asset.token == nil && asset.holder == f.account {
+		credited.Add(credited, result.GasSpent)
+	}
+	result.Credited = credited
+
+	log.Info("successfully finalized withdrawal transaction", "initTx", result.TxHash.Hex())
+	log.Info(
+		"Balance differentials",
+		"l1Token", asset.address,
+		"holder", asset.holder,
+		"holder L1 balance (+)", internal.FormatBigInt(credited, asset.decimals),
+		"expected", internal.FormatBigInt(asset.amount, asset.decimals),
+		"signer", f.account,
+		"signer gas", internal.FormatWei(result.GasSpent),
+	)
+
+	if f.CheckRecipient && credited.Cmp(asset.amount) < 0 {
+		return fmt.Errorf("recipient %s was credited %s on L1 but the withdrawal pays out %s", asset.holder.Hex(), internal.FormatBigInt(credited, asset.decimals), internal.FormatBigInt(asset.amount, asset.decimals))
+	}
+
+	return nil
+}
 
-			tx, err := transactions.PadGasEstimate(opts, 1.5, func(opts *bind.TransactOpts) (*types.Transaction, error) {
-				return permissionedDisputeGame.Resolve(opts)
+// resolveGame sends the ResolveClaim or Resolve transaction the dispute game is waiting for, returning true when the
+// withdrawal cannot progress any further in this run
+func (f *finalizer) resolveGame(ctx context.Context, result *finalizeResult, permissionedDisputeGame *bindings.PermissionedDisputeGame) (bool, error) {
+	readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+
+	isClaimResolved, err := permissionedDisputeGame.ResolvedSubgames(&bind.CallOpts{Context: readCtx}, internal.RootClaimIndex)
+	if err != nil {
+		return false, fmt.Errorf("PermissionedDisputeGame.ResolvedSubgame failed: %w", err)
+	}
+	if !isClaimResolved {
+		log.Info("PermissionedDisputeGame has not resolved its root claim")
+
+		// Every claim countering the root claim has to be resolved before it, which the plan orders
+		plan, err := internal.PlanSubgameResolution(&bind.CallOpts{Context: readCtx}, permissionedDisputeGame)
+		if err != nil {
+			return false, err
+		}
+		for _, index := range plan.Resolvable {
+			receipt, err := f.sendResolve(ctx, result, "PermissionedDisputeGame.ResolveClaim", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+				return permissionedDisputeGame.ResolveClaim(opts, index, common.Big0)
 			})
 			if err != nil {
-				return fmt.Errorf("failed to send PermissionedDisputeGame.Resolve(): %w", err)
+				return false, err
 			}
+			log.Info("successfully executed PermissionedDisputeGame.ResolveClaim", "claim", index, "tx", receipt.TxHash.Hex())
+		}
 
-			receipt, err := wait.ForReceiptOK(ctx, l1Client, tx.Hash())
-			if err != nil {
-				if statusErr, ok := err.(*wait.ReceiptStatusError); ok {
-					log.Error("transaction trace", "tx", tx.Hash().Hex(), "trace", statusErr.TxTrace)
-					return fmt.Errorf("failure in transaction execution: %w", err)
-				} else {
-					return fmt.Errorf("failed to get transaction receipt: %w", err)
-				}
-			}
+		if !plan.RootResolved {
+			log.Info("challenger duration period has not passed, exiting...", "remaining", plan.Remaining)
+			return false, fmt.Errorf("%w, the challenger clock has %s remaining", internal.ErrGameNotResolved, plan.Remaining)
+		}
 
-			log.Info("successfully executed PermissionedDisputeGame.Resolve(), exiting...", "tx", receipt.TxHash.Hex())
-			return nil
+		log.Info("successfully resolved the root claim, exiting...")
+		return true, nil
+	} else {
+		log.Info("PermissionedDisputeGame has already resolved subgames, continuing...")
+	}
 
-		} else {
-			disputeGameStatus, err := permissionedDisputeGame.Status(&bind.CallOpts{})
-			if err != nil {
-				return fmt.Errorf("could not fetch PermissionedDisputeGame.Status(): %w", err)
-			}
-			log.Info("PermissionedDisputeGame has been resolved, continuing...", "status", disputeGameStatus, "resolvedAt", time.Unix(int64(disputeGameResolvedAt), 0))
-		}
+	disputeGameResolvedAt, err := permissionedDisputeGame.ResolvedAt(&bind.CallOpts{Context: readCtx})
+	if err != nil {
+		return false, fmt.Errorf("could not fetch DisputeGame.Status: %w", err)
+	}
+
+	if disputeGameResolvedAt == 0 {
+		log.Info("disputeGame unresolved, calling PermissionedDisputeGame.Resolve()")
 
-		proofMaturityDelaySeconds, err := optimismPortal.ProofMaturityDelaySeconds(&bind.CallOpts{})
+		receipt, err := f.sendResolve(ctx, result, "PermissionedDisputeGame.Resolve", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return permissionedDisputeGame.Resolve(opts)
+		})
 		if err != nil {
-			return fmt.Errorf("could not call OptimismPortal.ProofMaturityDelaySeconds: %w", err)
+			return false, err
+		}
+
+		log.Info("successfully executed PermissionedDisputeGame.Resolve(), exiting...", "tx", receipt.TxHash.Hex())
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// send sends a transaction on behalf of the withdrawal of result, adding its fee to the gas spent on the withdrawal
+func (f *finalizer) send(ctx context.Context, result *finalizeResult, name string, builder transactions.TxBuilder) (*types.Receipt, error) {
+	receipt, err := internal.SendTransaction(ctx, f.l1Client, f.opts, name, builder)
+	if err != nil {
+		return nil, err
+	}
+	result.GasSpent.Add(result.GasSpent, internal.NewGasReport(receipt).Fee)
+	return receipt, nil
+}
+
+// sendResolve sends a resolve transaction like send, simulating it first with --simulate-resolve so that a resolve
+// that would revert, such as one whose challenger clock has not actually expired, is not paid for
+func (f *finalizer) sendResolve(ctx context.Context, result *finalizeResult, name string, builder transactions.TxBuilder) (*types.Receipt, error) {
+	if f.SimulateResolve {
+		if err := internal.SimulateTransaction(ctx, f.l1Client, f.opts, name, builder); err != nil {
+			return nil, err
 		}
-		proofMaturityDelay := time.Duration(proofMaturityDelaySeconds.Int64() * int64(time.Second))
+	}
+	return f.send(ctx, result, name, builder)
+}
 
-		finalityDelaySeconds, err := optimismPortal.DisputeGameFinalityDelaySeconds(&bind.CallOpts{})
+// asset is what a withdrawal pays out on L1 and to whom, so that the balance change of its finalization is read and
+// reported in the right unit
+type asset struct {
+	address  common.Address
+	token    *e2eBindings.ERC20
+	holder   common.Address
+	amount   *big.Int
+	decimals int
+}
+
+// withdrawnAsset identifies the asset of the withdrawal initiated in receipt from its L2StandardBridge
+// WithdrawalInitiated event. Withdrawals sent without the bridge pay out the ETH value of their message to its target.
+func (f *finalizer) withdrawnAsset(ctx context.Context, receipt *types.Receipt) (*asset, error) {
+	event, err := receipts.FindLog(receipt.Logs, f.l2StandardBridge.ParseWithdrawalInitiated)
+	if err != nil {
+		messagePassedEvent, err := withdrawals.ParseMessagePassed(receipt)
 		if err != nil {
-			return fmt.Errorf("could not call OptimismPortal.DisputeGameFinalityDelaySeconds: %w", err)
-		}
-		finalityDelay := time.Duration(finalityDelaySeconds.Int64() * int64(time.Second))
-
-		proofMaturityTime := provenTimestamp.Add(proofMaturityDelay)
-		finalityDelayTime := disputeGameResolvedAtTime.Add(finalityDelay)
-		untilProofMaturityTime := time.Until(proofMaturityTime)
-		untilFinalityDelayTime := time.Until(finalityDelayTime)
-
-		if untilProofMaturityTime > 0 || untilFinalityDelayTime > 0 {
-			log.Info("either the proof has not matured long enough or the finality period has not passed, exiting...",
-				"proofMaturityTime", proofMaturityTime,
-				"finalityDelayTime", finalityDelayTime,
-				"until proofMaturityTime", untilProofMaturityTime,
-				"until finalityDelayTime", untilFinalityDelayTime,
-			)
-			return nil
-		} else {
-			log.Info("the withdrawal proof has matured long enough and the finality period has passed, continuing...",
-				"proofMaturityTime", proofMaturityTime,
-				"finalityDelayTime", finalityDelayTime,
-				"since proofMaturityTime", -untilProofMaturityTime,
-				"since finalityDelayTime", -untilFinalityDelayTime,
-			)
+			return nil, fmt.Errorf("could not parse the MessagePassed event from the withdrawal transaction: %w", err)
 		}
+		return &asset{address: internal.ZeroAddress, holder: messagePassedEvent.Target, amount: messagePassedEvent.Value, decimals: 18}, nil
+	}
+	if event.L1Token == internal.ZeroAddress {
+		return &asset{address: internal.ZeroAddress, holder: event.To, amount: event.Amount, decimals: 18}, nil
+	}
+
+	token, err := e2eBindings.NewERC20(event.L1Token, f.l1Client)
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate ERC20 contract: %w", err)
+	}
+	decimals, err := token.Decimals(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch ERC20.Decimals of %s: %w", event.L1Token.Hex(), err)
+	}
+
+	return &asset{address: event.L1Token, token: token, holder: event.To, amount: event.Amount, decimals: int(decimals)}, nil
+}
 
-		withdrawalFinalized, err := optimismPortal.FinalizedWithdrawals(&bind.CallOpts{}, messagePassedEvent.WithdrawalHash)
+// balance returns the L1 balance of the asset held by its holder
+func (a *asset) balance(ctx context.Context, l1Client *ethclient.Client) (*big.Int, error) {
+	if a.token == nil {
+		return l1Client.BalanceAt(ctx, a.holder, nil)
+	}
+	return a.token.BalanceOf(&bind.CallOpts{Context: ctx}, a.holder)
+}
+
+// waitForChallengerResolution polls the dispute game until the challenger service has resolved it, logging the
+// remaining challenger clock on every poll
+func (f *finalizer) waitForChallengerResolution(ctx context.Context, permissionedDisputeGame *bindings.PermissionedDisputeGame) error {
+	waitCtx, cancel := context.WithTimeout(ctx, f.ChallengerTimeout)
+	defer cancel()
+
+	err := internal.PollUntil(waitCtx, f.PollInterval, f.MaxPollInterval, func() (bool, error) {
+		pollCtx, cancel := context.WithTimeout(waitCtx, internal.CallTimeout)
+		defer cancel()
+
+		// Read failures are retried on the next poll
+		disputeGameResolvedAt, err := permissionedDisputeGame.ResolvedAt(&bind.CallOpts{Context: pollCtx})
 		if err != nil {
-			return fmt.Errorf("could not fetch OptimismPortal.FinalizedWithdrawals: %w", err)
+			log.Warn("could not fetch PermissionedDisputeGame.ResolvedAt, retrying...", "error", err)
+			return false, nil
 		}
-		if withdrawalFinalized {
-			log.Info("withdrawal proof has already been finalized, exiting...", "withdrawal hash", common.Bytes2Hex(messagePassedEvent.WithdrawalHash[:]))
-			return nil
-		} else {
-			log.Info("withdrawal proof has not been finalized, continuing...")
+		if disputeGameResolvedAt != 0 {
+			log.Info("the challenger has resolved the dispute game, continuing...")
+			return true, nil
 		}
 
-		log.Info("calling OptimismPortal.CheckWithdrawal to validate that withdrawal can be finalized")
-		err = optimismPortal.CheckWithdrawal(&bind.CallOpts{}, messagePassedEvent.WithdrawalHash, account)
+		isClaimResolved, err := permissionedDisputeGame.ResolvedSubgames(&bind.CallOpts{Context: pollCtx}, internal.RootClaimIndex)
 		if err != nil {
-			log.Info("Optimism.CheckWithdrawal failed, exiting...", "error", err)
-			return fmt.Errorf("call to OptimismPortal.CheckWithdrawal failed: %w", err)
-		} else {
-			log.Info("call to Optimism.CheckWithdrawal succeeded, proceeding with finalizeWithdrawal transaction...")
+			log.Warn("PermissionedDisputeGame.ResolvedSubgames failed, retrying...", "error", err)
+			return false, nil
 		}
-
-		params, err := withdrawals.ProveWithdrawalParametersFaultProofs(
-			ctx,
-			gethclient.New(l2Client.Client()),
-			l2Client,
-			l2Client,
-			withdrawalTxHash,
-			&disputeGameFactory.DisputeGameFactoryCaller,
-			&optimismPortal.OptimismPortal2Caller,
-		)
+		// The root claim resolves once the clocks of the claims countering it have expired as well
+		plan, err := internal.PlanSubgameResolution(&bind.CallOpts{Context: pollCtx}, permissionedDisputeGame)
 		if err != nil {
-			return fmt.Errorf("could not generate fault proofs for withdrawal: %w", err)
+			log.Warn("could not plan the subgame resolution, retrying...", "error", err)
+			return false, nil
 		}
 
-		log.Info("calling OptimismPortal.FinalizeWithdrawalTransaction")
-		tx, err := transactions.PadGasEstimate(opts, 1.5, func(opts *bind.TransactOpts) (*types.Transaction, error) {
-			return optimismPortal.FinalizeWithdrawalTransaction(
-				opts,
-				bindingspreview.TypesWithdrawalTransaction{
-					Nonce:    params.Nonce,
-					Sender:   params.Sender,
-					Target:   params.Target,
-					Value:    params.Value,
-					GasLimit: params.GasLimit,
-					Data:     params.Data,
-				},
-			)
-		})
+		log.Info("waiting for the challenger to resolve the dispute game...",
+			"claimResolved", isClaimResolved,
+			"remainingClock", plan.Remaining,
+		)
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("dispute game was not resolved by the challenger within %s: %w", f.ChallengerTimeout, err)
+	}
+
+	return nil
+}
+
+// readWithdrawalTxHashes collects the withdrawal transaction hashes passed with --tx and --tx-file
+func readWithdrawalTxHashes(c *cli.Context) ([]common.Hash, error) {
+	var hashes []common.Hash
+	for _, tx := range c.StringSlice("tx") {
+		hash, err := internal.SafeParseHash(tx)
 		if err != nil {
-			return fmt.Errorf("failed to send OptimismPortal.FinalizeWithdrawalTransaction(): %w", err)
+			return nil, fmt.Errorf("could not parse --tx: %w", err)
 		}
+		hashes = append(hashes, hash)
+	}
 
-		receipt, err := wait.ForReceiptOK(ctx, l1Client, tx.Hash())
+	if path := c.String("tx-file"); path != "" {
+		data, err := os.ReadFile(path)
 		if err != nil {
-			if statusErr, ok := err.(*wait.ReceiptStatusError); ok {
-				log.Error("transaction trace", "tx", tx.Hash().Hex(), "trace", statusErr.TxTrace)
-				return fmt.Errorf("failure in transaction execution: %w", err)
-			} else {
-				return fmt.Errorf("failed to get transaction receipt: %w", err)
+			return nil, fmt.Errorf("could not read tx file %s: %w", path, err)
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
 			}
+			hash, err := internal.SafeParseHash(line)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %s line %d: %w", path, i+1, err)
+			}
+			hashes = append(hashes, hash)
 		}
-		log.Info("successfully executed OptimismPortal.FinalizedWithdrawalTransaction(), exiting...", "tx", receipt.TxHash.Hex())
+	}
+
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("at least one withdrawal transaction hash must be provided with --tx, --tx-file or --withdrawal-hash")
+	}
 
-		postBalance, err := l1Client.BalanceAt(ctx, account, nil)
+	return hashes, nil
+}
+
+// lookupWithdrawalTxHashes finds the L2 transactions that initiated the withdrawals passed with --withdrawal-hash. The
+// withdrawal hash is not an indexed field of MessagePassed, so the events of the L2ToL1MessagePasser are scanned from
+// --from-block on until every withdrawal is found.
+func lookupWithdrawalTxHashes(ctx context.Context, c *cli.Context, l2Client *ethclient.Client) ([]common.Hash, error) {
+	var withdrawalHashes []common.Hash
+	pending := make(map[common.Hash]bool)
+	for _, withdrawalHash := range c.StringSlice("withdrawal-hash") {
+		hash, err := internal.SafeParseHash(withdrawalHash)
 		if err != nil {
-			return fmt.Errorf("could not fetch balance: %w", err)
+			return nil, fmt.Errorf("could not parse --withdrawal-hash: %w", err)
 		}
+		withdrawalHashes = append(withdrawalHashes, hash)
+		pending[hash] = true
+	}
+
+	messagePasser, err := e2eBindings.NewL2ToL1MessagePasserFilterer(predeploys.L2ToL1MessagePasserAddr, l2Client)
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate L2ToL1MessagePasser filterer: %w", err)
+	}
+
+	txHashes := make(map[common.Hash]common.Hash)
+	err = internal.ScanLogs(ctx, l2Client, c.Uint64("from-block"), func(opts *bind.FilterOpts) (bool, error) {
+		iterator, err := messagePasser.FilterMessagePassed(opts, nil, nil, nil)
+		if err != nil {
+			return false, fmt.Errorf("could not filter MessagePassed events: %w", err)
+		}
+		defer iterator.Close()
 
-		log.Info("successfully finalized withdrawal transaction", "initTx", withdrawalTxHash.Hex(), "amount", postBalance.Uint64()-preBalance.Uint64())
+		for len(pending) > 0 && iterator.Next() {
+			event := iterator.Event
+			if pending[event.WithdrawalHash] {
+				txHashes[event.WithdrawalHash] = event.Raw.TxHash
+				delete(pending, event.WithdrawalHash)
+			}
+		}
+		if err := iterator.Error(); err != nil {
+			return false, fmt.Errorf("could not iterate MessagePassed events: %w", err)
+		}
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]common.Hash, 0, len(withdrawalHashes))
+	for _, withdrawalHash := range withdrawalHashes {
+		txHash, ok := txHashes[withdrawalHash]
+		if !ok {
+			return nil, fmt.Errorf("no MessagePassed event found for withdrawal hash %s", withdrawalHash.Hex())
+		}
+		log.Info("found withdrawal transaction", "withdrawalHash", withdrawalHash.Hex(), "tx", txHash.Hex())
+		hashes = append(hashes, txHash)
+	}
 
-		return nil
-	},
+	return hashes, nil
 }
@@ -0,0 +1,176 @@
+package withdraw_cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"math/big"
+	"testing"
+
+	"github.com/Golem-Base/op-probe/internal"
+	"github.com/Golem-Base/op-probe/internal/testutil"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	testProver             = common.HexToAddress("0x000000000000000000000000000000000000b0b0")
+	testGameProxy          = common.HexToAddress("0x000000000000000000000000000000000000ca11")
+	testWithdrawalHash     = common.HexToHash("0x01")
+	testDisputeGameFactory = common.HexToAddress("0x000000000000000000000000000000000000fac7")
+)
+
+// finalizeContext returns the context of a finalize invocation with args
+func finalizeContext(t *testing.T, args ...string) *cli.Context {
+	t.Helper()
+	set := flag.NewFlagSet("finalize", flag.ContinueOnError)
+	for _, f := range FinalizeCommand.Flags {
+		if err := f.Apply(set); err != nil {
+			t.Fatalf("could not apply flag %s: %v", f.Names()[0], err)
+		}
+	}
+	if err := set.Parse(args); err != nil {
+		t.Fatalf("could not parse %v: %v", args, err)
+	}
+	return cli.NewContext(cli.NewApp(), set, nil)
+}
+
+// newProverFinalizer returns a finalizer sending from a funded account and finalizing the proofs of prover, against a
+// portal which only holds a proof of testWithdrawalHash submitted by testProver
+func newProverFinalizer(t *testing.T, prover func(sender common.Address) common.Address) *finalizer {
+	t.Helper()
+	chain := testutil.NewChain(t, big.NewInt(900))
+
+	portalABI, err := bindingspreview.OptimismPortal2MetaData.GetAbi()
+	if err != nil {
+		t.Fatalf("could not parse OptimismPortal2 ABI: %v", err)
+	}
+	chain.HandleCalls(testutil.OptimismPortalAddress, func(from common.Address, data []byte) ([]byte, error) {
+		method, err := portalABI.MethodById(data[:4])
+		if err != nil {
+			return nil, err
+		}
+		if method.RawName != "provenWithdrawals" {
+			return nil, errors.New("unexpected call to " + method.Sig)
+		}
+		args, err := method.Inputs.Unpack(data[4:])
+		if err != nil {
+			return nil, err
+		}
+		if args[0].([32]byte) == testWithdrawalHash && args[1].(common.Address) == testProver {
+			return method.Outputs.Pack(testGameProxy, uint64(1_700_000_000))
+		}
+		return method.Outputs.Pack(internal.ZeroAddress, uint64(0))
+	})
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	sender := crypto.PubkeyToAddress(privateKey.PublicKey)
+	chain.SetBalance(sender, big.NewInt(params.Ether))
+	opts, err := bind.NewKeyedTransactorWithChainID(privateKey, chain.ChainID)
+	if err != nil {
+		t.Fatalf("could not create transactor: %v", err)
+	}
+	opts.NoSend = true
+
+	w, err := NewWithdrawer(chain.Client(), chain.Client(), chain.ChainID, chain.ChainID, testDisputeGameFactory, testutil.OptimismPortalAddress)
+	if err != nil {
+		t.Fatalf("could not create withdrawer: %v", err)
+	}
+	return w.newFinalizer(opts, FinalizeConfig{Prover: prover(sender)})
+}
+
+// finalizeMethod returns the OptimismPortal2 method called by the finalize transaction of f, and its arguments
+func finalizeMethod(t *testing.T, f *finalizer) (string, []interface{}) {
+	t.Helper()
+	tx, err := f.finalizeTx(bindingspreview.TypesWithdrawalTransaction{
+		Nonce:    common.Big1,
+		Sender:   f.account,
+		Target:   f.account,
+		Value:    common.Big0,
+		GasLimit: common.Big0,
+		Data:     []byte{},
+	})(f.opts)
+	if err != nil {
+		t.Fatalf("could not build finalize transaction: %v", err)
+	}
+	portalABI, err := bindingspreview.OptimismPortal2MetaData.GetAbi()
+	if err != nil {
+		t.Fatalf("could not parse OptimismPortal2 ABI: %v", err)
+	}
+	method, err := portalABI.MethodById(tx.Data()[:4])
+	if err != nil {
+		t.Fatalf("could not decode finalize transaction: %v", err)
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		t.Fatalf("could not decode finalize arguments: %v", err)
+	}
+	return method.RawName, args
+}
+
+func TestProverAccount(t *testing.T) {
+	sender := common.HexToAddress("0x000000000000000000000000000000000000a11c")
+
+	prover, err := proverAccount(finalizeContext(t), sender, false)
+	if err != nil || prover != sender {
+		t.Errorf("prover without --prover is %s (%v), want the sender %s", prover, err, sender)
+	}
+	prover, err = proverAccount(finalizeContext(t, "--prover", testProver.Hex()), sender, false)
+	if err != nil || prover != testProver {
+		t.Errorf("prover with --prover is %s (%v), want %s", prover, err, testProver)
+	}
+	if _, err := proverAccount(finalizeContext(t, "--prover", "0x1234"), sender, false); err == nil {
+		t.Error("an invalid --prover was accepted")
+	}
+}
+
+func TestProverAccountLegacyPortal(t *testing.T) {
+	sender := common.HexToAddress("0x000000000000000000000000000000000000a11c")
+	if _, err := proverAccount(finalizeContext(t, "--prover", testProver.Hex()), sender, true); err == nil {
+		t.Error("--prover was accepted with the legacy portal")
+	}
+	if _, err := proverAccount(finalizeContext(t), sender, true); err != nil {
+		t.Errorf("the legacy portal was rejected without --prover: %v", err)
+	}
+}
+
+func TestFinalizeExternalProof(t *testing.T) {
+	f := newProverFinalizer(t, func(common.Address) common.Address { return testProver })
+
+	proven, err := f.provenWithdrawal(&bind.CallOpts{Context: context.Background()}, testWithdrawalHash)
+	if err != nil {
+		t.Fatalf("the proof of --prover was not found: %v", err)
+	}
+	if proven.DisputeGameProxy != testGameProxy {
+		t.Errorf("proof is in game %s, want %s", proven.DisputeGameProxy, testGameProxy)
+	}
+
+	method, args := finalizeMethod(t, f)
+	if method != "finalizeWithdrawalTransactionExternalProof" {
+		t.Fatalf("finalize calls %s, want finalizeWithdrawalTransactionExternalProof", method)
+	}
+	if args[1].(common.Address) != testProver {
+		t.Errorf("finalize names proof submitter %s, want %s", args[1], testProver)
+	}
+}
+
+func TestFinalizeOwnProof(t *testing.T) {
+	f := newProverFinalizer(t, func(sender common.Address) common.Address { return sender })
+
+	// The proof of testProver is not the proof of the sender
+	_, err := f.provenWithdrawal(&bind.CallOpts{Context: context.Background()}, testWithdrawalHash)
+	if !errors.Is(err, internal.ErrNotProven) {
+		t.Errorf("expected ErrNotProven for the sender, got %v", err)
+	}
+
+	if method, _ := finalizeMethod(t, f); method != "finalizeWithdrawalTransaction" {
+		t.Errorf("finalize calls %s, want finalizeWithdrawalTransaction", method)
+	}
+}
@@ -0,0 +1,100 @@
+package withdraw_cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/Golem-Base/op-probe/internal"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// flowStage is how far prove-and-finalize got with a withdrawal, as persisted to its --state-file
+type flowStage string
+
+const (
+	// stageStarted is a withdrawal no transaction was sent for yet
+	stageStarted flowStage = "started"
+	// stageProving is a withdrawal whose prove transaction was sent but not seen mined
+	stageProving flowStage = "proving"
+	// stageProven is a withdrawal proven by the account, waiting for the game and the delays
+	stageProven flowStage = "proven"
+	// stageFinalizing is a withdrawal whose finalize transaction was sent but not seen mined
+	stageFinalizing flowStage = "finalizing"
+	// stageFinalized is a withdrawal finalized by the flow
+	stageFinalized flowStage = "finalized"
+)
+
+// flowState is the progress of prove-and-finalize persisted to --state-file, so that a run interrupted while waiting
+// out the game and the delays resumes where it left off. The stage only tells which transactions may still be pending,
+// the on-chain state of the withdrawal is always checked on resume.
+type flowState struct {
+	WithdrawalTxHash common.Hash    `json:"withdrawalTxHash"`
+	WithdrawalHash   common.Hash    `json:"withdrawalHash"`
+	Account          common.Address `json:"account"`
+	Stage            flowStage      `json:"stage"`
+	// PreBalance is the balance of the account when the flow first started, so that the balance change reported
+	// covers every run
+	PreBalance      *big.Int      `json:"preBalance"`
+	ProveTxHash     *common.Hash  `json:"proveTxHash,omitempty"`
+	ResolveTxHashes []common.Hash `json:"resolveTxHashes,omitempty"`
+	FinalizeTxHash  *common.Hash  `json:"finalizeTxHash,omitempty"`
+	UpdatedAt       time.Time     `json:"updatedAt"`
+
+	path string
+}
+
+// loadFlowState reads the state of the flow for the withdrawal withdrawalTxHash proven by account from path, or starts
+// a new one when the file does not exist yet. An empty path keeps the state in memory only.
+func loadFlowState(path string, withdrawalTxHash common.Hash, account common.Address) (*flowState, bool, error) {
+	state := &flowState{WithdrawalTxHash: withdrawalTxHash, Account: account, Stage: stageStarted, path: path}
+	if path == "" {
+		return state, false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("could not read state file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, false, fmt.Errorf("could not parse state file %s: %w", path, err)
+	}
+
+	// A state file only ever describes a single withdrawal, reusing it for another would skip its steps
+	if state.WithdrawalTxHash != withdrawalTxHash {
+		return nil, false, fmt.Errorf("state file %s is for withdrawal %s, not %s", path, state.WithdrawalTxHash.Hex(), withdrawalTxHash.Hex())
+	}
+	if state.Account != account {
+		return nil, false, fmt.Errorf("state file %s is for account %s, not %s", path, state.Account.Hex(), account.Hex())
+	}
+
+	log.Info("resuming withdrawal from state file", "path", path, "stage", state.Stage, "updatedAt", state.UpdatedAt)
+	return state, true, nil
+}
+
+// save persists the state to its file, replacing the previous one atomically so an interrupted write cannot lose it
+func (s *flowState) save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.UpdatedAt = time.Now()
+	if err := internal.WriteJSONFileAtomic(s.path, s); err != nil {
+		return fmt.Errorf("could not save state file: %w", err)
+	}
+	return nil
+}
+
+// advance moves the state to stage and persists it
+func (s *flowState) advance(stage flowStage) error {
+	log.Debug("withdrawal flow advanced", "from", s.Stage, "to", stage)
+	s.Stage = stage
+	return s.save()
+}
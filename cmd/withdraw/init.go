@@ -3,13 +3,10 @@ package withdraw_cmd
 import (
 	"context"
 	"fmt"
+	"math/big"
 
 	"github.com/Golem-Base/op-probe/internal"
-	e2eBindings "github.com/ethereum-optimism/optimism/op-e2e/bindings"
 	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/receipts"
-	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/transactions"
-	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/wait"
-	"github.com/ethereum-optimism/optimism/op-service/predeploys"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -18,20 +15,18 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-const RECEIVE_DEFAULT_GAS_LIMIT uint32 = 100_000
-
 var InitCommand = &cli.Command{
 	Name:  "init",
 	Usage: "Initialize a new withdrawal",
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:     "private-key",
-			Usage:    "Private key of address to send test transaction from",
+			Usage:    "Private key of address to send test transaction from, or - to read it from stdin",
 			Required: true,
 		},
 		&cli.StringFlag{
 			Name:     "l2-rpc-url",
-			Usage:    "Url for L2 execution client",
+			Usage:    "Url for L2 execution client, or comma separated urls to fail over between",
 			Required: true,
 		},
 		&cli.StringFlag{
@@ -44,24 +39,50 @@ var InitCommand = &cli.Command{
 			Usage:    "Amount to withdraw from L2 to L1 (wei)",
 			Required: true,
 		},
+		&cli.StringFlag{
+			Name:  "l1-rpc-url",
+			Usage: "Url for L1 execution client, or comma separated urls to fail over between, used to check whether the recipient is a contract needing a higher l1-gas-limit",
+		},
+		&cli.Uint64Flag{
+			Name:    "l1-gas-limit",
+			Aliases: []string{"min-gas-limit"},
+			Usage:   "Minimum gas limit for executing the withdrawal on L1 (default: 200000, or 500000 for a contract recipient)",
+			Value:   uint64(internal.RECEIVE_DEFAULT_GAS_LIMIT),
+		},
+		&cli.Uint64Flag{
+			Name:  "nonce",
+			Usage: "Nonce of the first transaction sent (default: pending nonce of the sender)",
+		},
 	},
-	Action: func(c *cli.Context) error {
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
 		ctx := context.Background()
 
 		l2RpcUrl := c.String("l2-rpc-url")
-		l2Client, _, err := internal.ConnectClient(ctx, l2RpcUrl)
+		l2Client, l2ChainId, err := internal.ConnectClient(ctx, c, l2RpcUrl, "l2-chain-id")
 		if err != nil {
 			return fmt.Errorf("could not connect to client at %s: %w", l2RpcUrl, err)
 		}
 
+		if err := internal.ValidateChainIds(c, nil, l2ChainId); err != nil {
+			return err
+		}
+
 		amount, err := internal.ParseUint256BigInt(c.String("amount"))
 		if err != nil {
 			return err
 		}
 
-		privateKey, err := crypto.HexToECDSA(c.String("private-key"))
+		l1GasLimit, err := internal.SafeParseUint32("l1-gas-limit", c.Uint64("l1-gas-limit"))
 		if err != nil {
-			return fmt.Errorf("failed to parse private-key: %w", err)
+			return err
+		}
+		if l1GasLimit == 0 {
+			return fmt.Errorf("l1-gas-limit must be greater than 0")
+		}
+
+		privateKey, err := internal.LoadSigner(c)
+		if err != nil {
+			return err
 		}
 
 		sender := crypto.PubkeyToAddress(privateKey.PublicKey)
@@ -71,55 +92,99 @@ var InitCommand = &cli.Command{
 			return fmt.Errorf("could not parse recipient address: %w", err)
 		}
 
-		log.Info("initiating withdrawal", "sender", sender, "receipient", recipient, "amount", amount)
+		// A contract recipient runs its own logic when the withdrawal is finalized, which may not fit in the default limit
+		if l1RpcUrl := c.String("l1-rpc-url"); l1RpcUrl != "" {
+			l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
+			if err != nil {
+				return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
+			}
+			if err := internal.ValidateChainIds(c, l1ChainId, l2ChainId); err != nil {
+				return err
+			}
 
-		l2ChainId, err := l2Client.ChainID(ctx)
-		if err != nil {
-			return fmt.Errorf("could not fetch l2 network id: %w", err)
-		}
+			readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+			defer cancel()
+			code, err := l1Client.CodeAt(readCtx, recipient, nil)
+			if err != nil {
+				return fmt.Errorf("could not fetch the L1 code of recipient %s: %w", recipient.Hex(), err)
+			}
 
-		l2StandardBridge, err := e2eBindings.NewL2StandardBridge(predeploys.L2StandardBridgeAddr, l2Client)
-		if err != nil {
-			return fmt.Errorf("could not not instantiate L2ToL1MessagePasser contract: %w", err)
+			if len(code) > 0 && l1GasLimit < internal.CONTRACT_RECEIVE_DEFAULT_GAS_LIMIT {
+				if c.IsSet("l1-gas-limit") {
+					log.Warn("l1-gas-limit looks too low for a contract recipient, finalizing the withdrawal may run out of gas on L1",
+						"recipient", recipient,
+						"l1GasLimit", l1GasLimit,
+						"suggested", internal.CONTRACT_RECEIVE_DEFAULT_GAS_LIMIT,
+					)
+				} else {
+					log.Info("recipient is a contract on L1, raising the l1-gas-limit", "recipient", recipient, "l1GasLimit", internal.CONTRACT_RECEIVE_DEFAULT_GAS_LIMIT)
+					l1GasLimit = internal.CONTRACT_RECEIVE_DEFAULT_GAS_LIMIT
+				}
+			}
+		} else {
+			log.Debug("l1-rpc-url not set, not checking whether the recipient is a contract")
 		}
 
-		l2ToL1MessagePasser, err := e2eBindings.NewL2ToL1MessagePasser(predeploys.L2ToL1MessagePasserAddr, l2Client)
+		result := &initResult{Sender: sender, Recipient: recipient, Amount: amount, L1GasLimit: l1GasLimit}
+		output.Result = result
+		output.SetAccount(sender)
+		output.Amount = amount
+
+		log.Info("initiating withdrawal", "sender", sender, "receipient", recipient, "amount", amount)
+
+		w, err := NewWithdrawer(nil, l2Client, nil, l2ChainId, internal.ZeroAddress, internal.ZeroAddress)
 		if err != nil {
-			return fmt.Errorf("could not not instantiate L2ToL1MessagePasser contract: %w", err)
+			return err
 		}
 
-		opts, err := bind.NewKeyedTransactorWithChainID(privateKey, l2ChainId)
+		opts, err := internal.NewTransactor(ctx, c, l2Client, privateKey, l2ChainId)
 		if err != nil {
-			return fmt.Errorf("could not setup transactor: %w", err)
+			return err
 		}
-		opts.Value = amount
 
-		tx, err := transactions.PadGasEstimate(opts, 1.5, func(opts *bind.TransactOpts) (*types.Transaction, error) {
-			return l2StandardBridge.BridgeETHTo(opts, recipient, RECEIVE_DEFAULT_GAS_LIMIT, []byte{})
-		})
-		if err != nil {
-			return fmt.Errorf("could not construct transaction to initiate withdrawal: %w", err)
+		err = w.Init(ctx, opts, result)
+		if result.Receipt != nil {
+			output.Primary = result.TxHash
+			output.AddTx(result.TxHash)
 		}
+		return err
+	}),
+}
 
-		log.Info("sent withdrawal initialization transaction", "tx", tx.Hash().Hex())
+// Init initiates the withdrawal of result.Amount from the account of opts to result.Recipient through the
+// L2StandardBridge, setting the transaction and the hash of the withdrawal on result
+func (w *Withdrawer) Init(ctx context.Context, opts *bind.TransactOpts, result *initResult) error {
+	opts.Value = result.Amount
+	receipt, err := internal.SendTransaction(ctx, w.l2Client, opts, "L2StandardBridge.BridgeETHTo", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return w.l2StandardBridge.BridgeETHTo(opts, result.Recipient, result.L1GasLimit, []byte{})
+	})
+	if err != nil {
+		return err
+	}
 
-		receipt, err := wait.ForReceiptOK(ctx, l2Client, tx.Hash())
-		if err != nil {
-			if statusErr, ok := err.(*wait.ReceiptStatusError); ok {
-				log.Error("bridgeETH transaction trace", "tx", tx.Hash().Hex(), "trace", statusErr.TxTrace)
-				return fmt.Errorf("failure in bridge transaction execution: %w", err)
-			} else {
-				return fmt.Errorf("failed to get bridge transaction receipt: %w", err)
-			}
-		}
+	result.TxHash = receipt.TxHash
+	result.Receipt = receipt
+	result.Gas = internal.NewGasReport(receipt)
 
-		messagePassedEvent, err := receipts.FindLog(receipt.Logs, l2ToL1MessagePasser.ParseMessagePassed)
-		if err != nil {
-			return fmt.Errorf("could not parse L2ToL1MessagePasser.MessagePassed event from the receipt logs: %w", err)
-		}
+	messagePassedEvent, err := receipts.FindLog(receipt.Logs, w.l2ToL1MessagePasser.ParseMessagePassed)
+	if err != nil {
+		return fmt.Errorf("could not parse L2ToL1MessagePasser.MessagePassed event from the receipt logs: %w", err)
+	}
 
-		log.Info("successfully initialized withdrawal", "withdrawalHash", common.Bytes2Hex(messagePassedEvent.WithdrawalHash[:]))
+	result.WithdrawalHash = messagePassedEvent.WithdrawalHash
 
-		return nil
-	},
+	log.Info("successfully initialized withdrawal", "withdrawalHash", common.Bytes2Hex(messagePassedEvent.WithdrawalHash[:]))
+
+	return nil
+}
+
+type initResult struct {
+	Sender         common.Address      `json:"sender"`
+	Recipient      common.Address      `json:"recipient"`
+	Amount         *big.Int            `json:"amount"`
+	L1GasLimit     uint32              `json:"l1GasLimit"`
+	TxHash         common.Hash         `json:"txHash"`
+	Receipt        *types.Receipt      `json:"receipt"`
+	Gas            *internal.GasReport `json:"gas,omitempty"`
+	WithdrawalHash common.Hash         `json:"withdrawalHash"`
 }
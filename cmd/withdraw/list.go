@@ -2,20 +2,31 @@ package withdraw_cmd
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/Golem-Base/op-probe/bindings"
 	"github.com/Golem-Base/op-probe/internal"
 	e2eBindings "github.com/ethereum-optimism/optimism/op-e2e/bindings"
 	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/receipts"
-	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
-	opNodePreviewBindings "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
-	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
 	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/urfave/cli/v2"
 )
@@ -31,231 +42,1125 @@ const (
 	Finalized
 )
 
+func (s WithdrawalStatus) String() string {
+	switch s {
+	case Initialized:
+		return "Initialized"
+	case Provable:
+		return "Provable"
+	case Proven:
+		return "Proven"
+	case ClaimResolved:
+		return "ClaimResolved"
+	case GameResolved:
+		return "GameResolved"
+	case Finalized:
+		return "Finalized"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(s))
+	}
+}
+
 var ListCommand = &cli.Command{
 	Name:  "list",
 	Usage: "Lists all ongoing withdrawals and their statuses (permissioned game)",
 	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "account",
+			Usage: "account to check for previous withdrawals, may be repeated or comma separated to list several accounts",
+		},
 		&cli.StringFlag{
-			Name:     "account",
-			Usage:    "account to check for previous withdrawals",
-			Required: true,
+			Name:  "accounts-file",
+			Usage: "Path to a file containing accounts to check for previous withdrawals, one per line",
+		},
+		&cli.BoolFlag{
+			Name:  "continue-on-error",
+			Usage: "Keep listing the remaining accounts when one of them fails, and report the failures once every account is listed",
 		},
 		&cli.StringFlag{
 			Name:     "l1-rpc-url",
-			Usage:    "Url for L1 execution client",
+			Usage:    "Url for L1 execution client, or comma separated urls to fail over between",
 			Required: true,
 		},
 		&cli.StringFlag{
 			Name:     "l2-rpc-url",
-			Usage:    "Url for L2 execution client",
+			Usage:    "Url for L2 execution client, or comma separated urls to fail over between",
 			Required: true,
 		},
 		&cli.StringFlag{
-			Name:     "dispute-game-factory-address",
-			Usage:    "Contract address for DisputeGameFactory (* or proxy)",
-			Required: true,
+			Name:  "dispute-game-factory-address",
+			Usage: "Contract address for DisputeGameFactory (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.Uint64Flag{
+			Name:  "game-type",
+			Usage: "Dispute game type the withdrawals are expected to be proven against, checked against the respected game type of the portal",
+			Value: uint64(internal.GameTypePermissioned),
 		},
 		&cli.StringFlag{
-			Name:     "optimism-portal-address",
-			Usage:    "Contract address for OptimismPortal (* or proxy)",
-			Required: true,
+			Name:  "optimism-portal-address",
+			Usage: "Contract address for OptimismPortal (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.Uint64Flag{
+			Name:  "at-block",
+			Usage: "L1 block number to read the portal and dispute game state at (default: latest)",
+		},
+		&cli.StringFlag{
+			Name:  "token",
+			Usage: "L2 token address to list withdrawals of (default: ETH), only with --source bridge",
+		},
+		&cli.StringFlag{
+			Name:  "source",
+			Usage: "Events the withdrawals are found by: bridge for L2StandardBridge.WithdrawalInitiated, or messagepasser for L2ToL1MessagePasser.MessagePassed sent by the account, covering withdrawals and messages that bypass the bridge",
+			Value: "bridge",
+		},
+		&cli.BoolFlag{
+			Name:  "rpc-batch",
+			Usage: "Read the state of the withdrawals with batched rpc requests instead of a request per call, falling back to single requests on endpoints rejecting batches",
+		},
+		&cli.IntFlag{
+			Name:  "concurrency",
+			Usage: "Maximum number of withdrawals whose state is fetched in parallel",
+			Value: 8,
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "How the withdrawals are reported, one of log (a log line per withdrawal), table (an aligned table on stdout), json, csv (a header row and a line per withdrawal on stdout) or prometheus-textfile (gauges written to --textfile for the node_exporter textfile collector)",
+			Value: "log",
+		},
+		&cli.StringFlag{
+			Name:  "textfile",
+			Usage: "Path of the .prom file written with --output prometheus-textfile, replaced atomically on every run",
+		},
+		&cli.BoolFlag{
+			Name:  "full-hashes",
+			Usage: "Show withdrawal hashes in full instead of a prefix with --output table",
+		},
+		&cli.StringFlag{
+			Name:  "order",
+			Usage: "Order the withdrawals of each account are reported in, one of oldest (oldest first) or newest (newest first)",
+			Value: "oldest",
+		},
+		&cli.IntFlag{
+			Name:  "max-withdrawals",
+			Usage: "Maximum number of withdrawals reported per account, the first ones in --order (default: all)",
+		},
+		&cli.BoolFlag{
+			Name:  "watch-new",
+			Usage: "Keep running after the listing and report every withdrawal initiated afterwards with its initial status, subscribing to the events or polling for them on rpcs without subscriptions. Only with --output log, json (a JSON object per line) or csv",
+		},
+		&cli.DurationFlag{
+			Name:  "poll-interval",
+			Usage: "Interval between checks for new withdrawals with --watch-new on rpcs without subscriptions",
+			Value: 12 * time.Second,
 		},
 	},
-	Action: func(c *cli.Context) error {
-		ctx := context.Background()
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := c.Context
+
+		accounts, err := readAccounts(c)
+		if err != nil {
+			return err
+		}
+		if len(accounts) == 1 {
+			output.SetAccount(accounts[0])
+		}
 
 		l1RpcUrl := c.String("l1-rpc-url")
-		l1Client, _, err := internal.ConnectClient(ctx, l1RpcUrl)
+		l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
 		if err != nil {
 			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
 		}
 
 		l2RpcUrl := c.String("l2-rpc-url")
-		l2Client, _, err := internal.ConnectClient(ctx, l2RpcUrl)
+		l2Client, l2ChainId, err := internal.ConnectClient(ctx, c, l2RpcUrl, "l2-chain-id")
 		if err != nil {
 			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
 		}
 
-		account, err := internal.SafeParseAddress(c.String("account"))
-		if err != nil {
-			return fmt.Errorf("could not parse account: %w", err)
+		if err := internal.ValidateChainIds(c, l1ChainId, l2ChainId); err != nil {
+			return err
 		}
 
-		disputeGameFactoryAddress, err := internal.SafeParseAddress(c.String("dispute-game-factory-address"))
+		// The initial reads share a deadline, each account and withdrawal scanned below gets its own
+		readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+		defer cancel()
+
+		// Portal and dispute game reads share the same CallOpts so that, when pinned to a block, the scan sees a consistent snapshot
+		callOpts := &bind.CallOpts{Context: readCtx}
+		if c.IsSet("at-block") {
+			head, err := l1Client.BlockNumber(readCtx)
+			if err != nil {
+				return fmt.Errorf("could not fetch L1 block number: %w", err)
+			}
+			if c.Uint64("at-block") > head {
+				return fmt.Errorf("at-block %d is above the current L1 head %d", c.Uint64("at-block"), head)
+			}
+			callOpts.BlockNumber = new(big.Int).SetUint64(c.Uint64("at-block"))
+			log.Info("reading L1 state at a pinned block", "block", callOpts.BlockNumber)
+		}
+
+		w, err := newFaultProofWithdrawer(ctx, c, l1Client, l2Client, l1ChainId, l2ChainId)
 		if err != nil {
-			return fmt.Errorf("could not parse DisputeGameFactory address: %w", err)
+			return err
 		}
-		disputeGameFactory, err := opNodeBindings.NewDisputeGameFactory(disputeGameFactoryAddress, l1Client)
+
+		gameType, err := internal.SafeParseUint32("game-type", c.Uint64("game-type"))
 		if err != nil {
-			return fmt.Errorf("could not instantiate DisputeGameFactory contract: %w", err)
+			return err
+		}
+		if err := internal.CheckRespectedGameType(callOpts, &w.optimismPortal.OptimismPortal2Caller, gameType, c.IsSet("game-type")); err != nil {
+			return err
 		}
 
-		optimismPortalAddress, err := internal.SafeParseAddress(c.String("optimism-portal-address"))
+		gameImplAddress, err := w.disputeGameFactory.GameImpls(callOpts, gameType)
 		if err != nil {
-			return fmt.Errorf("could not parse OptimismPortal address: %w", err)
+			return fmt.Errorf("could not fetch game implementation: %w", err)
+		}
+		if gameImplAddress == internal.ZeroAddress {
+			return fmt.Errorf("no implementation of game type %d set on DisputeGameFactory contract", gameType)
+		}
+
+		source := c.String("source")
+		if source != "bridge" && source != "messagepasser" {
+			return fmt.Errorf("unknown source %q, expected bridge or messagepasser", source)
+		}
+		if source == "messagepasser" && c.IsSet("token") {
+			return fmt.Errorf("token can only be used with --source bridge, the L2ToL1MessagePasser only carries ETH")
+		}
+
+		// ETH withdrawals are bridged with the zero address as L1 token, token withdrawals may have any L1 token
+		l1Tokens := []common.Address{internal.ZeroAddress}
+		l2Tokens := []common.Address{predeploys.LegacyERC20ETHAddr}
+		decimals := 18
+		if c.IsSet("token") {
+			l2Token, err := internal.SafeParseAddress(c.String("token"))
+			if err != nil {
+				return fmt.Errorf("could not parse token address: %w", err)
+			}
+			l1Tokens = nil
+			l2Tokens = []common.Address{l2Token}
+
+			token, err := e2eBindings.NewERC20(l2Token, l2Client)
+			if err != nil {
+				return fmt.Errorf("could not instantiate ERC20 contract: %w", err)
+			}
+			tokenDecimals, err := token.Decimals(&bind.CallOpts{Context: readCtx})
+			if err != nil {
+				return fmt.Errorf("could not fetch ERC20.Decimals of %s: %w", l2Token.Hex(), err)
+			}
+			decimals = int(tokenDecimals)
+		}
+
+		outputFormat := c.String("output")
+		if outputFormat != "log" && outputFormat != "table" && outputFormat != "json" && outputFormat != "csv" && outputFormat != "prometheus-textfile" {
+			return fmt.Errorf("unknown output %q, expected log, table, json, csv or prometheus-textfile", outputFormat)
+		}
+		if outputFormat == "prometheus-textfile" && c.String("textfile") == "" {
+			return fmt.Errorf("textfile must be set with --output prometheus-textfile")
+		}
+
+		concurrency := c.Int("concurrency")
+		if concurrency < 1 {
+			return fmt.Errorf("concurrency must be at least 1, got %d", concurrency)
+		}
+
+		order := c.String("order")
+		if order != "oldest" && order != "newest" {
+			return fmt.Errorf("unknown order %q, expected oldest or newest", order)
+		}
+		maxWithdrawals := c.Int("max-withdrawals")
+		if maxWithdrawals < 0 {
+			return fmt.Errorf("max-withdrawals must not be negative, got %d", maxWithdrawals)
+		}
+
+		watchNew := c.Bool("watch-new")
+		if watchNew {
+			if outputFormat != "log" && outputFormat != "json" && outputFormat != "csv" {
+				return fmt.Errorf("watch-new only works with --output log, json or csv")
+			}
+			if c.IsSet("at-block") {
+				return fmt.Errorf("watch-new cannot be combined with at-block, new withdrawals are read at the latest block")
+			}
+			if c.Duration("poll-interval") <= 0 {
+				return fmt.Errorf("poll-interval must be greater than 0")
+			}
+		}
+
+		// New withdrawals are watched for from the block after the L2 head seen before the listing, those listed
+		// already are skipped
+		var watchFrom uint64
+		if watchNew {
+			l2Head, err := l2Client.BlockNumber(readCtx)
+			if err != nil {
+				return fmt.Errorf("could not fetch L2 block number: %w", err)
+			}
+			watchFrom = l2Head + 1
 		}
-		optimismPortal, err := opNodePreviewBindings.NewOptimismPortal2(optimismPortalAddress, l1Client)
+
+		l, err := w.newLister(callOpts)
 		if err != nil {
-			return fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+			return err
+		}
+		l.source = source
+		l.l1Tokens = l1Tokens
+		l.l2Tokens = l2Tokens
+		l.concurrency = concurrency
+		l.newestFirst = order == "newest"
+		l.maxWithdrawals = maxWithdrawals
+		if c.Bool("rpc-batch") {
+			l.l1Batch = internal.NewBatchCaller(l1Client, callOpts.BlockNumber)
+			l.l2Batch = internal.NewBatchCaller(l2Client, nil)
+		}
+
+		// Every account is listed the same way, a failing account stops the run unless --continue-on-error is set
+		results := make([]*accountListings, 0, len(accounts))
+		var errs []error
+		for _, account := range accounts {
+			listings, err := l.listAccount(ctx, account)
+			if err != nil && !c.Bool("continue-on-error") {
+				if len(accounts) == 1 {
+					return err
+				}
+				return fmt.Errorf("account %s: %w", account.Hex(), err)
+			}
+			result := &accountListings{Account: account, Withdrawals: listings}
+			if err != nil {
+				log.Error("failed to list withdrawals, continuing with the remaining accounts", "account", account, "error", err)
+				result.Error = err.Error()
+				errs = append(errs, fmt.Errorf("account %s: %w", account.Hex(), err))
+			}
+			results = append(results, result)
+		}
+
+		// A single account keeps reporting its withdrawals alone, several are reported grouped by account
+		var report any = results
+		if len(accounts) == 1 {
+			report = results[0].Withdrawals
 		}
+		output.Result = report
 
-		l2StandardBridgeFilterer, err := e2eBindings.NewL2StandardBridgeFilterer(predeploys.L2StandardBridgeAddr, l2Client)
+		switch outputFormat {
+		case "table", "csv":
+			var listings []*withdrawalListing
+			for _, result := range results {
+				listings = append(listings, result.Withdrawals...)
+			}
+			if outputFormat == "csv" {
+				err = printListingCSV(listings, decimals)
+			} else {
+				err = printListingTable(listings, decimals, c.Bool("full-hashes"))
+			}
+			if err != nil {
+				return err
+			}
+		case "json":
+			if err := internal.PrintJSON(report); err != nil {
+				return err
+			}
+		case "prometheus-textfile":
+			if err := writeListingTextfile(ctx, c.String("textfile"), l1Client, l2Client, results); err != nil {
+				return err
+			}
+		default:
+			output.Primary = report
+
+			for _, result := range results {
+				for _, listing := range result.Withdrawals {
+					logListing(listing, decimals, l.proofMaturityDelaySeconds)
+				}
+			}
+		}
+
+		if len(accounts) > 1 {
+			for _, result := range results {
+				result.logSummary()
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%d of %d accounts failed to be listed: %w", len(errs), len(results), errors.Join(errs...))
+		}
+
+		if watchNew {
+			seen := make(map[common.Hash]bool)
+			for _, result := range results {
+				for _, listing := range result.Withdrawals {
+					seen[listing.WithdrawalHash] = true
+				}
+			}
+			return l.watchNew(ctx, accounts, watchFrom, c.Duration("poll-interval"), seen, func(listing *withdrawalListing) error {
+				switch outputFormat {
+				case "json":
+					return json.NewEncoder(os.Stdout).Encode(listing)
+				case "csv":
+					w := csv.NewWriter(os.Stdout)
+					if err := w.Write(listingCSVRecord(listing, decimals)); err != nil {
+						return fmt.Errorf("could not write csv record: %w", err)
+					}
+					w.Flush()
+					return w.Error()
+				default:
+					logListing(listing, decimals, l.proofMaturityDelaySeconds)
+					return nil
+				}
+			})
+		}
+
+		return nil
+	}),
+}
+
+// lister holds the delays and settings shared across every account listed in a single invocation
+type lister struct {
+	*Withdrawer
+	blockNumber               *big.Int
+	source                    string
+	l1Tokens                  []common.Address
+	l2Tokens                  []common.Address
+	proofMaturityDelaySeconds *big.Int
+	finalityDelaySeconds      *big.Int
+	concurrency               int
+	// newestFirst and maxWithdrawals are the --order and --max-withdrawals of the listing, a zero maxWithdrawals
+	// lists every withdrawal
+	newestFirst    bool
+	maxWithdrawals int
+	// l1Batch and l2Batch are set with --rpc-batch to read the withdrawals with batched requests
+	l1Batch *internal.BatchCaller
+	l2Batch *internal.BatchCaller
+}
+
+// newLister returns a lister of the ETH withdrawals sent through the L2StandardBridge, reading the portal and dispute
+// games with opts, one withdrawal at a time and oldest first
+func (w *Withdrawer) newLister(opts *bind.CallOpts) (*lister, error) {
+	proofMaturityDelaySeconds, err := w.optimismPortal.ProofMaturityDelaySeconds(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not call OptimismPortal.ProofMaturityDelaySeconds: %w", err)
+	}
+	finalityDelaySeconds, err := w.optimismPortal.DisputeGameFinalityDelaySeconds(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not call OptimismPortal.DisputeGameFinalityDelaySeconds: %w", err)
+	}
+
+	return &lister{
+		Withdrawer:                w,
+		blockNumber:               opts.BlockNumber,
+		source:                    "bridge",
+		l1Tokens:                  []common.Address{internal.ZeroAddress},
+		l2Tokens:                  []common.Address{predeploys.LegacyERC20ETHAddr},
+		proofMaturityDelaySeconds: proofMaturityDelaySeconds,
+		finalityDelaySeconds:      finalityDelaySeconds,
+		concurrency:               1,
+	}, nil
+}
+
+// List lists the ETH withdrawals account sent through the L2StandardBridge along with their status
+func (w *Withdrawer) List(ctx context.Context, account common.Address) ([]*withdrawalListing, error) {
+	if w.optimismPortal == nil {
+		return nil, fmt.Errorf("withdrawer has no OptimismPortal to read the withdrawal status from")
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+	l, err := w.newLister(&bind.CallOpts{Context: readCtx})
+	if err != nil {
+		return nil, err
+	}
+	return l.listAccount(ctx, account)
+}
+
+// accountListings is the outcome of listing the withdrawals of a single account
+type accountListings struct {
+	Account     common.Address       `json:"account"`
+	Withdrawals []*withdrawalListing `json:"withdrawals"`
+	Error       string               `json:"error,omitempty"`
+}
+
+// logSummary logs the number of withdrawals of the account by status
+func (a *accountListings) logSummary() {
+	if a.Error != "" {
+		log.Info("account summary", "account", a.Account, "result", "failed", "error", a.Error)
+		return
+	}
+	counts := make(map[WithdrawalStatus]int)
+	for _, listing := range a.Withdrawals {
+		counts[listing.Status]++
+	}
+	fields := []any{"account", a.Account, "withdrawals", len(a.Withdrawals)}
+	for status := Initialized; status <= Finalized; status++ {
+		fields = append(fields, strings.ToLower(status.String()), counts[status])
+	}
+	log.Info("account summary", fields...)
+}
+
+// listAccount scans the withdrawals initiated by account and fetches the state of each of them, sorted by block in
+// the order of the listing
+func (l *lister) listAccount(ctx context.Context, account common.Address) ([]*withdrawalListing, error) {
+	var events []*withdrawalEvent
+	err := internal.ScanLogs(ctx, l.l2Client, 0, func(opts *bind.FilterOpts) (bool, error) {
+		chunk, err := l.filterEvents(opts, []common.Address{account})
+		events = append(events, chunk...)
+		return false, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The cap is applied to the events, so that only the state of the withdrawals reported is fetched
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Raw.BlockNumber != events[j].Raw.BlockNumber {
+			return events[i].Raw.BlockNumber < events[j].Raw.BlockNumber
+		}
+		return events[i].Raw.Index < events[j].Raw.Index
+	})
+	if l.maxWithdrawals > 0 && len(events) > l.maxWithdrawals {
+		log.Info("capping the withdrawals listed", "account", account, "withdrawals", len(events), "max", l.maxWithdrawals)
+		if l.newestFirst {
+			events = events[len(events)-l.maxWithdrawals:]
+		} else {
+			events = events[:l.maxWithdrawals]
+		}
+	}
+
+	listings, err := l.fetchListings(ctx, account, events)
+	if err != nil {
+		return nil, err
+	}
+	if l.newestFirst {
+		slices.Reverse(listings)
+	}
+	return listings, nil
+}
+
+// fetchListings fetches the state of the withdrawals of account found by the events, sorted by block
+func (l *lister) fetchListings(ctx context.Context, account common.Address, events []*withdrawalEvent) ([]*withdrawalListing, error) {
+	// The game lookup has its own deadline, and so does each withdrawal fetched below
+	readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+	callOpts := &bind.CallOpts{Context: readCtx, BlockNumber: l.blockNumber}
+
+	// The latest game is looked up once the withdrawals are known, so that a game proposed just now for the most
+	// recent withdrawal is waited for briefly
+	latestWithdrawalBlock := uint64(0)
+	for _, event := range events {
+		latestWithdrawalBlock = max(latestWithdrawalBlock, event.Raw.BlockNumber)
+	}
+
+	// Without any game none of the withdrawals is provable yet, which is still worth listing
+	gameL2BlockNumber := new(big.Int)
+	game, err := internal.FindLatestGameCovering(callOpts, &l.disputeGameFactory.DisputeGameFactoryCaller, &l.optimismPortal.OptimismPortal2Caller, latestWithdrawalBlock)
+	if errors.Is(err, internal.ErrNoGames) {
+		log.Warn("no dispute games have been proposed yet, none of the withdrawals is provable")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to find latest game: %w", err)
+	} else {
+		gameL2BlockNumber, err = internal.SearchResultL2BlockNumber(game)
 		if err != nil {
-			return fmt.Errorf("could not instantiate L2StandardBridge filterer")
+			return nil, err
 		}
+		log.Info("Found latest game", "game", game.Index, "l2Block", gameL2BlockNumber, "timestamp", time.Unix(int64(game.Timestamp), 0))
+	}
 
-		permissionedDisputeGameAddress, err := disputeGameFactory.GameImpls(&bind.CallOpts{}, 1)
+	var listings []*withdrawalListing
+	if l.l1Batch != nil {
+		listings, err = l.fetchListingsBatched(ctx, account, gameL2BlockNumber, events)
 		if err != nil {
-			return fmt.Errorf("could not fetch game implementation: %w", err)
+			return nil, err
+		}
+	} else {
+		// Withdrawals are fetched by a bounded pool of workers, each result is stored at the index of its event
+		listings = make([]*withdrawalListing, len(events))
+		errs := make([]error, len(events))
+		sem := make(chan struct{}, l.concurrency)
+		var wg sync.WaitGroup
+		for i, event := range events {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				listings[i], errs[i] = l.fetchListing(ctx, account, gameL2BlockNumber, event)
+			}()
 		}
-		if permissionedDisputeGameAddress == internal.ZeroAddress {
-			return fmt.Errorf("PermissionedDisputeGame not set on DisputeGameFactory contract")
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			return nil, err
 		}
+	}
 
-		l2ToL1MessagePasser, err := e2eBindings.NewL2ToL1MessagePasser(predeploys.L2ToL1MessagePasserAddr, l2Client)
+	sort.SliceStable(listings, func(i, j int) bool {
+		if listings[i].Block != listings[j].Block {
+			return listings[i].Block < listings[j].Block
+		}
+		return listings[i].LogIndex < listings[j].LogIndex
+	})
+
+	return listings, nil
+}
+
+// filterEvents scans the withdrawals initiated by accounts within the blocks of opts from the events of the source
+func (l *lister) filterEvents(opts *bind.FilterOpts, accounts []common.Address) ([]*withdrawalEvent, error) {
+	var events []*withdrawalEvent
+	if l.source == "messagepasser" {
+		iterator, err := l.l2ToL1MessagePasser.FilterMessagePassed(
+			opts,
+			nil,
+			accounts,
+			nil,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("could not filter MessagePassed events: %w", err)
+		}
+		for iterator.Next() {
+			event := iterator.Event
+			events = append(events, &withdrawalEvent{
+				From:          event.Sender,
+				To:            event.Target,
+				Amount:        event.Value,
+				Raw:           event.Raw,
+				MessagePassed: event,
+			})
+		}
+		if err := iterator.Error(); err != nil {
+			return nil, fmt.Errorf("Found error while iterating through events: %w", err)
+		}
+	} else {
+		iterator, err := l.l2StandardBridge.FilterWithdrawalInitiated(
+			opts,
+			l.l1Tokens,
+			l.l2Tokens,
+			accounts,
+		)
 		if err != nil {
-			return fmt.Errorf("could not not instantiate L2ToL1MessagePasser contract: %w", err)
+			return nil, fmt.Errorf("could not filter WithdrawalInitiated events: %w", err)
+		}
+		for iterator.Next() {
+			event := iterator.Event
+			events = append(events, &withdrawalEvent{
+				From:    event.From,
+				To:      event.To,
+				L1Token: event.L1Token,
+				L2Token: event.L2Token,
+				Amount:  event.Amount,
+				Raw:     event.Raw,
+			})
 		}
+		if err := iterator.Error(); err != nil {
+			return nil, fmt.Errorf("Found error while iterating through events: %w", err)
+		}
+	}
+	return events, nil
+}
+
+// fetchListing fetches the state of a single withdrawal of account, which is provable once gameL2BlockNumber covers
+// its block
+func (l *lister) fetchListing(ctx context.Context, account common.Address, gameL2BlockNumber *big.Int, event *withdrawalEvent) (*withdrawalListing, error) {
+	status := Initialized
 
-		proofMaturityDelaySeconds, err := optimismPortal.ProofMaturityDelaySeconds(&bind.CallOpts{})
+	listingCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+	opts := &bind.CallOpts{Context: listingCtx, BlockNumber: l.blockNumber}
+
+	messagePassedEvent := event.MessagePassed
+	if messagePassedEvent == nil {
+		receipt, err := l.l2Client.TransactionReceipt(listingCtx, event.Raw.TxHash)
 		if err != nil {
-			return fmt.Errorf("could not call OptimismPortal.ProofMaturityDelaySeconds: %w", err)
+			return nil, fmt.Errorf("could not get receipt for withdrawal event in transaction: %s: %w", event.Raw.TxHash.Hex(), err)
 		}
 
-		game, err := withdrawals.FindLatestGame(ctx, &disputeGameFactory.DisputeGameFactoryCaller, &optimismPortal.OptimismPortal2Caller)
+		messagePassedEvent, err = receipts.FindLog(receipt.Logs, l.l2ToL1MessagePasser.ParseMessagePassed)
 		if err != nil {
-			return fmt.Errorf("failed to find latest game: %w", err)
+			return nil, fmt.Errorf("could not parse L2ToL1MessagePasser.MessagePassed event from the receipt logs: %w", err)
 		}
+	}
 
-		gameL2BlockNumber := new(big.Int).SetBytes(game.ExtraData[0:32])
+	if gameL2BlockNumber.Uint64() >= event.Raw.BlockNumber {
+		status = Provable
+	}
 
-		log.Info("Found latest game", "game", game.Index, "l2Block", gameL2BlockNumber, "timestamp", time.Unix(int64(game.Timestamp), 0))
+	listing := newListing(event, messagePassedEvent)
 
-		iterator, err := l2StandardBridgeFilterer.FilterWithdrawalInitiated(
-			&bind.FilterOpts{Context: ctx, Start: 0, End: nil},
-			[]common.Address{internal.ZeroAddress},
-			[]common.Address{predeploys.LegacyERC20ETHAddr},
-			[]common.Address{account},
+	timestamp := uint64(0)
+
+	if status == Provable {
+		proven, err := l.optimismPortal.ProvenWithdrawals(
+			opts,
+			messagePassedEvent.WithdrawalHash,
+			account, // TODO This is a simplified lookup and a more robust approach would be to filter by event for WithdrawalProven events
 		)
-		for iterator.Next() {
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch proven withdrawal: %w", err)
+		}
 
-			status := Initialized
+		if proven.DisputeGameProxy != common.BytesToAddress([]byte{0}) {
+			status = Proven
+			timestamp = proven.Timestamp
 
-			event := iterator.Event
+			permissionedDisputeGame, err := bindings.NewPermissionedDisputeGame(proven.DisputeGameProxy, l.l1Client)
+			if err != nil {
+				return nil, fmt.Errorf("could not construct permissioned dispute game")
+			}
 
-			receipt, err := l2Client.TransactionReceipt(ctx, event.Raw.TxHash)
+			created_at, err := permissionedDisputeGame.CreatedAt(opts)
 			if err != nil {
-				return fmt.Errorf("could not get receipt for withdrawal event in transaction: %s: %w", event.Raw.TxHash.Hex(), err)
+				return nil, fmt.Errorf("could not fetch DisputeGame.CreatedAt: %w", err)
 			}
+			listing.CreatedAtTime = time.Unix(int64(created_at), 0)
 
-			messagePassedEvent, err := receipts.FindLog(receipt.Logs, l2ToL1MessagePasser.ParseMessagePassed)
+			disputeGameStatus, err := permissionedDisputeGame.Status(opts)
 			if err != nil {
-				return fmt.Errorf("could not parse L2ToL1MessagePasser.MessagePassed event from the receipt logs: %w", err)
+				return nil, fmt.Errorf("could not fetch DisputeGame.Status: %w", err)
 			}
+			listing.DisputeGameStatus = internal.GameStatus(disputeGameStatus)
 
-			if gameL2BlockNumber.Uint64() >= receipt.BlockNumber.Uint64() {
-				status = Provable
+			_maxClockDuration, err := permissionedDisputeGame.MaxClockDuration(opts)
+			if err != nil {
+				return nil, fmt.Errorf("PermissionedDisputeGame.GetChallengerDuration failed: %w", err)
 			}
+			listing.MaxClockDuration = time.Duration(_maxClockDuration * uint64(time.Second))
 
-			timestamp := uint64(0)
-			var created_at_time time.Time
-			disputeGameStatus := uint8(0)
-			isClaimResolved := false
-			challengerDuration := time.Duration(0)
-			maxClockDuration := time.Duration(0)
+			_challengerDuration, err := permissionedDisputeGame.GetChallengerDuration(opts, internal.RootClaimIndex)
+			if err != nil {
+				return nil, fmt.Errorf("PermissionedDisputeGame.GetChallengerDuration failed: %w", err)
+			}
+			listing.ChallengerDuration = time.Duration(_challengerDuration * uint64(time.Second))
 
-			if status == Provable {
-				proven, err := optimismPortal.ProvenWithdrawals(
-					&bind.CallOpts{},
-					messagePassedEvent.WithdrawalHash,
-					account, // TODO This is a simplified lookup and a more robust approach would be to filter by event for WithdrawalProven events
-				)
-				if err != nil {
-					return fmt.Errorf("could not fetch proven withdrawal: %w", err)
-				}
+			listing.IsClaimResolved, err = permissionedDisputeGame.ResolvedSubgames(opts, internal.RootClaimIndex)
+			if err != nil {
+				return nil, fmt.Errorf("PermissionedDisputeGame.ResolvedSubgame failed: %w", err)
+			}
 
-				if proven.DisputeGameProxy != common.BytesToAddress([]byte{0}) {
-					status = Proven
-					timestamp = proven.Timestamp
+			resolvedAt, err := permissionedDisputeGame.ResolvedAt(opts)
+			if err != nil {
+				return nil, fmt.Errorf("could not fetch DisputeGame.ResolvedAt: %w", err)
+			}
 
-					permissionedDisputeGame, err := bindings.NewPermissionedDisputeGame(proven.DisputeGameProxy, l1Client)
-					if err != nil {
-						return fmt.Errorf("could not construct permissioned dispute game")
-					}
+			if listing.IsClaimResolved {
+				status = ClaimResolved
+			}
+			if resolvedAt != 0 {
+				status = GameResolved
+				listing.GameResolvedTime = time.Unix(int64(resolvedAt), 0)
+			}
 
-					created_at, err := permissionedDisputeGame.CreatedAt(&bind.CallOpts{})
-					if err != nil {
-						return fmt.Errorf("could not fetch DisputeGame.CreatedAt: %w", err)
-					}
-					created_at_time = time.Unix(int64(created_at), 0)
+			finalized, err := l.optimismPortal.FinalizedWithdrawals(opts, messagePassedEvent.WithdrawalHash)
+			if err != nil {
+				return nil, fmt.Errorf("could not fetch OptimismPortal.FinalizedWithdrawals: %w", err)
+			}
+			if finalized {
+				status = Finalized
+			}
 
-					disputeGameStatus, err = permissionedDisputeGame.Status(&bind.CallOpts{})
-					if err != nil {
-						return fmt.Errorf("could not fetch DisputeGame.Status: %w", err)
-					}
+		}
+	}
 
-					_maxClockDuration, err := permissionedDisputeGame.MaxClockDuration(&bind.CallOpts{})
-					if err != nil {
-						return fmt.Errorf("PermissionedDisputeGame.GetChallengerDuration failed: %w", err)
-					}
-					maxClockDuration = time.Duration(_maxClockDuration * uint64(time.Second))
+	l.finishListing(listing, status, timestamp)
+	return listing, nil
+}
 
-					_challengerDuration, err := permissionedDisputeGame.GetChallengerDuration(&bind.CallOpts{}, common.Big0)
-					if err != nil {
-						return fmt.Errorf("PermissionedDisputeGame.GetChallengerDuration failed: %w", err)
-					}
-					challengerDuration = time.Duration(_challengerDuration * uint64(time.Second))
+// newListing returns the listing of a withdrawal with the fields known from its events
+func newListing(event *withdrawalEvent, messagePassedEvent *e2eBindings.L2ToL1MessagePasserMessagePassed) *withdrawalListing {
+	return &withdrawalListing{
+		From:           event.From,
+		To:             event.To,
+		L1Token:        event.L1Token,
+		L2Token:        event.L2Token,
+		Amount:         event.Amount,
+		Nonce:          DecodeVersionedNonce(messagePassedEvent.Nonce),
+		Block:          event.Raw.BlockNumber,
+		LogIndex:       event.Raw.Index,
+		TxHash:         event.Raw.TxHash,
+		WithdrawalHash: messagePassedEvent.WithdrawalHash,
+	}
+}
 
-					isClaimResolved, err = permissionedDisputeGame.ResolvedSubgames(&bind.CallOpts{}, common.Big0)
-					if err != nil {
-						return fmt.Errorf("PermissionedDisputeGame.ResolvedSubgame failed: %w", err)
-					}
+// finishListing sets the status of listing and the times derived from the timestamp it was proven at. Like finalize,
+// a proven withdrawal is finalizable once its proof has matured and its game has been resolved for the finality delay,
+// a game not resolved yet is expected to resolve when the challenger clock of its root claim expires.
+func (l *lister) finishListing(listing *withdrawalListing, status WithdrawalStatus, timestamp uint64) {
+	listing.Status = status
+	listing.ProvenTime = time.Unix(int64(timestamp), 0)
+	listing.FinalizableTime = time.Unix(int64(timestamp)+l.proofMaturityDelaySeconds.Int64(), 0)
 
-					if isClaimResolved {
-						status = ClaimResolved
-					}
+	if status >= Proven && status != Finalized {
+		resolvedAt := listing.GameResolvedTime
+		if status != GameResolved {
+			resolvedAt = time.Now().Add(max(listing.MaxClockDuration-listing.ChallengerDuration, 0))
+		}
+		finalityDelayTime := resolvedAt.Add(time.Duration(l.finalityDelaySeconds.Int64() * int64(time.Second)))
+		if finalityDelayTime.After(listing.FinalizableTime) {
+			listing.FinalizableTime = finalityDelayTime
+		}
+		listing.FinalizableIn = time.Until(listing.FinalizableTime)
+	}
+	listing.ReproveRequired = status != Finalized && listing.DisputeGameStatus == internal.GameStatusChallengerWins
+}
 
-				}
+// fetchListingsBatched fetches the state of the withdrawals of account like fetchListing, with the reads of all
+// withdrawals grouped into batched rpc requests: the receipts first, then the proven withdrawals, then the state of
+// the games they were proven against
+func (l *lister) fetchListingsBatched(ctx context.Context, account common.Address, gameL2BlockNumber *big.Int, events []*withdrawalEvent) ([]*withdrawalListing, error) {
+	portalAbi, err := bindingspreview.OptimismPortal2MetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse OptimismPortal2 abi: %w", err)
+	}
+	gameAbi, err := bindings.PermissionedDisputeGameMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse PermissionedDisputeGame abi: %w", err)
+	}
+
+	// Only withdrawals found through the bridge need their receipt for the MessagePassed event
+	messagePassedEvents := make([]*e2eBindings.L2ToL1MessagePasserMessagePassed, len(events))
+	var missing []int
+	var txHashes []common.Hash
+	for i, event := range events {
+		messagePassedEvents[i] = event.MessagePassed
+		if event.MessagePassed == nil {
+			missing = append(missing, i)
+			txHashes = append(txHashes, event.Raw.TxHash)
+		}
+	}
+	if len(txHashes) > 0 {
+		receiptsCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+		defer cancel()
+		withdrawalReceipts, err := l.l2Batch.Receipts(receiptsCtx, txHashes)
+		if err != nil {
+			return nil, fmt.Errorf("could not get receipts for withdrawal events: %w", err)
+		}
+		for j, i := range missing {
+			messagePassedEvents[i], err = receipts.FindLog(withdrawalReceipts[j].Logs, l.l2ToL1MessagePasser.ParseMessagePassed)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse L2ToL1MessagePasser.MessagePassed event from the receipt logs of %s: %w", txHashes[j].Hex(), err)
 			}
+		}
+	}
 
-			nonce := DecodeVersionedNonce(messagePassedEvent.Nonce)
-
-			withdrawalHash := common.Bytes2Hex(messagePassedEvent.WithdrawalHash[:])
-			provenTime := time.Unix(int64(timestamp), 0)
-			finalizableTime := time.Unix(int64(timestamp)+proofMaturityDelaySeconds.Int64(), 0)
-
-			secondsUntilWithdrawalFinalization := time.Duration(0)
-			if status == Proven {
-				secondsUntilWithdrawalFinalization = finalizableTime.Sub(time.Now())
-			}
-
-			log.Info(fmt.Sprintf("Withdrawal: %s", nonce),
-				"from", event.From,
-				"to", event.To,
-				"l1Token", event.L1Token,
-				"l2Token", event.L2Token,
-				"amount", internal.FormatWei(event.Amount),
-				"block", receipt.BlockNumber.Uint64(),
-				"withdrawalHash", withdrawalHash,
-				"transactionHash", event.Raw.TxHash.Hex(),
-				"status", status,
-				"timestamp_proven", provenTime,
-				"timestamp_created_at", created_at_time,
-				"timestamp_finalizable", finalizableTime,
-				"finalizable_in", secondsUntilWithdrawalFinalization,
-				"proof_maturity_delay", time.Duration(proofMaturityDelaySeconds.Int64()*int64(time.Second)),
-				"isClaimResolved", isClaimResolved,
-				"challengerDuration", challengerDuration,
-				"maxClockDuration", maxClockDuration,
-				"disputeGameStatus", disputeGameStatus,
-			)
+	listings := make([]*withdrawalListing, len(events))
+	statuses := make([]WithdrawalStatus, len(events))
+	timestamps := make([]uint64, len(events))
+	var provable []int
+	var provenCalls []*internal.BatchCall
+	for i, event := range events {
+		listings[i] = newListing(event, messagePassedEvents[i])
+		if gameL2BlockNumber.Uint64() >= event.Raw.BlockNumber {
+			statuses[i] = Provable
+			provable = append(provable, i)
+			provenCalls = append(provenCalls, &internal.BatchCall{
+				To:     l.optimismPortalAddress,
+				ABI:    portalAbi,
+				Method: "provenWithdrawals",
+				Args:   []any{messagePassedEvents[i].WithdrawalHash, account},
+			})
 		}
-		if err := iterator.Error(); err != nil {
-			return fmt.Errorf("Found error while iterating through events: %w", err)
+	}
+
+	provenCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+	if err := l.l1Batch.Call(provenCtx, provenCalls); err != nil {
+		return nil, fmt.Errorf("could not fetch proven withdrawals: %w", err)
+	}
+
+	// Every proven withdrawal needs the same seven reads of its game and the portal, queued in this order
+	const gameReads = 7
+	var proven []int
+	var gameCalls []*internal.BatchCall
+	for j, i := range provable {
+		disputeGameProxy := *abi.ConvertType(provenCalls[j].Out[0], new(common.Address)).(*common.Address)
+		if disputeGameProxy == internal.ZeroAddress {
+			continue
 		}
+		statuses[i] = Proven
+		timestamps[i] = *abi.ConvertType(provenCalls[j].Out[1], new(uint64)).(*uint64)
+		proven = append(proven, i)
+		gameCalls = append(gameCalls,
+			&internal.BatchCall{To: disputeGameProxy, ABI: gameAbi, Method: "createdAt"},
+			&internal.BatchCall{To: disputeGameProxy, ABI: gameAbi, Method: "status"},
+			&internal.BatchCall{To: disputeGameProxy, ABI: gameAbi, Method: "maxClockDuration"},
+			&internal.BatchCall{To: disputeGameProxy, ABI: gameAbi, Method: "getChallengerDuration", Args: []any{internal.RootClaimIndex}},
+			&internal.BatchCall{To: disputeGameProxy, ABI: gameAbi, Method: "resolvedSubgames", Args: []any{internal.RootClaimIndex}},
+			&internal.BatchCall{To: disputeGameProxy, ABI: gameAbi, Method: "resolvedAt"},
+			&internal.BatchCall{To: l.optimismPortalAddress, ABI: portalAbi, Method: "finalizedWithdrawals", Args: []any{messagePassedEvents[i].WithdrawalHash}},
+		)
+	}
 
-		return nil
-	},
+	gameCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+	if err := l.l1Batch.Call(gameCtx, gameCalls); err != nil {
+		return nil, fmt.Errorf("could not fetch dispute game state: %w", err)
+	}
+
+	for j, i := range proven {
+		calls := gameCalls[j*gameReads : (j+1)*gameReads]
+		listing := listings[i]
+
+		listing.CreatedAtTime = time.Unix(int64(*abi.ConvertType(calls[0].Out[0], new(uint64)).(*uint64)), 0)
+		listing.DisputeGameStatus = internal.GameStatus(*abi.ConvertType(calls[1].Out[0], new(uint8)).(*uint8))
+		listing.MaxClockDuration = time.Duration(*abi.ConvertType(calls[2].Out[0], new(uint64)).(*uint64) * uint64(time.Second))
+		listing.ChallengerDuration = time.Duration(*abi.ConvertType(calls[3].Out[0], new(uint64)).(*uint64) * uint64(time.Second))
+		listing.IsClaimResolved = *abi.ConvertType(calls[4].Out[0], new(bool)).(*bool)
+
+		resolvedAt := *abi.ConvertType(calls[5].Out[0], new(uint64)).(*uint64)
+
+		if listing.IsClaimResolved {
+			statuses[i] = ClaimResolved
+		}
+		if resolvedAt != 0 {
+			statuses[i] = GameResolved
+			listing.GameResolvedTime = time.Unix(int64(resolvedAt), 0)
+		}
+		if *abi.ConvertType(calls[6].Out[0], new(bool)).(*bool) {
+			statuses[i] = Finalized
+		}
+	}
+
+	for i, listing := range listings {
+		l.finishListing(listing, statuses[i], timestamps[i])
+	}
+	return listings, nil
+}
+
+// readAccounts collects the addresses passed with --account and --accounts-file
+func readAccounts(c *cli.Context) ([]common.Address, error) {
+	var accounts []common.Address
+	for _, account := range c.StringSlice("account") {
+		address, err := internal.SafeParseAddress(account)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse account: %w", err)
+		}
+		accounts = append(accounts, address)
+	}
+
+	if path := c.String("accounts-file"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read accounts file %s: %w", path, err)
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			address, err := internal.SafeParseAddress(line)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %s line %d: %w", path, i+1, err)
+			}
+			accounts = append(accounts, address)
+		}
+	}
+
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("at least one account must be provided with --account or --accounts-file")
+	}
+
+	return accounts, nil
+}
+
+// withdrawalEvent is a withdrawal found by the event scan of the list command
+type withdrawalEvent struct {
+	From    common.Address
+	To      common.Address
+	L1Token common.Address
+	L2Token common.Address
+	Amount  *big.Int
+	Raw     types.Log
+	// MessagePassed is set when the withdrawal was found through the L2ToL1MessagePasser, otherwise it is read from
+	// the receipt of the withdrawal transaction
+	MessagePassed *e2eBindings.L2ToL1MessagePasserMessagePassed
+}
+
+// withdrawalListing is the state of a single withdrawal as reported by the list command
+type withdrawalListing struct {
+	From               common.Address      `json:"from"`
+	To                 common.Address      `json:"to"`
+	L1Token            common.Address      `json:"l1Token"`
+	L2Token            common.Address      `json:"l2Token"`
+	Amount             *big.Int            `json:"amount"`
+	Nonce              *big.Int            `json:"nonce"`
+	Block              uint64              `json:"block"`
+	LogIndex           uint                `json:"logIndex"`
+	TxHash             common.Hash         `json:"transactionHash"`
+	WithdrawalHash     common.Hash         `json:"withdrawalHash"`
+	Status             WithdrawalStatus    `json:"status"`
+	ProvenTime         time.Time           `json:"provenAt"`
+	CreatedAtTime      time.Time           `json:"gameCreatedAt"`
+	GameResolvedTime   time.Time           `json:"gameResolvedAt"`
+	FinalizableTime    time.Time           `json:"finalizableAt"`
+	FinalizableIn      time.Duration       `json:"finalizableIn"`
+	IsClaimResolved    bool                `json:"isClaimResolved"`
+	ChallengerDuration time.Duration       `json:"challengerDuration"`
+	MaxClockDuration   time.Duration       `json:"maxClockDuration"`
+	DisputeGameStatus  internal.GameStatus `json:"disputeGameStatus"`
+	// ReproveRequired is set for a withdrawal proven against a game the challenger won, which can never be finalized
+	ReproveRequired bool `json:"reproveRequired,omitempty"`
+}
+
+// logListing logs a single withdrawal with --output log
+func logListing(listing *withdrawalListing, decimals int, proofMaturityDelaySeconds *big.Int) {
+	proofMaturityDelay := time.Duration(proofMaturityDelaySeconds.Int64() * int64(time.Second))
+	log.Info(fmt.Sprintf("Withdrawal: %s", listing.Nonce),
+		"from", listing.From,
+		"to", listing.To,
+		"l1Token", listing.L1Token,
+		"l2Token", listing.L2Token,
+		"amount", internal.FormatBigInt(listing.Amount, decimals),
+		"block", listing.Block,
+		"withdrawalHash", common.Bytes2Hex(listing.WithdrawalHash[:]),
+		"transactionHash", listing.TxHash.Hex(),
+		"status", listing.Status,
+		"timestamp_proven", listing.ProvenTime,
+		"timestamp_created_at", listing.CreatedAtTime,
+		"timestamp_game_resolved", listing.GameResolvedTime,
+		"timestamp_finalizable", listing.FinalizableTime,
+		"finalizable_in", listing.FinalizableIn,
+		"proof_maturity_delay", proofMaturityDelay,
+		"isClaimResolved", listing.IsClaimResolved,
+		"challengerDuration", listing.ChallengerDuration,
+		"maxClockDuration", listing.MaxClockDuration,
+		"disputeGameStatus", listing.DisputeGameStatus.String(),
+		"reproveRequired", listing.ReproveRequired,
+	)
+}
+
+// printListingTable writes the listings, already sorted by block, as an aligned table to stdout. Withdrawal hashes are
+// shortened to a prefix unless fullHashes is set.
+func printListingTable(listings []*withdrawalListing, decimals int, fullHashes bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NONCE\tFROM\tTO\tAMOUNT\tBLOCK\tSTATUS\tFINALIZABLE IN\tWITHDRAWAL HASH")
+	for _, listing := range listings {
+		finalizableIn := "-"
+		if listing.Status >= Proven && listing.Status != Finalized {
+			finalizableIn = max(listing.FinalizableIn, 0).Round(time.Second).String()
+		}
+		withdrawalHash := listing.WithdrawalHash.Hex()
+		if !fullHashes {
+			withdrawalHash = withdrawalHash[:10] + "..."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			listing.Nonce,
+			listing.From.Hex(),
+			listing.To.Hex(),
+			internal.FormatBigInt(listing.Amount, decimals),
+			listing.Block,
+			listing.Status,
+			finalizableIn,
+			withdrawalHash,
+		)
+	}
+	return w.Flush()
+}
+
+// printListingCSV writes the listings, already sorted by block, as CSV to stdout with a header row. Amounts are written
+// both in the smallest unit and formatted with the token decimals, and finalizable_at is left empty until the
+// withdrawal is proven.
+func printListingCSV(listings []*withdrawalListing, decimals int) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"nonce", "from", "to", "l1Token", "l2Token", "amount_wei", "amount_eth", "block", "withdrawalHash", "txHash", "status", "finalizable_at"}); err != nil {
+		return fmt.Errorf("could not write csv header: %w", err)
+	}
+	for _, listing := range listings {
+		if err := w.Write(listingCSVRecord(listing, decimals)); err != nil {
+			return fmt.Errorf("could not write csv record: %w", err)
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// listingCSVRecord formats a single withdrawal as a record of the columns written by printListingCSV
+func listingCSVRecord(listing *withdrawalListing, decimals int) []string {
+	finalizableAt := ""
+	if listing.Status >= Proven {
+		finalizableAt = listing.FinalizableTime.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		listing.Nonce.String(),
+		listing.From.Hex(),
+		listing.To.Hex(),
+		listing.L1Token.Hex(),
+		listing.L2Token.Hex(),
+		listing.Amount.String(),
+		internal.FormatBigInt(listing.Amount, decimals),
+		strconv.FormatUint(listing.Block, 10),
+		listing.WithdrawalHash.Hex(),
+		listing.TxHash.Hex(),
+		listing.Status.String(),
+		finalizableAt,
+	}
+}
+
+// writeListingTextfile writes the pending withdrawals and the age of the oldest one of every listed account, and the L1
+// and L2 heads as gauges for the node_exporter textfile collector. Accounts that failed to be listed are left out.
+func writeListingTextfile(ctx context.Context, path string, l1Client *ethclient.Client, l2Client *ethclient.Client, results []*accountListings) error {
+	readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+
+	var pendingSamples, oldestAgeSamples []internal.GaugeSample
+	for _, result := range results {
+		if result.Error != "" {
+			continue
+		}
+
+		// Listings are sorted by block, so the first unfinalized one is the oldest
+		pending := 0
+		var oldest *withdrawalListing
+		for _, listing := range result.Withdrawals {
+			if listing.Status == Finalized {
+				continue
+			}
+			pending++
+			if oldest == nil {
+				oldest = listing
+			}
+		}
+
+		oldestAge := 0.0
+		if oldest != nil {
+			header, err := l2Client.HeaderByNumber(readCtx, new(big.Int).SetUint64(oldest.Block))
+			if err != nil {
+				return fmt.Errorf("could not fetch L2 block %d: %w", oldest.Block, err)
+			}
+			oldestAge = time.Since(time.Unix(int64(header.Time), 0)).Seconds()
+		}
+
+		labels := map[string]string{"account": result.Account.Hex()}
+		pendingSamples = append(pendingSamples, internal.GaugeSample{Labels: labels, Value: float64(pending)})
+		oldestAgeSamples = append(oldestAgeSamples, internal.GaugeSample{Labels: labels, Value: oldestAge})
+		log.Info("withdrawal gauges", "account", result.Account, "pending", pending, "oldestAge", time.Duration(oldestAge*float64(time.Second)).Round(time.Second))
+	}
+
+	l1Head, err := l1Client.BlockNumber(readCtx)
+	if err != nil {
+		return fmt.Errorf("could not fetch L1 block number: %w", err)
+	}
+	l2Head, err := l2Client.BlockNumber(readCtx)
+	if err != nil {
+		return fmt.Errorf("could not fetch L2 block number: %w", err)
+	}
+
+	gauges := []internal.Gauge{
+		{
+			Name:    "op_probe_pending_withdrawals",
+			Help:    "Number of withdrawals of the account that are not finalized yet",
+			Samples: pendingSamples,
+		},
+		{
+			Name:    "op_probe_oldest_unfinalized_age_seconds",
+			Help:    "Seconds since the L2 block of the oldest withdrawal of the account that is not finalized yet, 0 when there is none",
+			Samples: oldestAgeSamples,
+		},
+		{
+			Name: "op_probe_chain_head_block",
+			Help: "Latest block number of the chain",
+			Samples: []internal.GaugeSample{
+				{Labels: map[string]string{"chain": "l1"}, Value: float64(l1Head)},
+				{Labels: map[string]string{"chain": "l2"}, Value: float64(l2Head)},
+			},
+		},
+	}
+
+	if err := internal.WritePrometheusTextfile(path, gauges); err != nil {
+		return err
+	}
+	log.Info("wrote withdrawal gauges", "path", path)
+	return nil
 }
 
 func DecodeVersionedNonce(nonce *big.Int) *big.Int {
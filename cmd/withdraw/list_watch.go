@@ -0,0 +1,227 @@
+package withdraw_cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Golem-Base/op-probe/internal"
+	e2eBindings "github.com/ethereum-optimism/optimism/op-e2e/bindings"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// watchNew reports every withdrawal initiated by accounts from the L2 block from on, until ctx is cancelled.
+// Withdrawals are taken from a subscription to the events of the source, or polled for every pollInterval on rpcs
+// without subscriptions and once a subscription fails. Withdrawals in seen were reported already and are skipped.
+func (l *lister) watchNew(ctx context.Context, accounts []common.Address, from uint64, pollInterval time.Duration, seen map[common.Hash]bool, report func(*withdrawalListing) error) error {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, sub, err := l.subscribeEvents(watchCtx, accounts)
+	if err != nil {
+		log.Warn("could not subscribe to new withdrawals, polling for them instead", "error", err)
+	} else {
+		log.Info("watching for new withdrawals", "accounts", len(accounts), "fromBlock", from)
+		from, err = l.followSubscription(watchCtx, accounts, events, sub, from, seen, report)
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Info("polling for new withdrawals", "fromBlock", from, "pollInterval", pollInterval)
+	}
+
+	// Each poll scans the blocks up to the current head, a failed scan is retried from the same block on the next poll
+	err = internal.PollUntil(ctx, pollInterval, pollInterval, func() (bool, error) {
+		readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+		defer cancel()
+
+		head, err := l.l2Client.BlockNumber(readCtx)
+		if err != nil {
+			log.Warn("could not fetch L2 block number, retrying...", "error", err)
+			return false, nil
+		}
+		if head < from {
+			return false, nil
+		}
+
+		events, err := l.filterEvents(&bind.FilterOpts{Context: readCtx, Start: from, End: &head}, accounts)
+		if err == nil {
+			err = l.reportNew(ctx, events, seen, report)
+		}
+		if errors.Is(err, errReport) {
+			return false, err
+		}
+		if err != nil {
+			log.Warn("could not read new withdrawals, retrying...", "fromBlock", from, "toBlock", head, "error", err)
+			return false, nil
+		}
+		from = head + 1
+		return false, nil
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// followSubscription reports the withdrawals received on events until ctx is cancelled or the subscription fails, and
+// returns the block to continue polling from. A withdrawal whose state cannot be read ends the subscription as well,
+// so that polling retries it.
+func (l *lister) followSubscription(ctx context.Context, accounts []common.Address, events <-chan *withdrawalEvent, sub event.Subscription, from uint64, seen map[common.Hash]bool, report func(*withdrawalListing) error) (uint64, error) {
+	defer sub.Unsubscribe()
+
+	// Nodes only deliver logs mined after the subscription started, so the blocks mined since from are scanned once
+	readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+	head, err := l.l2Client.BlockNumber(readCtx)
+	if err != nil {
+		log.Warn("could not fetch L2 block number", "error", err)
+		return from, nil
+	}
+	if head >= from {
+		missed, err := l.filterEvents(&bind.FilterOpts{Context: readCtx, Start: from, End: &head}, accounts)
+		if err == nil {
+			err = l.reportNew(ctx, missed, seen, report)
+		}
+		if errors.Is(err, errReport) {
+			return from, err
+		}
+		if err != nil {
+			log.Warn("could not read new withdrawals", "fromBlock", from, "toBlock", head, "error", err)
+			return from, nil
+		}
+		from = head + 1
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return from, nil
+		case err := <-sub.Err():
+			log.Warn("withdrawal subscription failed", "error", err)
+			return from, nil
+		case event := <-events:
+			// Events of blocks dropped by a reorg are redelivered with Removed set
+			if event.Raw.Removed {
+				continue
+			}
+			// Polling resumes from the block of the last event, whose withdrawals already reported are skipped
+			err := l.reportNew(ctx, []*withdrawalEvent{event}, seen, report)
+			if errors.Is(err, errReport) {
+				return from, err
+			}
+			if err != nil {
+				log.Warn("could not read new withdrawal", "tx", event.Raw.TxHash.Hex(), "error", err)
+				return min(from, event.Raw.BlockNumber), nil
+			}
+			from = max(from, event.Raw.BlockNumber)
+		}
+	}
+}
+
+// errReport marks a failure to report a withdrawal, which stops watching instead of being retried
+var errReport = errors.New("could not report withdrawal")
+
+// reportNew fetches the state of the withdrawals found by events and reports those not in seen, in block order
+func (l *lister) reportNew(ctx context.Context, events []*withdrawalEvent, seen map[common.Hash]bool, report func(*withdrawalListing) error) error {
+	// Withdrawals are proven by the account that initiated them, so their state is read per account
+	var accounts []common.Address
+	byAccount := make(map[common.Address][]*withdrawalEvent)
+	for _, event := range events {
+		if _, ok := byAccount[event.From]; !ok {
+			accounts = append(accounts, event.From)
+		}
+		byAccount[event.From] = append(byAccount[event.From], event)
+	}
+
+	var listings []*withdrawalListing
+	for _, account := range accounts {
+		accountListings, err := l.fetchListings(ctx, account, byAccount[account])
+		if err != nil {
+			return fmt.Errorf("account %s: %w", account.Hex(), err)
+		}
+		listings = append(listings, accountListings...)
+	}
+	sort.SliceStable(listings, func(i, j int) bool {
+		if listings[i].Block != listings[j].Block {
+			return listings[i].Block < listings[j].Block
+		}
+		return listings[i].LogIndex < listings[j].LogIndex
+	})
+
+	for _, listing := range listings {
+		if seen[listing.WithdrawalHash] {
+			continue
+		}
+		if err := report(listing); err != nil {
+			return fmt.Errorf("%w %s: %w", errReport, listing.WithdrawalHash.Hex(), err)
+		}
+		seen[listing.WithdrawalHash] = true
+	}
+	return nil
+}
+
+// subscribeEvents subscribes to the events of the source initiated by accounts in newly mined L2 blocks, which fails on
+// rpcs without subscriptions such as plain http endpoints
+func (l *lister) subscribeEvents(ctx context.Context, accounts []common.Address) (<-chan *withdrawalEvent, event.Subscription, error) {
+	opts := &bind.WatchOpts{Context: ctx}
+	events := make(chan *withdrawalEvent)
+
+	if l.source == "messagepasser" {
+		sink := make(chan *e2eBindings.L2ToL1MessagePasserMessagePassed)
+		sub, err := l.l2ToL1MessagePasser.WatchMessagePassed(opts, sink, nil, accounts, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not subscribe to MessagePassed events: %w", err)
+		}
+		go forwardEvents(ctx, sink, events, func(event *e2eBindings.L2ToL1MessagePasserMessagePassed) *withdrawalEvent {
+			return &withdrawalEvent{
+				From:          event.Sender,
+				To:            event.Target,
+				Amount:        event.Value,
+				Raw:           event.Raw,
+				MessagePassed: event,
+			}
+		})
+		return events, sub, nil
+	}
+
+	sink := make(chan *e2eBindings.L2StandardBridgeWithdrawalInitiated)
+	sub, err := l.l2StandardBridge.WatchWithdrawalInitiated(opts, sink, l.l1Tokens, l.l2Tokens, accounts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not subscribe to WithdrawalInitiated events: %w", err)
+	}
+	go forwardEvents(ctx, sink, events, func(event *e2eBindings.L2StandardBridgeWithdrawalInitiated) *withdrawalEvent {
+		return &withdrawalEvent{
+			From:    event.From,
+			To:      event.To,
+			L1Token: event.L1Token,
+			L2Token: event.L2Token,
+			Amount:  event.Amount,
+			Raw:     event.Raw,
+		}
+	})
+	return events, sub, nil
+}
+
+// forwardEvents converts the events received on sink into withdrawal events until ctx is cancelled
+func forwardEvents[T any](ctx context.Context, sink <-chan T, events chan<- *withdrawalEvent, convert func(T) *withdrawalEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-sink:
+			select {
+			case events <- convert(event):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
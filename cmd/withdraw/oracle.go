@@ -0,0 +1,216 @@
+package withdraw_cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/Golem-Base/op-probe/internal"
+	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/transactions"
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// newOracleContracts instantiates the L2OutputOracle given with --l2-output-oracle-address and the legacy
+// OptimismPortal proving against it
+func newOracleContracts(ctx context.Context, c *cli.Context, l1Client *ethclient.Client, optimismPortalAddress common.Address) (*opNodeBindings.L2OutputOracle, *opNodeBindings.OptimismPortal, error) {
+	l2OutputOracleAddress, err := internal.SafeParseAddress(c.String("l2-output-oracle-address"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse L2OutputOracle address: %w", err)
+	}
+	l2OutputOracle, err := opNodeBindings.NewL2OutputOracle(l2OutputOracleAddress, l1Client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not instantiate L2OutputOracle contract: %w", err)
+	}
+
+	optimismPortal, err := internal.NewLegacyOptimismPortal(ctx, c, l1Client, optimismPortalAddress)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+	}
+
+	return l2OutputOracle, optimismPortal, nil
+}
+
+// outputStillProposed reports whether the L2OutputOracle still holds the output root a withdrawal was proven against.
+// Outputs deleted by the challenger leave the proof pointing at an index the oracle no longer holds or holds another
+// root at, and the withdrawal has to be proven again.
+func outputStillProposed(opts *bind.CallOpts, l2OutputOracle *opNodeBindings.L2OutputOracle, l2OutputIndex *big.Int, outputRoot common.Hash) (bool, error) {
+	output, err := l2OutputOracle.GetL2Output(opts, l2OutputIndex)
+	if err != nil {
+		if strings.Contains(err.Error(), "execution reverted") {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not fetch L2OutputOracle.GetL2Output: %w", err)
+	}
+	return output.OutputRoot == outputRoot, nil
+}
+
+// proveAgainstOracle proves the withdrawal of result through the legacy OptimismPortal against the first
+// L2OutputOracle output covering it, for chains without fault proofs
+func proveAgainstOracle(ctx context.Context, c *cli.Context, output *internal.Output, result *proveResult, account common.Address, privateKey *ecdsa.PrivateKey, l1Client *ethclient.Client, l1ChainId *big.Int, l2Client *ethclient.Client) error {
+	optimismPortalAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "optimism-portal-address")
+	if err != nil {
+		return fmt.Errorf("could not resolve OptimismPortal address: %w", err)
+	}
+	l2OutputOracle, optimismPortal, err := newOracleContracts(ctx, c, l1Client, optimismPortalAddress)
+	if err != nil {
+		return err
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+	callOpts := &bind.CallOpts{Context: readCtx}
+
+	// Receipts are cached for the run so the proof generation reuses the withdrawal receipt fetched below
+	l2Receipts := internal.NewReceiptCache(l2Client, internal.DefaultReceiptCacheSize)
+
+	withdrawalTxReceipt, err := l2Receipts.TransactionReceipt(readCtx, result.WithdrawalTxHash)
+	if err != nil {
+		return fmt.Errorf("could not get receipt for withdrawal event in transaction: %s: %w", result.WithdrawalTxHash.Hex(), err)
+	}
+	messagePassedEvent, err := withdrawals.ParseMessagePassed(withdrawalTxReceipt)
+	if err != nil {
+		return fmt.Errorf("could not parse the MessagePassed event from the withdrawal transaction hash")
+	}
+
+	finalized, err := optimismPortal.FinalizedWithdrawals(callOpts, messagePassedEvent.WithdrawalHash)
+	if err != nil {
+		return fmt.Errorf("could not fetch OptimismPortal.FinalizedWithdrawals: %w", err)
+	}
+	if finalized {
+		log.Info("withdrawal has already been finalized, nothing to do", "withdrawal hash", common.Bytes2Hex(messagePassedEvent.WithdrawalHash[:]))
+		result.AlreadyFinalized = true
+		return nil
+	}
+
+	proven, err := optimismPortal.ProvenWithdrawals(callOpts, messagePassedEvent.WithdrawalHash)
+	if err != nil {
+		return fmt.Errorf("could not fetch proven withdrawal: %w", err)
+	}
+	if proven.Timestamp.Sign() != 0 {
+		stillProposed, err := outputStillProposed(callOpts, l2OutputOracle, proven.L2OutputIndex, proven.OutputRoot)
+		if err != nil {
+			return err
+		}
+		if stillProposed {
+			log.Info("withdrawal has already been proven against a proposed output, skipping prove transaction",
+				"proved_at", time.Unix(proven.Timestamp.Int64(), 0),
+				"l2OutputIndex", proven.L2OutputIndex,
+			)
+			result.AlreadyProven = true
+			return nil
+		}
+		log.Warn("withdrawal was proven against an output the L2OutputOracle no longer holds, a re-prove is required", "l2OutputIndex", proven.L2OutputIndex)
+	}
+
+	params, err := internal.ProveWithdrawalParametersForOutput(
+		readCtx,
+		callOpts,
+		c.String("proof-variant"),
+		gethclient.New(l2Client.Client()),
+		l2Receipts,
+		l2Client,
+		result.WithdrawalTxHash,
+		&l2OutputOracle.L2OutputOracleCaller,
+		withdrawalTxReceipt.BlockNumber.Uint64(),
+	)
+	if err != nil {
+		return fmt.Errorf("could not generate proofs for withdrawal: %w", err)
+	}
+
+	if dir := c.String("dump-proof"); dir != "" {
+		if _, err := internal.DumpProof(dir, result.WithdrawalTxHash, params); err != nil {
+			return err
+		}
+	}
+
+	return submitProve(ctx, c, output, result, account, privateKey, l1Client, l1ChainId, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return optimismPortal.ProveWithdrawalTransaction(
+			opts,
+			opNodeBindings.TypesWithdrawalTransaction{
+				Nonce:    params.Nonce,
+				Sender:   params.Sender,
+				Target:   params.Target,
+				Value:    params.Value,
+				GasLimit: params.GasLimit,
+				Data:     params.Data,
+			},
+			params.L2OutputIndex,
+			params.OutputRootProof,
+			params.WithdrawalProof,
+		)
+	})
+}
+
+// oracleFinalizeTx checks that the withdrawal of messagePassedEvent can be finalized through the legacy
+// OptimismPortal, proven against an output the L2OutputOracle still holds and past its finalization period, and
+// returns the finalize transaction
+func (f *finalizer) oracleFinalizeTx(readCtx context.Context, messagePassedEvent *opNodeBindings.L2ToL1MessagePasserMessagePassed) (transactions.TxBuilder, error) {
+	callOpts := &bind.CallOpts{Context: readCtx}
+
+	finalized, err := f.legacyPortal.FinalizedWithdrawals(callOpts, messagePassedEvent.WithdrawalHash)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch OptimismPortal.FinalizedWithdrawals: %w", err)
+	}
+	if finalized {
+		log.Info("withdrawal proof has already been finalized, exiting...", "withdrawal hash", common.Bytes2Hex(messagePassedEvent.WithdrawalHash[:]))
+		return nil, internal.ErrAlreadyFinalized
+	}
+
+	proven, err := f.legacyPortal.ProvenWithdrawals(callOpts, messagePassedEvent.WithdrawalHash)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch proven withdrawal: %w", err)
+	}
+	if proven.Timestamp.Sign() == 0 {
+		return nil, internal.ErrNotProven
+	}
+	provenTimestamp := time.Unix(proven.Timestamp.Int64(), 0)
+	log.Info("withdrawal has been proven", "proved_at", provenTimestamp, "l2OutputIndex", proven.L2OutputIndex)
+
+	stillProposed, err := outputStillProposed(callOpts, f.l2OutputOracle, proven.L2OutputIndex, proven.OutputRoot)
+	if err != nil {
+		return nil, err
+	}
+	if !stillProposed {
+		return nil, fmt.Errorf("the output your withdrawal was proven against is no longer held by the L2OutputOracle; re-prove required")
+	}
+
+	finalizationPeriodSeconds, err := f.l2OutputOracle.FINALIZATIONPERIODSECONDS(callOpts)
+	if err != nil {
+		return nil, fmt.Errorf("could not call L2OutputOracle.FINALIZATION_PERIOD_SECONDS: %w", err)
+	}
+	finalizableTime := provenTimestamp.Add(time.Duration(finalizationPeriodSeconds.Int64() * int64(time.Second)))
+	now, err := internal.Now(readCtx, f.l1Client, f.TimeSource)
+	if err != nil {
+		return nil, err
+	}
+	if untilFinalizable := finalizableTime.Sub(now); untilFinalizable > 0 {
+		log.Info("the finalization period has not passed, exiting...", "finalizableTime", finalizableTime, "until finalizableTime", untilFinalizable)
+		return nil, fmt.Errorf("%w, matures at %s", internal.ErrProofNotMatured, finalizableTime)
+	}
+	log.Info("the finalization period has passed, continuing...", "finalizableTime", finalizableTime)
+
+	return func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return f.legacyPortal.FinalizeWithdrawalTransaction(
+			opts,
+			opNodeBindings.TypesWithdrawalTransaction{
+				Nonce:    messagePassedEvent.Nonce,
+				Sender:   messagePassedEvent.Sender,
+				Target:   messagePassedEvent.Target,
+				Value:    messagePassedEvent.Value,
+				GasLimit: messagePassedEvent.GasLimit,
+				Data:     messagePassedEvent.Data,
+			},
+		)
+	}, nil
+}
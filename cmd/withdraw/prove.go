@@ -2,12 +2,15 @@ package withdraw_cmd
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"math/big"
+	"time"
 
+	"github.com/Golem-Base/op-probe/bindings"
 	"github.com/Golem-Base/op-probe/internal"
 	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/transactions"
-	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/wait"
 	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
 	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
 	opNodePreviewBindings "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
@@ -15,7 +18,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/ethclient/gethclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/urfave/cli/v2"
@@ -26,18 +29,17 @@ var ProveCommand = &cli.Command{
 	Usage: "Prove a withdrawal transaction",
 	Flags: []cli.Flag{
 		&cli.StringFlag{
-			Name:     "private-key",
-			Usage:    "Private key of address to send test transaction from",
-			Required: true,
+			Name:  "private-key",
+			Usage: "Private key of address to send test transaction from, or - to read it from stdin, required unless --build-only is set. The sender becomes the prover of the withdrawal, which another account can finalize with finalize --prover",
 		},
 		&cli.StringFlag{
 			Name:     "l1-rpc-url",
-			Usage:    "Url for L1 execution client",
+			Usage:    "Url for L1 execution client, or comma separated urls to fail over between",
 			Required: true,
 		},
 		&cli.StringFlag{
 			Name:     "l2-rpc-url",
-			Usage:    "Url for L2 execution client",
+			Usage:    "Url for L2 execution client, or comma separated urls to fail over between",
 			Required: true,
 		},
 		&cli.StringFlag{
@@ -46,129 +48,552 @@ var ProveCommand = &cli.Command{
 			Required: true,
 		},
 		&cli.StringFlag{
-			Name:     "dispute-game-factory-address",
-			Usage:    "Contract address for DisputeGameFactory (* or proxy)",
-			Required: true,
+			Name:  "dispute-game-factory-address",
+			Usage: "Contract address for DisputeGameFactory (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
 		},
 		&cli.StringFlag{
-			Name:     "optimism-portal-address",
-			Usage:    "Contract address for OptimismPortal (* or proxy)",
-			Required: true,
+			Name:  "l2-output-oracle-address",
+			Usage: "Contract address for the L2OutputOracle (* or proxy) of a chain without fault proofs, proving through the legacy OptimismPortal instead of against a dispute game",
+		},
+		&cli.StringFlag{
+			Name:  "optimism-portal-address",
+			Usage: "Contract address for OptimismPortal (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "Send the prove transaction without simulating it first with eth_call",
+		},
+		&cli.StringFlag{
+			Name:  "proof-variant",
+			Usage: "Withdrawal proof to generate: fault-proofs, or withdrawals-root for Isthmus chains whose L2 account proofs do not verify against the state root",
+			Value: internal.ProofVariantFaultProofs,
+		},
+		&cli.StringFlag{
+			Name:  "dump-proof",
+			Usage: "Directory to write the generated withdrawal proof parameters to as JSON before submitting, for debugging reverts",
+		},
+		&cli.Uint64Flag{
+			Name:  "game-index",
+			Usage: "Index of the dispute game to prove against (default: latest game of the respected game type)",
+		},
+		&cli.Uint64Flag{
+			Name:  "l2-block-number",
+			Usage: "L2 block to build the proof at, proving against the latest game proposed at that block unless --game-index is set (default: L2 block of the game)",
+		},
+		&cli.BoolFlag{
+			Name:  "output-root-check",
+			Usage: "Compare the output root of the proof, computed from the L2 node, with the root claim of the game before proving, failing on a mismatch instead of sending a prove that reverts",
+		},
+		&cli.StringFlag{
+			Name:  "output-root",
+			Usage: "Output root the proof has to match, proving against the latest game claiming it unless --game-index is set",
+		},
+		&cli.BoolFlag{
+			Name:  "require-l1-finalized",
+			Usage: "Only prove once the L1 block the dispute game was created in is finalized, so that an L1 reorg cannot invalidate the proof",
+		},
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "Wait for the L1 block of the dispute game to be finalized with --require-l1-finalized instead of failing",
+		},
+		&cli.DurationFlag{
+			Name:  "poll-interval",
+			Usage: "Interval between checks of the finalized L1 block with --wait",
+			Value: 12 * time.Second,
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "Maximum time to wait for the L1 block of the dispute game to be finalized with --wait",
+			Value: time.Hour,
+		},
+		&cli.Uint64Flag{
+			Name:  "nonce",
+			Usage: "Nonce of the first transaction sent (default: pending nonce of the sender)",
+		},
+		&cli.BoolFlag{
+			Name:  "build-only",
+			Usage: "Print the unsigned transaction as JSON instead of signing and sending it, to be signed offline and broadcast with submit-raw",
+		},
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "Address the transaction is built for with --build-only, in place of --private-key",
 		},
 	},
-	Action: func(c *cli.Context) error {
-		ctx := context.Background()
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := c.Context
 
-		privateKey, err := crypto.HexToECDSA(c.String("private-key"))
+		if err := internal.ValidateProofVariant(c.String("proof-variant")); err != nil {
+			return err
+		}
+		useOracle, err := internal.UseL2OutputOracle(c)
 		if err != nil {
-			return fmt.Errorf("failed to parse private-key: %w", err)
+			return err
+		}
+
+		var l2BlockOverride *big.Int
+		if c.IsSet("l2-block-number") {
+			l2BlockOverride = new(big.Int).SetUint64(c.Uint64("l2-block-number"))
+		}
+		var outputRootOverride *common.Hash
+		if c.IsSet("output-root") {
+			outputRoot, err := internal.SafeParseHash(c.String("output-root"))
+			if err != nil {
+				return fmt.Errorf("invalid output-root: %w", err)
+			}
+			outputRootOverride = &outputRoot
+		}
+		if useOracle && (l2BlockOverride != nil || outputRootOverride != nil) {
+			return fmt.Errorf("--l2-block-number and --output-root are not supported with --l2-output-oracle-address")
+		}
+		if useOracle && c.Bool("require-l1-finalized") {
+			return fmt.Errorf("--require-l1-finalized is not supported with --l2-output-oracle-address")
+		}
+
+		account, privateKey, err := internal.SenderAccount(c)
+		if err != nil {
+			return err
 		}
 
 		l1RpcUrl := c.String("l1-rpc-url")
-		l1Client, l1ChainId, err := internal.ConnectClient(ctx, l1RpcUrl)
+		l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
 		if err != nil {
 			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
 		}
 
 		l2RpcUrl := c.String("l2-rpc-url")
-		l2Client, _, err := internal.ConnectClient(ctx, l2RpcUrl)
+		l2Client, l2ChainId, err := internal.ConnectClient(ctx, c, l2RpcUrl, "l2-chain-id")
 		if err != nil {
 			return fmt.Errorf("could not connect to client at %s: %w", l2RpcUrl, err)
 		}
 
-		withdrawalTxHash := common.HexToHash(c.String("tx"))
+		if err := internal.ValidateChainIds(c, l1ChainId, l2ChainId); err != nil {
+			return err
+		}
 
-		disputeGameFactoryAddress, err := internal.SafeParseAddress(c.String("dispute-game-factory-address"))
+		withdrawalTxHash, err := internal.SafeParseHash(c.String("tx"))
 		if err != nil {
-			return fmt.Errorf("could not parse DisputeGameFactory address: %w", err)
+			return fmt.Errorf("could not parse tx: %w", err)
 		}
-		disputeGameFactory, err := opNodeBindings.NewDisputeGameFactory(disputeGameFactoryAddress, l1Client)
-		if err != nil {
-			return fmt.Errorf("could not instantiate DisputeGameFactory contract: %w", err)
+
+		result := &proveResult{WithdrawalTxHash: withdrawalTxHash}
+		output.Result = result
+		output.SetAccount(account)
+
+		if useOracle {
+			return proveAgainstOracle(ctx, c, output, result, account, privateKey, l1Client, l1ChainId, l2Client)
 		}
 
-		optimismPortalAddress, err := internal.SafeParseAddress(c.String("optimism-portal-address"))
+		w, err := newFaultProofWithdrawer(ctx, c, l1Client, l2Client, l1ChainId, l2ChainId)
 		if err != nil {
-			return fmt.Errorf("could not parse OptimismPortal address: %w", err)
+			return err
 		}
-		optimismPortal, err := opNodePreviewBindings.NewOptimismPortal2(optimismPortalAddress, l1Client)
+		opts, err := proveTransactor(ctx, c, account, privateKey, l1Client, l1ChainId)
 		if err != nil {
-			return fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+			return err
 		}
 
-		withdrawalTxReceipt, err := l2Client.TransactionReceipt(ctx, withdrawalTxHash)
+		var gameIndex *big.Int
+		if c.IsSet("game-index") {
+			gameIndex = new(big.Int).SetUint64(c.Uint64("game-index"))
+		}
+		err = w.Prove(ctx, opts, ProveConfig{
+			ProofVariant:       c.String("proof-variant"),
+			GameIndex:          gameIndex,
+			L2BlockNumber:      l2BlockOverride,
+			OutputRoot:         outputRootOverride,
+			OutputRootCheck:    c.Bool("output-root-check"),
+			RequireL1Finalized: c.Bool("require-l1-finalized"),
+			WaitL1Finalized:    c.Bool("wait"),
+			PollInterval:       c.Duration("poll-interval"),
+			Timeout:            c.Duration("timeout"),
+			DumpProofDir:       c.String("dump-proof"),
+			Force:              c.Bool("force"),
+			BuildOnly:          c.Bool("build-only"),
+		}, result)
 		if err != nil {
-			return fmt.Errorf("could not get receipt for withdrawal event in transaction: %s: %w", withdrawalTxHash.Hex(), err)
+			return err
 		}
+		return reportProve(output, result)
+	}),
+}
+
+// ProveConfig holds the settings of Withdrawer.Prove
+type ProveConfig struct {
+	// ProofVariant selects how the withdrawal proof is generated, see internal.ValidateProofVariant
+	ProofVariant string
+	// GameIndex proves against the game at this factory index instead of the latest game covering the withdrawal
+	GameIndex *big.Int
+	// L2BlockNumber and OutputRoot prove against the game proposed at this L2 block or with this output root instead
+	// of the latest game covering the withdrawal
+	L2BlockNumber *big.Int
+	OutputRoot    *common.Hash
+	// OutputRootCheck checks the output root of the proof against the root claim of the game before proving
+	OutputRootCheck bool
+	// RequireL1Finalized only proves against a game created in a finalized L1 block, polling every PollInterval for up
+	// to Timeout until it is with WaitL1Finalized
+	RequireL1Finalized bool
+	WaitL1Finalized    bool
+	PollInterval       time.Duration
+	Timeout            time.Duration
+	// DumpProofDir is the directory the proof of the withdrawal is written to when set
+	DumpProofDir string
+	// Force sends the prove transaction without simulating it first
+	Force bool
+	// BuildOnly sets the unsigned prove transaction on the result instead of sending it
+	BuildOnly bool
+}
+
+// Prove proves the withdrawal initiated in result.WithdrawalTxHash from the account of opts against a dispute game,
+// setting the prove transaction on result. A withdrawal already finalized, or proven against a game the portal still
+// honours, is only reported on result.
+func (w *Withdrawer) Prove(ctx context.Context, opts *bind.TransactOpts, cfg ProveConfig, result *proveResult) error {
+	if w.optimismPortal == nil {
+		return fmt.Errorf("withdrawer has no OptimismPortal to prove withdrawals against")
+	}
+	withdrawalTxHash := result.WithdrawalTxHash
+
+	readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+
+	withdrawalTxReceipt, err := w.l2Receipts.TransactionReceipt(readCtx, withdrawalTxHash)
+	if err != nil {
+		return fmt.Errorf("could not get receipt for withdrawal event in transaction: %s: %w", withdrawalTxHash.Hex(), err)
+	}
+
+	messagePassedEvent, err := withdrawals.ParseMessagePassed(withdrawalTxReceipt)
+	if err != nil {
+		return fmt.Errorf("could not parse the MessagePassed event from the withdrawal transaction hash")
+	}
+
+	// A finalized withdrawal is reported before a game is looked for or waited on, which it no longer needs
+	err = internal.CheckNotFinalized(&bind.CallOpts{Context: readCtx}, &w.optimismPortal.OptimismPortal2Caller, messagePassedEvent.WithdrawalHash)
+	if errors.Is(err, internal.ErrAlreadyFinalized) {
+		log.Info("withdrawal has already been finalized, nothing to do", "withdrawal hash", common.Bytes2Hex(messagePassedEvent.WithdrawalHash[:]))
+		result.AlreadyFinalized = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-		game, err := withdrawals.FindLatestGame(ctx, &disputeGameFactory.DisputeGameFactoryCaller, &optimismPortal.OptimismPortal2Caller)
+	var game *opNodeBindings.IDisputeGameFactoryGameSearchResult
+	if cfg.GameIndex != nil {
+		game, err = internal.GameAtIndex(&bind.CallOpts{Context: readCtx}, &w.disputeGameFactory.DisputeGameFactoryCaller, w.l1Client, cfg.GameIndex)
+		if err != nil {
+			return err
+		}
+		if err := validateGameOverride(&bind.CallOpts{Context: readCtx}, w.l1Client, w.disputeGameFactory, w.optimismPortal, game, withdrawalTxReceipt.BlockNumber.Uint64()); err != nil {
+			return err
+		}
+	} else if cfg.L2BlockNumber != nil || cfg.OutputRoot != nil {
+		game, err = internal.FindGame(&bind.CallOpts{Context: readCtx}, &w.disputeGameFactory.DisputeGameFactoryCaller, &w.optimismPortal.OptimismPortal2Caller, cfg.L2BlockNumber, cfg.OutputRoot)
+		if err != nil {
+			return err
+		}
+		if err := validateGameOverride(&bind.CallOpts{Context: readCtx}, w.l1Client, w.disputeGameFactory, w.optimismPortal, game, withdrawalTxReceipt.BlockNumber.Uint64()); err != nil {
+			return err
+		}
+	} else {
+		game, err = internal.FindLatestGameCovering(&bind.CallOpts{Context: readCtx}, &w.disputeGameFactory.DisputeGameFactoryCaller, &w.optimismPortal.OptimismPortal2Caller, withdrawalTxReceipt.BlockNumber.Uint64())
 		if err != nil {
 			return fmt.Errorf("failed to find latest game: %w", err)
 		}
 
-		gameL2BlockNumber := new(big.Int).SetBytes(game.ExtraData[0:32])
+		gameL2BlockNumber, err := internal.SearchResultL2BlockNumber(game)
+		if err != nil {
+			return err
+		}
 
 		if gameL2BlockNumber.Uint64() < withdrawalTxReceipt.BlockNumber.Uint64() {
-			return fmt.Errorf("game for this withdrawal has not been proposed yet, %d blocks remaining", withdrawalTxReceipt.BlockNumber.Uint64()-gameL2BlockNumber.Uint64())
+			return fmt.Errorf("%w, %d blocks remaining", internal.ErrGameNotProposed, withdrawalTxReceipt.BlockNumber.Uint64()-gameL2BlockNumber.Uint64())
 		}
+	}
 
-		params, err := withdrawals.ProveWithdrawalParametersFaultProofs(
-			ctx,
-			gethclient.New(l2Client.Client()),
-			l2Client,
-			l2Client,
-			withdrawalTxHash,
-			&disputeGameFactory.DisputeGameFactoryCaller,
-			&optimismPortal.OptimismPortal2Caller,
-		)
-		if err != nil {
-			return fmt.Errorf("could not generate fault proofs for withdrawal: %w", err)
+	if cfg.RequireL1Finalized {
+		if err := waitGameL1Finalized(ctx, w.l1Client, game, cfg.WaitL1Finalized, cfg.PollInterval, cfg.Timeout); err != nil {
+			return err
 		}
+	}
 
-		// log.Info("constructed fault proof parameters", params.WithdrawalProof)
+	// Waiting for the L1 block of the game to be finalized may outlast the deadline of the reads above
+	readCtx, cancel = context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
 
-		opts, err := bind.NewKeyedTransactorWithChainID(privateKey, l1ChainId)
-		if err != nil {
-			return fmt.Errorf("could not setup transactor: %w", err)
-		}
-
-		tx, err := transactions.PadGasEstimate(opts, 1.5, func(opts *bind.TransactOpts) (*types.Transaction, error) {
-			return optimismPortal.ProveWithdrawalTransaction(
-				opts,
-				bindingspreview.TypesWithdrawalTransaction{
-					Nonce:    params.Nonce,
-					Sender:   params.Sender,
-					Target:   params.Target,
-					Value:    params.Value,
-					GasLimit: params.GasLimit,
-					Data:     params.Data,
-				},
-				params.L2OutputIndex,
-				bindingspreview.TypesOutputRootProof{
-					Version:                  params.OutputRootProof.Version,
-					StateRoot:                params.OutputRootProof.StateRoot,
-					MessagePasserStorageRoot: params.OutputRootProof.MessagePasserStorageRoot,
-					LatestBlockhash:          params.OutputRootProof.LatestBlockhash,
-				},
-				params.WithdrawalProof,
-			)
-		})
+	proven, err := w.optimismPortal.ProvenWithdrawals(&bind.CallOpts{Context: readCtx}, messagePassedEvent.WithdrawalHash, opts.From)
+	if err != nil {
+		return fmt.Errorf("could not fetch proven withdrawal: %w", err)
+	}
+
+	if proven.Timestamp != 0 {
+		reprove, err := reproveRequired(&bind.CallOpts{Context: readCtx}, w.l1Client, w.disputeGameFactory, w.optimismPortal, game.Index, proven.DisputeGameProxy, time.Unix(int64(proven.Timestamp), 0))
 		if err != nil {
-			return fmt.Errorf("failed to prove withdrawal transaction: %w", err)
+			return err
+		}
+		if !reprove {
+			result.AlreadyProven = true
+			return nil
 		}
+	}
 
-		receipt, err := wait.ForReceiptOK(ctx, l1Client, tx.Hash())
+	// The proof is generated against the game selected above, so that it matches the game checked for coverage
+	l2BlockNumber := cfg.L2BlockNumber
+	if l2BlockNumber == nil {
+		l2BlockNumber, err = internal.SearchResultL2BlockNumber(game)
 		if err != nil {
-			if statusErr, ok := err.(*wait.ReceiptStatusError); ok {
-				log.Error("transaction trace", "tx", tx.Hash().Hex(), "trace", statusErr.TxTrace)
-				return fmt.Errorf("failure in transaction execution: %w", err)
-			} else {
-				return fmt.Errorf("failed to get transaction receipt: %w", err)
-			}
+			return err
+		}
+	}
+	if l2BlockNumber.Uint64() < withdrawalTxReceipt.BlockNumber.Uint64() {
+		return fmt.Errorf("L2 block %d does not include the withdrawal in L2 block %d", l2BlockNumber, withdrawalTxReceipt.BlockNumber)
+	}
+	params, err := internal.ProveWithdrawalParametersAtBlock(
+		readCtx,
+		cfg.ProofVariant,
+		gethclient.New(w.l2Client.Client()),
+		w.l2Receipts,
+		w.l2Client,
+		withdrawalTxHash,
+		l2BlockNumber,
+		game,
+	)
+	if err != nil {
+		return fmt.Errorf("could not generate fault proofs for withdrawal: %w", err)
+	}
+
+	// The proof is dumped before it is checked, so that a proof diverging from the game can still be inspected
+	if cfg.DumpProofDir != "" {
+		if _, err := internal.DumpProof(cfg.DumpProofDir, withdrawalTxHash, params); err != nil {
+			return err
 		}
+	}
+
+	if cfg.L2BlockNumber != nil || cfg.OutputRoot != nil || cfg.OutputRootCheck {
+		if err := checkProofOutputRoot(params, game, l2BlockNumber, cfg.OutputRoot); err != nil {
+			return err
+		}
+	}
+
+	prove := func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return w.optimismPortal.ProveWithdrawalTransaction(
+			opts,
+			bindingspreview.TypesWithdrawalTransaction{
+				Nonce:    params.Nonce,
+				Sender:   params.Sender,
+				Target:   params.Target,
+				Value:    params.Value,
+				GasLimit: params.GasLimit,
+				Data:     params.Data,
+			},
+			params.L2OutputIndex,
+			bindingspreview.TypesOutputRootProof{
+				Version:                  params.OutputRootProof.Version,
+				StateRoot:                params.OutputRootProof.StateRoot,
+				MessagePasserStorageRoot: params.OutputRootProof.MessagePasserStorageRoot,
+				LatestBlockhash:          params.OutputRootProof.LatestBlockhash,
+			},
+			params.WithdrawalProof,
+		)
+	}
+
+	return sendProve(ctx, w.l1Client, w.l1ChainId, opts, cfg.Force, cfg.BuildOnly, result, prove)
+}
 
-		log.Info("successfully proven withdrawal transaction", "receipt", receipt)
+type proveResult struct {
+	WithdrawalTxHash common.Hash                   `json:"withdrawalTxHash"`
+	AlreadyProven    bool                          `json:"alreadyProven"`
+	AlreadyFinalized bool                          `json:"alreadyFinalized"`
+	UnsignedTx       *internal.UnsignedTransaction `json:"unsignedTx,omitempty"`
+	TxHash           common.Hash                   `json:"txHash"`
+	Receipt          *types.Receipt                `json:"receipt"`
+	Gas              *internal.GasReport           `json:"gas,omitempty"`
+}
 
+// submitProve sends the prove transaction built by prove after simulating it, or prints it unsigned with --build-only
+func submitProve(ctx context.Context, c *cli.Context, output *internal.Output, result *proveResult, account common.Address, privateKey *ecdsa.PrivateKey, l1Client *ethclient.Client, l1ChainId *big.Int, prove transactions.TxBuilder) error {
+	opts, err := proveTransactor(ctx, c, account, privateKey, l1Client, l1ChainId)
+	if err != nil {
+		return err
+	}
+	if err := sendProve(ctx, l1Client, l1ChainId, opts, c.Bool("force"), c.Bool("build-only"), result, prove); err != nil {
+		return err
+	}
+	return reportProve(output, result)
+}
+
+// proveTransactor returns the transactor of the prove transaction, unsigned with --build-only
+func proveTransactor(ctx context.Context, c *cli.Context, account common.Address, privateKey *ecdsa.PrivateKey, l1Client *ethclient.Client, l1ChainId *big.Int) (*bind.TransactOpts, error) {
+	if c.Bool("build-only") {
+		return internal.NewUnsignedTransactor(ctx, c, l1Client, account)
+	}
+	return internal.NewTransactor(ctx, c, l1Client, privateKey, l1ChainId)
+}
+
+// sendProve sends the prove transaction built by prove after simulating it unless force is set, setting its receipt
+// on result, or sets it unsigned on result with buildOnly
+func sendProve(ctx context.Context, l1Client *ethclient.Client, l1ChainId *big.Int, opts *bind.TransactOpts, force, buildOnly bool, result *proveResult, prove transactions.TxBuilder) error {
+	// A prove against the wrong game or a stale output root would only revert once mined, wasting its gas
+	if force {
+		log.Warn("skipping the simulation of the prove transaction")
+	} else if err := internal.SimulateTransaction(ctx, l1Client, opts, "OptimismPortal.ProveWithdrawalTransaction", prove); err != nil {
+		return err
+	}
+
+	if buildOnly {
+		unsigned, err := internal.BuildUnsignedTransaction(opts, l1ChainId, "OptimismPortal.ProveWithdrawalTransaction", prove)
+		if err != nil {
+			return err
+		}
+		result.UnsignedTx = unsigned
 		return nil
-	},
+	}
+
+	receipt, err := internal.SendTransaction(ctx, l1Client, opts, "OptimismPortal.ProveWithdrawalTransaction", prove)
+	if err != nil {
+		return err
+	}
+
+	result.TxHash = receipt.TxHash
+	result.Receipt = receipt
+	result.Gas = internal.NewGasReport(receipt)
+
+	log.Info("successfully proven withdrawal transaction", "receipt", receipt)
+
+	return nil
+}
+
+// reportProve prints the unsigned prove transaction of result with --build-only, or reports the transaction sent
+func reportProve(output *internal.Output, result *proveResult) error {
+	if result.UnsignedTx != nil {
+		return internal.PrintJSON(result.UnsignedTx)
+	}
+	if result.Receipt != nil {
+		output.Primary = result.TxHash
+		output.AddTx(result.TxHash)
+	}
+	return nil
+}
+
+// validateGameOverride checks that the game selected with --game-index covers the withdrawal block and is honoured by
+// the portal
+func validateGameOverride(opts *bind.CallOpts, l1Client *ethclient.Client, disputeGameFactory *opNodeBindings.DisputeGameFactory, optimismPortal *opNodePreviewBindings.OptimismPortal2, game *opNodeBindings.IDisputeGameFactoryGameSearchResult, withdrawalBlock uint64) error {
+	gameL2BlockNumber, err := internal.SearchResultL2BlockNumber(game)
+	if err != nil {
+		return err
+	}
+	if gameL2BlockNumber.Uint64() < withdrawalBlock {
+		return fmt.Errorf("game %d at L2 block %d does not cover the withdrawal in L2 block %d", game.Index, gameL2BlockNumber, withdrawalBlock)
+	}
+
+	gameAtIndex, err := disputeGameFactory.GameAtIndex(opts, game.Index)
+	if err != nil {
+		return fmt.Errorf("could not fetch DisputeGameFactory.GameAtIndex: %w", err)
+	}
+	permissionedDisputeGame, err := bindings.NewPermissionedDisputeGame(gameAtIndex.Proxy, l1Client)
+	if err != nil {
+		return fmt.Errorf("could not construct permissioned dispute game")
+	}
+	reason, err := internal.InvalidGameReason(opts, optimismPortal, gameAtIndex.Proxy, permissionedDisputeGame)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("game %d cannot be proven against, %s", game.Index, reason)
+	}
+
+	log.Info("proving against the selected dispute game", "game", game.Index, "l2Block", gameL2BlockNumber, "disputeGame", gameAtIndex.Proxy)
+	return nil
+}
+
+// waitGameL1Finalized checks that the L1 block game was created in is finalized, polling every pollInterval for up to
+// timeout until it is with wait
+func waitGameL1Finalized(ctx context.Context, l1Client *ethclient.Client, game *opNodeBindings.IDisputeGameFactoryGameSearchResult, wait bool, pollInterval, timeout time.Duration) error {
+	if !wait {
+		readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+		defer cancel()
+		return internal.CheckGameL1Finalized(readCtx, l1Client, game)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Read failures are retried on the next poll like the L1 block not being finalized yet
+	err := internal.PollUntil(waitCtx, pollInterval, pollInterval, func() (bool, error) {
+		readCtx, cancel := context.WithTimeout(waitCtx, internal.CallTimeout)
+		defer cancel()
+
+		if err := internal.CheckGameL1Finalized(readCtx, l1Client, game); err != nil {
+			log.Info("waiting for the L1 block of the dispute game to be finalized...", "reason", err)
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed waiting for the L1 block of game %d to be finalized: %w", game.Index, err)
+	}
+
+	log.Info("L1 block of the dispute game is finalized", "game", game.Index)
+	return nil
+}
+
+// checkProofOutputRoot checks that the output root of the proof built at l2BlockNumber is the one expected with
+// --output-root and the root claim of game, which the portal would otherwise reject the proof against
+func checkProofOutputRoot(params withdrawals.ProvenWithdrawalParameters, game *opNodeBindings.IDisputeGameFactoryGameSearchResult, l2BlockNumber *big.Int, expected *common.Hash) error {
+	outputRoot := internal.OutputRootOfProof(params)
+	if expected != nil && outputRoot != *expected {
+		return fmt.Errorf("output root %s of L2 block %d does not match the expected %s", outputRoot.Hex(), l2BlockNumber, expected.Hex())
+	}
+	rootClaim := common.Hash(game.RootClaim)
+	if outputRoot != rootClaim {
+		log.Error("output root mismatch, the proof would revert", "game", game.Index, "l2Block", l2BlockNumber, "outputRoot", outputRoot.Hex(), "rootClaim", rootClaim.Hex())
+		return fmt.Errorf("%w: output root %s of L2 block %d, root claim %s of game %d", internal.ErrOutputRootMismatch, outputRoot.Hex(), l2BlockNumber, rootClaim.Hex(), game.Index)
+	}
+
+	log.Info("proof matches the root claim of the game", "game", game.Index, "l2Block", l2BlockNumber, "outputRoot", outputRoot.Hex())
+	return nil
+}
+
+// reproveRequired reports whether a withdrawal proven against provenGameProxy has to be proven again because the portal
+// no longer honours that game, failing when a re-prove is required but the portal would not accept it yet
+func reproveRequired(opts *bind.CallOpts, l1Client *ethclient.Client, disputeGameFactory *opNodeBindings.DisputeGameFactory, optimismPortal *opNodePreviewBindings.OptimismPortal2, latestGameIndex *big.Int, provenGameProxy common.Address, provenAt time.Time) (bool, error) {
+	provenGame, err := bindings.NewPermissionedDisputeGame(provenGameProxy, l1Client)
+	if err != nil {
+		return false, fmt.Errorf("could not construct permissioned dispute game")
+	}
+	reason, err := internal.InvalidGameReason(opts, optimismPortal, provenGameProxy, provenGame)
+	if err != nil {
+		return false, err
+	}
+	if reason == "" {
+		log.Info("withdrawal has already been proven against a valid dispute game, skipping prove transaction",
+			"proved_at", provenAt,
+			"disputeGame", provenGameProxy,
+		)
+		return false, nil
+	}
+
+	log.Warn("withdrawal was proven against a dispute game the portal no longer honours, a re-prove is required",
+		"reason", reason,
+		"proved_at", provenAt,
+		"disputeGame", provenGameProxy,
+	)
+
+	latestGame, err := disputeGameFactory.GameAtIndex(opts, latestGameIndex)
+	if err != nil {
+		return false, fmt.Errorf("could not fetch DisputeGameFactory.GameAtIndex: %w", err)
+	}
+	if latestGame.Proxy == provenGameProxy {
+		return false, fmt.Errorf("re-prove required but no newer dispute game than %s: %w", provenGameProxy.Hex(), internal.ErrGameNotProposed)
+	}
+
+	proofMaturityDelaySeconds, err := optimismPortal.ProofMaturityDelaySeconds(opts)
+	if err != nil {
+		return false, fmt.Errorf("could not call OptimismPortal.ProofMaturityDelaySeconds: %w", err)
+	}
+	reproveAllowedAt := provenAt.Add(time.Duration(proofMaturityDelaySeconds.Int64() * int64(time.Second)))
+	if untilReprove := time.Until(reproveAllowedAt); untilReprove > 0 {
+		return false, fmt.Errorf("re-prove required but not allowed until %s (in %s): %w", reproveAllowedAt, untilReprove, internal.ErrProofNotMatured)
+	}
+
+	log.Info("re-proving withdrawal against a newer dispute game", "disputeGame", latestGame.Proxy)
+	return true, nil
 }
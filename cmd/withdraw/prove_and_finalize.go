@@ -0,0 +1,523 @@
+package withdraw_cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Golem-Base/op-probe/bindings"
+	"github.com/Golem-Base/op-probe/internal"
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+var ProveAndFinalizeCommand = &cli.Command{
+	Name:  "prove-and-finalize",
+	Usage: "Proves a withdrawal transaction, waits for the game and withdrawal delays and finalizes it",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "private-key",
+			Usage:    "Private key of address to send test transaction from, or - to read it from stdin",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "l1-rpc-url",
+			Usage:    "Url for L1 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "l2-rpc-url",
+			Usage:    "Url for L2 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "tx",
+			Usage:    "The L2 withdrawal transaction hash",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "dispute-game-factory-address",
+			Usage: "Contract address for DisputeGameFactory (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.StringFlag{
+			Name:  "optimism-portal-address",
+			Usage: "Contract address for OptimismPortal (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.StringFlag{
+			Name:  "proof-variant",
+			Usage: "Withdrawal proof to generate: fault-proofs, or withdrawals-root for Isthmus chains whose L2 account proofs do not verify against the state root",
+			Value: internal.ProofVariantFaultProofs,
+		},
+		&cli.StringFlag{
+			Name:  "time-source",
+			Usage: "Clock the proof maturity and finality delays are compared against: wall for the local clock, or chain for the timestamp of the latest L1 block on dev chains with skewed or fast-forwarded time",
+			Value: internal.TimeSourceWall,
+		},
+		&cli.DurationFlag{
+			Name:  "poll-interval",
+			Usage: "Interval between checks of the dispute game and withdrawal delays",
+			Value: 12 * time.Second,
+		},
+		&cli.DurationFlag{
+			Name:  "max-poll-interval",
+			Usage: "Back off exponentially from poll-interval up to this interval between checks, 0 polls at a fixed interval",
+		},
+		&cli.Uint64Flag{
+			Name:  "nonce",
+			Usage: "Nonce of the first transaction sent (default: pending nonce of the sender)",
+		},
+		&cli.StringFlag{
+			Name:  "state-file",
+			Usage: "Path of a JSON file the progress of the withdrawal is saved to, re-running with the same file resumes an interrupted run instead of starting over",
+		},
+	},
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := c.Context
+
+		privateKey, err := internal.LoadSigner(c)
+		if err != nil {
+			return err
+		}
+
+		account := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+		timeSource := c.String("time-source")
+		if err := internal.ValidateTimeSource(timeSource); err != nil {
+			return err
+		}
+
+		l1RpcUrl := c.String("l1-rpc-url")
+		l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
+		}
+
+		l2RpcUrl := c.String("l2-rpc-url")
+		l2Client, l2ChainId, err := internal.ConnectClient(ctx, c, l2RpcUrl, "l2-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l2RpcUrl, err)
+		}
+
+		if err := internal.ValidateChainIds(c, l1ChainId, l2ChainId); err != nil {
+			return err
+		}
+
+		// Reads share a deadline per phase, transaction confirmations and the wait are only bounded by the command context
+		readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+		defer cancel()
+
+		withdrawalTxHash, err := internal.SafeParseHash(c.String("tx"))
+		if err != nil {
+			return fmt.Errorf("could not parse tx: %w", err)
+		}
+
+		state, resumed, err := loadFlowState(c.String("state-file"), withdrawalTxHash, account)
+		if err != nil {
+			return err
+		}
+
+		preBalance := state.PreBalance
+		if preBalance == nil {
+			preBalance, err = l1Client.BalanceAt(readCtx, account, nil)
+			if err != nil {
+				return fmt.Errorf("could not fetch balance: %w", err)
+			}
+			state.PreBalance = preBalance
+		}
+
+		result := &proveAndFinalizeResult{WithdrawalTxHash: withdrawalTxHash, Resumed: resumed, PreBalance: preBalance}
+		output.Result = result
+		output.SetAccount(account)
+
+		// finish records the successful finalize receipt and reports the balance change of the whole flow
+		finish := func(receipt *types.Receipt) error {
+			log.Info("successfully executed OptimismPortal.FinalizeWithdrawalTransaction()", "tx", receipt.TxHash.Hex())
+			result.FinalizeTxHash = receipt.TxHash
+			output.Primary = receipt.TxHash
+			output.AddTx(receipt.TxHash)
+			result.FinalizeReceipt = receipt
+			result.FinalizeGas = internal.NewGasReport(receipt)
+
+			state.FinalizeTxHash = &receipt.TxHash
+			if err := state.advance(stageFinalized); err != nil {
+				return err
+			}
+
+			readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+			defer cancel()
+
+			postBalance, err := l1Client.BalanceAt(readCtx, account, nil)
+			if err != nil {
+				return fmt.Errorf("could not fetch balance: %w", err)
+			}
+			result.PostBalance = postBalance
+
+			log.Info("successfully finalized withdrawal transaction", "initTx", withdrawalTxHash.Hex(), "balance change", internal.FormatWei(new(big.Int).Sub(postBalance, preBalance)))
+			return nil
+		}
+
+		disputeGameFactoryAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "dispute-game-factory-address")
+		if err != nil {
+			return fmt.Errorf("could not resolve DisputeGameFactory address: %w", err)
+		}
+		disputeGameFactory, err := opNodeBindings.NewDisputeGameFactory(disputeGameFactoryAddress, l1Client)
+		if err != nil {
+			return fmt.Errorf("could not instantiate DisputeGameFactory contract: %w", err)
+		}
+
+		optimismPortalAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "optimism-portal-address")
+		if err != nil {
+			return fmt.Errorf("could not resolve OptimismPortal address: %w", err)
+		}
+		optimismPortal, err := internal.NewOptimismPortal2(ctx, c, l1Client, optimismPortalAddress)
+		if err != nil {
+			return fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+		}
+
+		// Receipts are cached for the run so the proof generation reuses the withdrawal receipt fetched below
+		l2Receipts := internal.NewReceiptCache(l2Client, internal.DefaultReceiptCacheSize)
+		proofs := internal.NewWithdrawalProofCache()
+
+		withdrawalTxReceipt, err := l2Receipts.TransactionReceipt(readCtx, withdrawalTxHash)
+		if err != nil {
+			return fmt.Errorf("could not get receipt for withdrawal event in transaction: %s: %w", withdrawalTxHash.Hex(), err)
+		}
+
+		messagePassedEvent, err := withdrawals.ParseMessagePassed(withdrawalTxReceipt)
+		if err != nil {
+			return fmt.Errorf("could not parse the MessagePassed event from the withdrawal transaction hash")
+		}
+
+		state.WithdrawalHash = messagePassedEvent.WithdrawalHash
+
+		err = internal.CheckNotFinalized(&bind.CallOpts{Context: readCtx}, &optimismPortal.OptimismPortal2Caller, messagePassedEvent.WithdrawalHash)
+		if err != nil && !errors.Is(err, internal.ErrAlreadyFinalized) {
+			return err
+		}
+		// The finalize sent by an interrupted run is waited for rather than sent again, it may be what finalized the
+		// withdrawal since
+		if state.Stage == stageFinalizing && state.FinalizeTxHash != nil {
+			receipt, waitErr := internal.WaitForReceipt(ctx, l1Client, "OptimismPortal.FinalizeWithdrawalTransaction", *state.FinalizeTxHash)
+			if waitErr == nil {
+				return finish(receipt)
+			}
+			log.Warn("finalize transaction of the interrupted run did not succeed", "tx", state.FinalizeTxHash.Hex(), "error", waitErr)
+
+			readCtx, cancel = context.WithTimeout(ctx, internal.CallTimeout)
+			defer cancel()
+		}
+		if errors.Is(err, internal.ErrAlreadyFinalized) {
+			log.Info("withdrawal has already been finalized, nothing to do", "withdrawal hash", common.Bytes2Hex(messagePassedEvent.WithdrawalHash[:]))
+			result.AlreadyFinalized = true
+			return state.advance(stageFinalized)
+		}
+
+		game, err := internal.FindLatestGameCovering(&bind.CallOpts{Context: readCtx}, &disputeGameFactory.DisputeGameFactoryCaller, &optimismPortal.OptimismPortal2Caller, withdrawalTxReceipt.BlockNumber.Uint64())
+		if err != nil {
+			return fmt.Errorf("failed to find latest game: %w", err)
+		}
+
+		gameL2BlockNumber, err := internal.SearchResultL2BlockNumber(game)
+		if err != nil {
+			return err
+		}
+
+		if gameL2BlockNumber.Uint64() < withdrawalTxReceipt.BlockNumber.Uint64() {
+			return fmt.Errorf("%w, %d blocks remaining", internal.ErrGameNotProposed, withdrawalTxReceipt.BlockNumber.Uint64()-gameL2BlockNumber.Uint64())
+		}
+
+		// The withdrawal is fully described by the MessagePassed event, so finalizing never needs the proof parameters
+		withdrawalTransaction := bindingspreview.TypesWithdrawalTransaction{
+			Nonce:    messagePassedEvent.Nonce,
+			Sender:   messagePassedEvent.Sender,
+			Target:   messagePassedEvent.Target,
+			Value:    messagePassedEvent.Value,
+			GasLimit: messagePassedEvent.GasLimit,
+			Data:     messagePassedEvent.Data,
+		}
+		output.Amount = messagePassedEvent.Value
+
+		opts, err := internal.NewTransactor(ctx, c, l1Client, privateKey, l1ChainId)
+		if err != nil {
+			return err
+		}
+
+		// The prove sent by an interrupted run is waited for rather than sent again
+		if state.Stage == stageProving && state.ProveTxHash != nil {
+			receipt, err := internal.WaitForReceipt(ctx, l1Client, "OptimismPortal.ProveWithdrawalTransaction", *state.ProveTxHash)
+			if err != nil {
+				log.Warn("prove transaction of the interrupted run did not succeed, proving again", "tx", state.ProveTxHash.Hex(), "error", err)
+			} else {
+				result.ProveTxHash = receipt.TxHash
+				output.AddTx(receipt.TxHash)
+				result.ProveReceipt = receipt
+				result.ProveGas = internal.NewGasReport(receipt)
+			}
+
+			readCtx, cancel = context.WithTimeout(ctx, internal.CallTimeout)
+			defer cancel()
+		}
+
+		proven, err := optimismPortal.ProvenWithdrawals(&bind.CallOpts{Context: readCtx}, messagePassedEvent.WithdrawalHash, account)
+		if err != nil {
+			return fmt.Errorf("could not fetch proven withdrawal: %w", err)
+		}
+		if proven.Timestamp == 0 && (state.Stage == stageProven || state.Stage == stageFinalizing) {
+			log.Warn("state file records the withdrawal as proven but the portal holds no proof by the account, proving again", "stage", state.Stage)
+		}
+
+		// A withdrawal proven against a game the portal no longer honours is proven again before waiting
+		prove := proven.Timestamp == 0
+		if !prove {
+			prove, err = reproveRequired(&bind.CallOpts{Context: readCtx}, l1Client, disputeGameFactory, optimismPortal, game.Index, proven.DisputeGameProxy, time.Unix(int64(proven.Timestamp), 0))
+			if err != nil {
+				return err
+			}
+		}
+
+		if prove {
+			// The expensive proof generation only runs when a prove transaction is actually sent, under its own deadline
+			proofCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+			defer cancel()
+
+			params, err := proofs.ProveWithdrawalParametersForGame(
+				proofCtx,
+				c.String("proof-variant"),
+				gethclient.New(l2Client.Client()),
+				l2Receipts,
+				l2Client,
+				withdrawalTxHash,
+				game,
+			)
+			if err != nil {
+				return fmt.Errorf("could not generate fault proofs for withdrawal: %w", err)
+			}
+
+			proveTx, err := internal.BroadcastTransaction(ctx, l1Client, opts, "OptimismPortal.ProveWithdrawalTransaction", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+				return optimismPortal.ProveWithdrawalTransaction(
+					opts,
+					withdrawalTransaction,
+					params.L2OutputIndex,
+					bindingspreview.TypesOutputRootProof{
+						Version:                  params.OutputRootProof.Version,
+						StateRoot:                params.OutputRootProof.StateRoot,
+						MessagePasserStorageRoot: params.OutputRootProof.MessagePasserStorageRoot,
+						LatestBlockhash:          params.OutputRootProof.LatestBlockhash,
+					},
+					params.WithdrawalProof,
+				)
+			})
+			if err != nil {
+				return err
+			}
+			proveTxHash := proveTx.Hash()
+			state.ProveTxHash = &proveTxHash
+			if err := state.advance(stageProving); err != nil {
+				return err
+			}
+
+			receipt, err := internal.WaitForTransaction(ctx, l1Client, opts, "OptimismPortal.ProveWithdrawalTransaction", proveTx)
+			if err != nil {
+				return err
+			}
+
+			log.Info("successfully proven withdrawal transaction", "tx", receipt.TxHash.Hex())
+			result.ProveTxHash = receipt.TxHash
+			output.AddTx(receipt.TxHash)
+			result.ProveReceipt = receipt
+			result.ProveGas = internal.NewGasReport(receipt)
+			// A resubmission replaces the hash first recorded
+			state.ProveTxHash = &receipt.TxHash
+
+			readCtx, cancel = context.WithTimeout(ctx, internal.CallTimeout)
+			defer cancel()
+
+			proven, err = optimismPortal.ProvenWithdrawals(&bind.CallOpts{Context: readCtx}, messagePassedEvent.WithdrawalHash, account)
+			if err != nil {
+				return fmt.Errorf("could not fetch proven withdrawal: %w", err)
+			}
+		} else {
+			result.AlreadyProven = result.ProveReceipt == nil
+		}
+		if state.Stage == stageStarted || state.Stage == stageProving {
+			if err := state.advance(stageProven); err != nil {
+				return err
+			}
+		}
+
+		// The reads of the finalization get a deadline of their own, the lookups above may have used most of theirs
+		readCtx, cancel = context.WithTimeout(ctx, internal.CallTimeout)
+		defer cancel()
+
+		permissionedDisputeGame, err := bindings.NewPermissionedDisputeGame(proven.DisputeGameProxy, l1Client)
+		if err != nil {
+			return fmt.Errorf("could not construct permissioned dispute game")
+		}
+
+		proofMaturityDelaySeconds, err := optimismPortal.ProofMaturityDelaySeconds(&bind.CallOpts{Context: readCtx})
+		if err != nil {
+			return fmt.Errorf("could not call OptimismPortal.ProofMaturityDelaySeconds: %w", err)
+		}
+		proofMaturityDelay := time.Duration(proofMaturityDelaySeconds.Int64() * int64(time.Second))
+
+		finalityDelaySeconds, err := optimismPortal.DisputeGameFinalityDelaySeconds(&bind.CallOpts{Context: readCtx})
+		if err != nil {
+			return fmt.Errorf("could not call OptimismPortal.DisputeGameFinalityDelaySeconds: %w", err)
+		}
+		finalityDelay := time.Duration(finalityDelaySeconds.Int64() * int64(time.Second))
+
+		proofMaturityTime := time.Unix(int64(proven.Timestamp), 0).Add(proofMaturityDelay)
+
+		err = internal.PollUntil(ctx, c.Duration("poll-interval"), c.Duration("max-poll-interval"), func() (bool, error) {
+			pollCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+			defer cancel()
+
+			// Read failures are retried on the next poll, only failed transactions abort the wait
+			isClaimResolved, err := permissionedDisputeGame.ResolvedSubgames(&bind.CallOpts{Context: pollCtx}, internal.RootClaimIndex)
+			if err != nil {
+				log.Warn("PermissionedDisputeGame.ResolvedSubgames failed, retrying...", "error", err)
+				return false, nil
+			}
+			if !isClaimResolved {
+				plan, err := internal.PlanSubgameResolution(&bind.CallOpts{Context: pollCtx}, permissionedDisputeGame)
+				if err != nil {
+					log.Warn("could not plan the subgame resolution, retrying...", "error", err)
+					return false, nil
+				}
+
+				for _, index := range plan.Resolvable {
+					receipt, err := internal.SendTransaction(ctx, l1Client, opts, "PermissionedDisputeGame.ResolveClaim", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+						return permissionedDisputeGame.ResolveClaim(opts, index, common.Big0)
+					})
+					if err != nil {
+						return false, err
+					}
+					log.Info("successfully executed PermissionedDisputeGame.ResolveClaim()", "claim", index, "tx", receipt.TxHash.Hex())
+					output.AddTx(receipt.TxHash)
+					state.ResolveTxHashes = append(state.ResolveTxHashes, receipt.TxHash)
+					if err := state.save(); err != nil {
+						return false, err
+					}
+				}
+
+				if !plan.RootResolved {
+					log.Info("challenger duration period has not passed, waiting...", "remaining", plan.Remaining)
+					return false, nil
+				}
+			}
+
+			disputeGameResolvedAt, err := permissionedDisputeGame.ResolvedAt(&bind.CallOpts{Context: pollCtx})
+			if err != nil {
+				log.Warn("could not fetch PermissionedDisputeGame.ResolvedAt, retrying...", "error", err)
+				return false, nil
+			}
+			if disputeGameResolvedAt == 0 {
+				receipt, err := internal.SendTransaction(ctx, l1Client, opts, "PermissionedDisputeGame.Resolve", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+					return permissionedDisputeGame.Resolve(opts)
+				})
+				if err != nil {
+					return false, err
+				}
+				log.Info("successfully executed PermissionedDisputeGame.Resolve()", "tx", receipt.TxHash.Hex())
+				output.AddTx(receipt.TxHash)
+				state.ResolveTxHashes = append(state.ResolveTxHashes, receipt.TxHash)
+				if err := state.save(); err != nil {
+					return false, err
+				}
+
+				disputeGameResolvedAt, err = permissionedDisputeGame.ResolvedAt(&bind.CallOpts{Context: pollCtx})
+				if err != nil {
+					log.Warn("could not fetch PermissionedDisputeGame.ResolvedAt, retrying...", "error", err)
+					return false, nil
+				}
+			}
+
+			// Like finalize, a game the challenger won is reported right away instead of waiting for its finality delay
+			disputeGameStatus, err := internal.CheckGameNotChallenged(&bind.CallOpts{Context: pollCtx}, proven.DisputeGameProxy, permissionedDisputeGame)
+			if errors.Is(err, internal.ErrGameChallenged) {
+				log.Error("PermissionedDisputeGame resolved against the proposer", "status", disputeGameStatus.String(), "resolvedAt", time.Unix(int64(disputeGameResolvedAt), 0), "game", proven.DisputeGameProxy)
+				return false, err
+			}
+			if err != nil {
+				log.Warn("could not fetch PermissionedDisputeGame.Status, retrying...", "error", err)
+				return false, nil
+			}
+
+			finalityDelayTime := time.Unix(int64(disputeGameResolvedAt), 0).Add(finalityDelay)
+			now, err := internal.Now(pollCtx, l1Client, timeSource)
+			if err != nil {
+				log.Warn("could not read the current time, retrying...", "error", err)
+				return false, nil
+			}
+			untilProofMaturityTime := proofMaturityTime.Sub(now)
+			untilFinalityDelayTime := finalityDelayTime.Sub(now)
+
+			if untilProofMaturityTime > 0 || untilFinalityDelayTime > 0 {
+				log.Info("waiting for the proof to mature and the finality period to pass...",
+					"proofMaturityTime", proofMaturityTime,
+					"finalityDelayTime", finalityDelayTime,
+					"until proofMaturityTime", untilProofMaturityTime,
+					"until finalityDelayTime", untilFinalityDelayTime,
+				)
+				return false, nil
+			}
+
+			return true, nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed waiting for the withdrawal to become finalizable: %w", err)
+		}
+
+		readCtx, cancel = context.WithTimeout(ctx, internal.CallTimeout)
+		defer cancel()
+
+		log.Info("calling OptimismPortal.CheckWithdrawal to validate that withdrawal can be finalized")
+		err = optimismPortal.CheckWithdrawal(&bind.CallOpts{Context: readCtx}, messagePassedEvent.WithdrawalHash, account)
+		if err != nil {
+			return fmt.Errorf("call to OptimismPortal.CheckWithdrawal failed: %w", err)
+		}
+
+		finalizeTx, err := internal.BroadcastTransaction(ctx, l1Client, opts, "OptimismPortal.FinalizeWithdrawalTransaction", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return optimismPortal.FinalizeWithdrawalTransaction(opts, withdrawalTransaction)
+		})
+		if err != nil {
+			return err
+		}
+		finalizeTxHash := finalizeTx.Hash()
+		state.FinalizeTxHash = &finalizeTxHash
+		if err := state.advance(stageFinalizing); err != nil {
+			return err
+		}
+
+		receipt, err := internal.WaitForTransaction(ctx, l1Client, opts, "OptimismPortal.FinalizeWithdrawalTransaction", finalizeTx)
+		if err != nil {
+			return err
+		}
+		return finish(receipt)
+	}),
+}
+
+type proveAndFinalizeResult struct {
+	WithdrawalTxHash common.Hash         `json:"withdrawalTxHash"`
+	Resumed          bool                `json:"resumed"`
+	AlreadyProven    bool                `json:"alreadyProven"`
+	AlreadyFinalized bool                `json:"alreadyFinalized"`
+	ProveTxHash      common.Hash         `json:"proveTxHash"`
+	ProveReceipt     *types.Receipt      `json:"proveReceipt,omitempty"`
+	ProveGas         *internal.GasReport `json:"proveGas,omitempty"`
+	FinalizeTxHash   common.Hash         `json:"finalizeTxHash"`
+	FinalizeReceipt  *types.Receipt      `json:"finalizeReceipt,omitempty"`
+	FinalizeGas      *internal.GasReport `json:"finalizeGas,omitempty"`
+	PreBalance       *big.Int            `json:"preBalance,omitempty"`
+	PostBalance      *big.Int            `json:"postBalance,omitempty"`
+}
@@ -0,0 +1,191 @@
+package withdraw_cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Golem-Base/op-probe/bindings"
+	"github.com/Golem-Base/op-probe/internal"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+var ResolveGameCommand = &cli.Command{
+	Name:  "resolve-game",
+	Usage: "Resolves every subgame of a dispute game whose challenger clock has expired and then the game itself, for devnets without a challenger",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "private-key",
+			Usage: "Private key of the address sending the resolve transactions, or - to read it from stdin, required unless --impersonate is set",
+		},
+		&cli.StringFlag{
+			Name:  "impersonate",
+			Usage: "Address to send from without its private key, on dev nodes supporting account impersonation (anvil, hardhat) or holding it unlocked",
+		},
+		&cli.StringFlag{
+			Name:     "l1-rpc-url",
+			Usage:    "Url for L1 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "game",
+			Usage:    "Address of the dispute game proxy to resolve",
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "Wait for the challenger clocks that have not expired yet instead of exiting",
+		},
+		&cli.DurationFlag{
+			Name:  "poll-interval",
+			Usage: "Interval between checks of the challenger clocks with --wait",
+			Value: 12 * time.Second,
+		},
+		&cli.DurationFlag{
+			Name:  "max-poll-interval",
+			Usage: "Back off exponentially from poll-interval up to this interval between checks, 0 polls at a fixed interval",
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "Maximum time to wait for the challenger clocks with --wait",
+			Value: time.Hour,
+		},
+		&cli.Uint64Flag{
+			Name:  "nonce",
+			Usage: "Nonce of the first transaction sent (default: pending nonce of the sender)",
+		},
+	},
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := c.Context
+
+		sender, privateKey, err := internal.SenderAccount(c)
+		if err != nil {
+			return err
+		}
+		output.SetAccount(sender)
+
+		gameAddress, err := internal.SafeParseAddress(c.String("game"))
+		if err != nil {
+			return fmt.Errorf("could not parse game address: %w", err)
+		}
+
+		l1RpcUrl := c.String("l1-rpc-url")
+		l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
+		}
+
+		if err := internal.ValidateChainIds(c, l1ChainId, nil); err != nil {
+			return err
+		}
+
+		permissionedDisputeGame, err := bindings.NewPermissionedDisputeGame(gameAddress, l1Client)
+		if err != nil {
+			return fmt.Errorf("could not construct permissioned dispute game")
+		}
+
+		var opts *bind.TransactOpts
+		if privateKey == nil {
+			opts, err = internal.NewImpersonatingTransactor(ctx, c, l1Client, sender)
+		} else {
+			opts, err = internal.NewTransactor(ctx, c, l1Client, privateKey, l1ChainId)
+		}
+		if err != nil {
+			return err
+		}
+
+		result := &resolveGameResult{Game: gameAddress}
+		output.Result = result
+
+		waitCtx := ctx
+		if c.Bool("wait") {
+			var cancel context.CancelFunc
+			waitCtx, cancel = context.WithTimeout(ctx, c.Duration("timeout"))
+			defer cancel()
+		}
+
+		// Subgames are resolved as their clocks expire, a read failure is retried on the next poll while waiting
+		err = internal.PollUntil(waitCtx, c.Duration("poll-interval"), c.Duration("max-poll-interval"), func() (bool, error) {
+			readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+			defer cancel()
+
+			resolvedAt, err := permissionedDisputeGame.ResolvedAt(&bind.CallOpts{Context: readCtx})
+			if err != nil {
+				err = fmt.Errorf("could not fetch PermissionedDisputeGame.ResolvedAt: %w", err)
+			}
+			var plan *internal.SubgameResolution
+			if err == nil && resolvedAt == 0 {
+				plan, err = internal.PlanSubgameResolution(&bind.CallOpts{Context: readCtx}, permissionedDisputeGame)
+			}
+			if err != nil {
+				if c.Bool("wait") {
+					log.Warn("could not read the dispute game, retrying...", "error", err)
+					return false, nil
+				}
+				return false, err
+			}
+			if resolvedAt != 0 {
+				return true, nil
+			}
+
+			for _, index := range plan.Resolvable {
+				receipt, err := internal.SendTransaction(ctx, l1Client, opts, "PermissionedDisputeGame.ResolveClaim", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+					return permissionedDisputeGame.ResolveClaim(opts, index, common.Big0)
+				})
+				if err != nil {
+					return false, fmt.Errorf("could not resolve claim %d: %w", index, err)
+				}
+				log.Info("successfully executed PermissionedDisputeGame.ResolveClaim()", "claim", index, "tx", receipt.TxHash.Hex())
+				result.ResolvedClaims = append(result.ResolvedClaims, index.Uint64())
+				result.TxHashes = append(result.TxHashes, receipt.TxHash)
+				output.AddTx(receipt.TxHash)
+			}
+
+			if !plan.RootResolved {
+				if !c.Bool("wait") {
+					return false, fmt.Errorf("%w, the last challenger clock has %s remaining", internal.ErrGameNotResolved, plan.Remaining)
+				}
+				log.Info("challenger clocks have not expired yet, waiting...", "remaining", plan.Remaining)
+				return false, nil
+			}
+
+			receipt, err := internal.SendTransaction(ctx, l1Client, opts, "PermissionedDisputeGame.Resolve", func(opts *bind.TransactOpts) (*types.Transaction, error) {
+				return permissionedDisputeGame.Resolve(opts)
+			})
+			if err != nil {
+				return false, err
+			}
+			log.Info("successfully executed PermissionedDisputeGame.Resolve()", "tx", receipt.TxHash.Hex())
+			result.TxHashes = append(result.TxHashes, receipt.TxHash)
+			output.AddTx(receipt.TxHash)
+			return true, nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not resolve dispute game %s: %w", gameAddress.Hex(), err)
+		}
+
+		readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+		defer cancel()
+		status, err := permissionedDisputeGame.Status(&bind.CallOpts{Context: readCtx})
+		if err != nil {
+			return fmt.Errorf("could not fetch PermissionedDisputeGame.Status: %w", err)
+		}
+		result.Status = internal.GameStatus(status).String()
+		output.Primary = result.TxHashes
+
+		log.Info("dispute game is resolved", "game", gameAddress, "status", result.Status, "resolvedClaims", len(result.ResolvedClaims))
+
+		return nil
+	}),
+}
+
+type resolveGameResult struct {
+	Game           common.Address `json:"game"`
+	ResolvedClaims []uint64       `json:"resolvedClaims"`
+	TxHashes       []common.Hash  `json:"txHashes"`
+	Status         string         `json:"status"`
+}
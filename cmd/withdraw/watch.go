@@ -0,0 +1,308 @@
+package withdraw_cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Golem-Base/op-probe/bindings"
+	"github.com/Golem-Base/op-probe/internal"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+var WatchCommand = &cli.Command{
+	Name:  "watch",
+	Usage: "Polls a single withdrawal and logs every status transition until it is finalized (permissioned game)",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "account",
+			Usage:    "account that proves the withdrawal",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "l1-rpc-url",
+			Usage:    "Url for L1 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "l2-rpc-url",
+			Usage:    "Url for L2 execution client, or comma separated urls to fail over between",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "tx",
+			Usage:    "The L2 withdrawal transaction hash",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "dispute-game-factory-address",
+			Usage: "Contract address for DisputeGameFactory (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.StringFlag{
+			Name:  "optimism-portal-address",
+			Usage: "Contract address for OptimismPortal (* or proxy), discovered from the SystemConfig with --autodiscover when omitted",
+		},
+		&cli.DurationFlag{
+			Name:  "poll-interval",
+			Usage: "Interval between checks of the withdrawal status",
+			Value: 12 * time.Second,
+		},
+		&cli.DurationFlag{
+			Name:  "max-poll-interval",
+			Usage: "Back off exponentially from poll-interval up to this interval between checks while the status is unchanged, 0 polls at a fixed interval",
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "Maximum time to watch the withdrawal for, 0 watches until it is finalized",
+		},
+	},
+	Action: internal.WithOutput(func(c *cli.Context, output *internal.Output) error {
+		ctx := c.Context
+
+		l1RpcUrl := c.String("l1-rpc-url")
+		l1Client, l1ChainId, err := internal.ConnectClient(ctx, c, l1RpcUrl, "l1-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l1RpcUrl, err)
+		}
+
+		l2RpcUrl := c.String("l2-rpc-url")
+		l2Client, l2ChainId, err := internal.ConnectClient(ctx, c, l2RpcUrl, "l2-chain-id")
+		if err != nil {
+			return fmt.Errorf("could not connect to client at %s: %w", l2RpcUrl, err)
+		}
+
+		if err := internal.ValidateChainIds(c, l1ChainId, l2ChainId); err != nil {
+			return err
+		}
+
+		account, err := internal.SafeParseAddress(c.String("account"))
+		if err != nil {
+			return fmt.Errorf("could not parse account: %w", err)
+		}
+		output.SetAccount(account)
+
+		withdrawalTxHash, err := internal.SafeParseHash(c.String("tx"))
+		if err != nil {
+			return fmt.Errorf("could not parse tx: %w", err)
+		}
+
+		w, err := newFaultProofWithdrawer(ctx, c, l1Client, l2Client, l1ChainId, l2ChainId)
+		if err != nil {
+			return err
+		}
+		watcher, err := w.newWatcher(ctx, account, withdrawalTxHash)
+		if err != nil {
+			return err
+		}
+
+		var transitions []*withdrawalProgress
+		output.Result = &transitions
+
+		watchCtx := ctx
+		if timeout := c.Duration("timeout"); timeout > 0 {
+			var cancelWatch context.CancelFunc
+			watchCtx, cancelWatch = context.WithTimeout(ctx, timeout)
+			defer cancelWatch()
+		}
+
+		var last *withdrawalProgress
+		poll := func() (bool, error) {
+			pollCtx, cancel := context.WithTimeout(watchCtx, internal.CallTimeout)
+			defer cancel()
+
+			// Read failures are retried on the next poll
+			progress, err := watcher.progress(&bind.CallOpts{Context: pollCtx})
+			if err != nil {
+				log.Warn("could not read withdrawal status, retrying...", "error", err)
+				return false, nil
+			}
+
+			if last == nil || progress.Status != last.Status {
+				from := "none"
+				if last != nil {
+					from = last.Status.String()
+				}
+				log.Info("withdrawal status advanced",
+					"tx", withdrawalTxHash.Hex(),
+					"from", from,
+					"to", progress.Status.String(),
+					"at", progress.At,
+					"next", progress.Next,
+					"nextIn", progress.NextIn,
+				)
+				transitions = append(transitions, progress)
+				last = progress
+			} else {
+				log.Debug("withdrawal status unchanged", "status", progress.Status.String(), "next", progress.Next, "nextIn", progress.NextIn)
+			}
+
+			return progress.Status == Finalized, nil
+		}
+
+		if err := internal.PollUntil(watchCtx, c.Duration("poll-interval"), c.Duration("max-poll-interval"), poll); err != nil {
+			return fmt.Errorf("stopped watching withdrawal in status %s: %w", last.statusString(), err)
+		}
+
+		return nil
+	}),
+}
+
+// withdrawalWatcher reads the status of a single withdrawal proven by account
+type withdrawalWatcher struct {
+	*Withdrawer
+	account        common.Address
+	receipt        *types.Receipt
+	withdrawalHash common.Hash
+}
+
+// newWatcher returns the watcher of the withdrawal initiated in withdrawalTxHash and proven by account
+func (w *Withdrawer) newWatcher(ctx context.Context, account common.Address, withdrawalTxHash common.Hash) (*withdrawalWatcher, error) {
+	readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+
+	withdrawalTxReceipt, err := w.l2Receipts.TransactionReceipt(readCtx, withdrawalTxHash)
+	if err != nil {
+		return nil, fmt.Errorf("could not get receipt for withdrawal event in transaction: %s: %w", withdrawalTxHash.Hex(), err)
+	}
+	messagePassedEvent, err := withdrawals.ParseMessagePassed(withdrawalTxReceipt)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the MessagePassed event from the withdrawal transaction hash")
+	}
+
+	return &withdrawalWatcher{
+		Withdrawer:     w,
+		account:        account,
+		receipt:        withdrawalTxReceipt,
+		withdrawalHash: messagePassedEvent.WithdrawalHash,
+	}, nil
+}
+
+// Status reads the current status of the withdrawal initiated in withdrawalTxHash and proven by account
+func (w *Withdrawer) Status(ctx context.Context, account common.Address, withdrawalTxHash common.Hash) (*withdrawalProgress, error) {
+	if w.optimismPortal == nil {
+		return nil, fmt.Errorf("withdrawer has no OptimismPortal to read the withdrawal status from")
+	}
+	watcher, err := w.newWatcher(ctx, account, withdrawalTxHash)
+	if err != nil {
+		return nil, err
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, internal.CallTimeout)
+	defer cancel()
+	return watcher.progress(&bind.CallOpts{Context: readCtx})
+}
+
+// withdrawalProgress is the status of a watched withdrawal along with the step it is waiting for
+type withdrawalProgress struct {
+	Status WithdrawalStatus `json:"status"`
+	At     time.Time        `json:"at"`
+	Next   string           `json:"next,omitempty"`
+	NextIn time.Duration    `json:"nextIn"`
+}
+
+func (p *withdrawalProgress) statusString() string {
+	if p == nil {
+		return "unknown"
+	}
+	return p.Status.String()
+}
+
+// progress reads the current status of the withdrawal and how long until its next step can be taken, a zero wait
+// meaning that the next step can be taken right away
+func (w *withdrawalWatcher) progress(opts *bind.CallOpts) (*withdrawalProgress, error) {
+	progress := &withdrawalProgress{At: time.Now()}
+
+	finalized, err := w.optimismPortal.FinalizedWithdrawals(opts, w.withdrawalHash)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch OptimismPortal.FinalizedWithdrawals: %w", err)
+	}
+	if finalized {
+		progress.Status = Finalized
+		return progress, nil
+	}
+
+	proven, err := w.optimismPortal.ProvenWithdrawals(opts, w.withdrawalHash, w.account)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch proven withdrawal: %w", err)
+	}
+
+	if proven.Timestamp == 0 {
+		game, err := internal.FindLatestGame(opts, &w.disputeGameFactory.DisputeGameFactoryCaller, &w.optimismPortal.OptimismPortal2Caller)
+		if errors.Is(err, internal.ErrNoGames) {
+			progress.Status = Initialized
+			progress.Next = "dispute game proposal, no games proposed yet"
+			return progress, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find latest game: %w", err)
+		}
+		gameL2Block, err := internal.SearchResultL2BlockNumber(game)
+		if err != nil {
+			return nil, err
+		}
+		gameL2BlockNumber := gameL2Block.Uint64()
+		if gameL2BlockNumber >= w.receipt.BlockNumber.Uint64() {
+			progress.Status = Provable
+			progress.Next = "prove"
+		} else {
+			progress.Status = Initialized
+			progress.Next = fmt.Sprintf("dispute game proposal, %d L2 blocks remaining", w.receipt.BlockNumber.Uint64()-gameL2BlockNumber)
+		}
+		return progress, nil
+	}
+
+	permissionedDisputeGame, err := bindings.NewPermissionedDisputeGame(proven.DisputeGameProxy, w.l1Client)
+	if err != nil {
+		return nil, fmt.Errorf("could not construct permissioned dispute game")
+	}
+
+	resolvedAt, err := permissionedDisputeGame.ResolvedAt(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch DisputeGame.ResolvedAt: %w", err)
+	}
+	if resolvedAt != 0 {
+		proofMaturityDelaySeconds, err := w.optimismPortal.ProofMaturityDelaySeconds(opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not call OptimismPortal.ProofMaturityDelaySeconds: %w", err)
+		}
+		finalityDelaySeconds, err := w.optimismPortal.DisputeGameFinalityDelaySeconds(opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not call OptimismPortal.DisputeGameFinalityDelaySeconds: %w", err)
+		}
+
+		proofMaturityTime := time.Unix(int64(proven.Timestamp)+proofMaturityDelaySeconds.Int64(), 0)
+		finalityDelayTime := time.Unix(int64(resolvedAt)+finalityDelaySeconds.Int64(), 0)
+
+		progress.Status = GameResolved
+		progress.Next = "finalize"
+		progress.NextIn = max(time.Until(proofMaturityTime), time.Until(finalityDelayTime), 0)
+		return progress, nil
+	}
+
+	isClaimResolved, err := permissionedDisputeGame.ResolvedSubgames(opts, internal.RootClaimIndex)
+	if err != nil {
+		return nil, fmt.Errorf("PermissionedDisputeGame.ResolvedSubgame failed: %w", err)
+	}
+	if isClaimResolved {
+		progress.Status = ClaimResolved
+		progress.Next = "resolve"
+		return progress, nil
+	}
+
+	// The root claim resolves once the clocks of the claims countering it have expired as well
+	plan, err := internal.PlanSubgameResolution(opts, permissionedDisputeGame)
+	if err != nil {
+		return nil, err
+	}
+
+	progress.Status = Proven
+	progress.Next = "resolveClaim"
+	progress.NextIn = plan.Remaining
+	return progress, nil
+}
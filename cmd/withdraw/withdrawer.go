@@ -0,0 +1,93 @@
+package withdraw_cmd
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/Golem-Base/op-probe/internal"
+	e2eBindings "github.com/ethereum-optimism/optimism/op-e2e/bindings"
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/urfave/cli/v2"
+)
+
+// Withdrawer holds the clients and contracts of an L1 and L2 pair and takes withdrawals through their steps: Init,
+// Prove, Finalize, List and Status. The withdraw commands resolve their flags into a Withdrawer, so that the
+// withdrawal flow can be embedded without them, in integration tests for instance.
+type Withdrawer struct {
+	l1Client            *ethclient.Client
+	l2Client            *ethclient.Client
+	l1ChainId           *big.Int
+	l2ChainId           *big.Int
+	l2StandardBridge    *e2eBindings.L2StandardBridge
+	l2ToL1MessagePasser *e2eBindings.L2ToL1MessagePasser
+	// disputeGameFactory and optimismPortal are nil for a Withdrawer without fault proof contracts, which only
+	// initiates withdrawals or finalizes them through the legacy portal
+	disputeGameFactory    *opNodeBindings.DisputeGameFactory
+	optimismPortal        *bindingspreview.OptimismPortal2
+	optimismPortalAddress common.Address
+	// Receipts and proofs are cached across the withdrawals of a Withdrawer, so that each is only fetched once
+	l2Receipts *internal.ReceiptCache
+	proofs     *internal.WithdrawalProofCache
+}
+
+// NewWithdrawer binds the L2 predeploys on l2Client and, unless optimismPortalAddress is the zero address, the
+// DisputeGameFactory and OptimismPortal2 at the given addresses on l1Client. l1Client may be nil for a Withdrawer that
+// only initiates withdrawals.
+func NewWithdrawer(l1Client, l2Client *ethclient.Client, l1ChainId, l2ChainId *big.Int, disputeGameFactoryAddress, optimismPortalAddress common.Address) (*Withdrawer, error) {
+	l2StandardBridge, err := e2eBindings.NewL2StandardBridge(predeploys.L2StandardBridgeAddr, l2Client)
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate L2StandardBridge contract: %w", err)
+	}
+	l2ToL1MessagePasser, err := e2eBindings.NewL2ToL1MessagePasser(predeploys.L2ToL1MessagePasserAddr, l2Client)
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate L2ToL1MessagePasser contract: %w", err)
+	}
+
+	w := &Withdrawer{
+		l1Client:            l1Client,
+		l2Client:            l2Client,
+		l1ChainId:           l1ChainId,
+		l2ChainId:           l2ChainId,
+		l2StandardBridge:    l2StandardBridge,
+		l2ToL1MessagePasser: l2ToL1MessagePasser,
+		l2Receipts:          internal.NewReceiptCache(l2Client, internal.DefaultReceiptCacheSize),
+		proofs:              internal.NewWithdrawalProofCache(),
+	}
+	if optimismPortalAddress == internal.ZeroAddress {
+		return w, nil
+	}
+
+	w.disputeGameFactory, err = opNodeBindings.NewDisputeGameFactory(disputeGameFactoryAddress, l1Client)
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate DisputeGameFactory contract: %w", err)
+	}
+	w.optimismPortal, err = bindingspreview.NewOptimismPortal2(optimismPortalAddress, l1Client)
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+	}
+	w.optimismPortalAddress = optimismPortalAddress
+	return w, nil
+}
+
+// newFaultProofWithdrawer creates the Withdrawer of a withdraw command on a chain with fault proofs, resolving the
+// addresses of the DisputeGameFactory and OptimismPortal from its flags
+func newFaultProofWithdrawer(ctx context.Context, c *cli.Context, l1Client, l2Client *ethclient.Client, l1ChainId, l2ChainId *big.Int) (*Withdrawer, error) {
+	disputeGameFactoryAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "dispute-game-factory-address")
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve DisputeGameFactory address: %w", err)
+	}
+	optimismPortalAddress, err := internal.L1ContractAddress(ctx, c, l1Client, "optimism-portal-address")
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve OptimismPortal address: %w", err)
+	}
+	// The binding is only used to check that the portal selected by --portal-version proves against dispute games
+	if _, err := internal.NewOptimismPortal2(ctx, c, l1Client, optimismPortalAddress); err != nil {
+		return nil, fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+	}
+	return NewWithdrawer(l1Client, l2Client, l1ChainId, l2ChainId, disputeGameFactoryAddress, optimismPortalAddress)
+}
@@ -0,0 +1,115 @@
+package withdraw_cmd
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/Golem-Base/op-probe/internal"
+	"github.com/Golem-Base/op-probe/internal/testutil"
+	e2eBindings "github.com/ethereum-optimism/optimism/op-e2e/bindings"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newWithdrawTest starts a fake L2 whose L2StandardBridge passes a message to L1 for every ETH withdrawal, and returns
+// an L2-only Withdrawer with the transactor of a funded sender
+func newWithdrawTest(t *testing.T) (*testutil.Chain, *Withdrawer, *bind.TransactOpts) {
+	t.Helper()
+	l2 := testutil.NewChain(t, big.NewInt(901))
+
+	bridgeABI, err := e2eBindings.L2StandardBridgeMetaData.GetAbi()
+	if err != nil {
+		t.Fatalf("could not parse L2StandardBridge ABI: %v", err)
+	}
+	messagePasserABI, err := e2eBindings.L2ToL1MessagePasserMetaData.GetAbi()
+	if err != nil {
+		t.Fatalf("could not parse L2ToL1MessagePasser ABI: %v", err)
+	}
+	l2.HandleTransactions(predeploys.L2StandardBridgeAddr, func(from common.Address, tx *types.Transaction, receipt *types.Receipt) error {
+		method, err := bridgeABI.MethodById(tx.Data()[:4])
+		if err != nil {
+			return err
+		}
+		args, err := method.Inputs.Unpack(tx.Data()[4:])
+		if err != nil {
+			return err
+		}
+		event := messagePasserABI.Events["MessagePassed"]
+		// The hash only has to be unique to the withdrawal for the test
+		data, err := event.Inputs.NonIndexed().Pack(tx.Value(), new(big.Int).SetUint64(uint64(args[1].(uint32))), []byte{}, crypto.Keccak256Hash(tx.Hash().Bytes()))
+		if err != nil {
+			return err
+		}
+		l2.AppendLog(receipt, &types.Log{
+			Address: predeploys.L2ToL1MessagePasserAddr,
+			Topics:  []common.Hash{event.ID, common.BigToHash(common.Big1), common.BytesToHash(predeploys.L2CrossDomainMessengerAddr.Bytes()), common.BytesToHash(args[0].(common.Address).Bytes())},
+			Data:    data,
+		})
+		return nil
+	})
+
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	l2.SetBalance(crypto.PubkeyToAddress(privateKey.PublicKey), big.NewInt(params.Ether))
+	opts, err := bind.NewKeyedTransactorWithChainID(privateKey, l2.ChainID)
+	if err != nil {
+		t.Fatalf("could not create transactor: %v", err)
+	}
+
+	w, err := NewWithdrawer(nil, l2.Client(), nil, l2.ChainID, internal.ZeroAddress, internal.ZeroAddress)
+	if err != nil {
+		t.Fatalf("could not create withdrawer: %v", err)
+	}
+	return l2, w, opts
+}
+
+func TestWithdrawerInit(t *testing.T) {
+	l2, w, opts := newWithdrawTest(t)
+
+	result := &initResult{
+		Sender:     opts.From,
+		Recipient:  common.HexToAddress("0x000000000000000000000000000000000000beef"),
+		Amount:     big.NewInt(params.GWei),
+		L1GasLimit: internal.RECEIVE_DEFAULT_GAS_LIMIT,
+	}
+	if err := w.Init(context.Background(), opts, result); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	sent := l2.Sent()
+	if len(sent) != 1 || sent[0].Hash() != result.TxHash {
+		t.Fatalf("expected the withdrawal as the only L2 transaction, sent %d", len(sent))
+	}
+	if sent[0].Value().Cmp(result.Amount) != 0 {
+		t.Errorf("withdrawal sent %s, want %s", sent[0].Value(), result.Amount)
+	}
+	if want := crypto.Keccak256Hash(result.TxHash.Bytes()); result.WithdrawalHash != want {
+		t.Errorf("withdrawal hash is %s, want %s", result.WithdrawalHash, want)
+	}
+}
+
+func TestWithdrawerWithoutPortal(t *testing.T) {
+	_, w, opts := newWithdrawTest(t)
+	ctx := context.Background()
+	txHash := common.HexToHash("0x02")
+
+	if err := w.Prove(ctx, opts, ProveConfig{}, &proveResult{WithdrawalTxHash: txHash}); err == nil {
+		t.Error("Prove succeeded without an OptimismPortal")
+	}
+	if err := w.Finalize(ctx, opts, FinalizeConfig{}, &finalizeResult{TxHash: txHash}); err == nil {
+		t.Error("Finalize succeeded without an OptimismPortal")
+	}
+	if _, err := w.List(ctx, opts.From); err == nil {
+		t.Error("List succeeded without an OptimismPortal")
+	}
+	if _, err := w.Status(ctx, opts.From, txHash); err == nil {
+		t.Error("Status succeeded without an OptimismPortal")
+	}
+}
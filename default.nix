@@ -1,4 +1,9 @@
-{buildGoModule}:
+{
+  buildGoModule,
+  lib,
+  commit ? null,
+  buildDate ? null,
+}:
 buildGoModule {
   pname = "probe";
   version = "0.0.0";
@@ -7,6 +12,11 @@ buildGoModule {
 
   vendorHash = "sha256-tI+PM+K5yBrwomC9hFxduwEdAKb1vvK+k4T6hZKwK8k=";
 
+  # Build information not passed in by the flake is resolved at runtime in main.go
+  ldflags =
+    lib.optional (commit != null) "-X main.GitCommit=${commit}"
+    ++ lib.optional (buildDate != null) "-X main.BuildDate=${buildDate}";
+
   doCheck = false;
 
   meta.mainProgram = "probe";
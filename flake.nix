@@ -50,7 +50,10 @@
           ];
         };
 
-        packages.op-probe = pkgs.callPackage ./. {};
+        packages.op-probe = pkgs.callPackage ./. {
+          commit = inputs.self.shortRev or (inputs.self.dirtyShortRev or null);
+          buildDate = inputs.self.lastModifiedDate or null;
+        };
         packages.default = self'.packages.op-probe;
       };
     };
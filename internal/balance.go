@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/urfave/cli/v2"
+)
+
+// ErrSenderBalanceLow is returned before sending a transaction from an account whose balance fell below --min-balance.
+// Commands sending transactions in a loop stop on it instead of failing every remaining transaction.
+var ErrSenderBalanceLow = errors.New("sender balance below threshold")
+
+// minSenderBalance is the --min-balance threshold checked before every transaction, nil disables the check
+var minSenderBalance *big.Int
+
+// SetMinBalance reads the --min-balance global flag used by every transaction sent afterwards
+func SetMinBalance(c *cli.Context) error {
+	if !c.IsSet("min-balance") {
+		minSenderBalance = nil
+		return nil
+	}
+	threshold, err := ParseUint256BigInt(c.String("min-balance"))
+	if err != nil {
+		return fmt.Errorf("could not parse min-balance: %w", err)
+	}
+	minSenderBalance = threshold
+	return nil
+}
+
+// checkSenderBalance fails with ErrSenderBalanceLow when the pending balance of account is below --min-balance
+func checkSenderBalance(ctx context.Context, client *ethclient.Client, account common.Address) error {
+	if minSenderBalance == nil {
+		return nil
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+	balance, err := client.PendingBalanceAt(readCtx, account)
+	if err != nil {
+		return fmt.Errorf("could not fetch balance of sender %s: %w", account.Hex(), err)
+	}
+	if balance.Cmp(minSenderBalance) < 0 {
+		return fmt.Errorf("%w: %s has %s, min-balance is %s", ErrSenderBalanceLow, account.Hex(), FormatWei(balance), FormatWei(minSenderBalance))
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RPCBatchSize is the number of calls sent in a single batch request, below the batch limits of common providers
+const RPCBatchSize = 100
+
+// BatchCall is a contract call made through a BatchCaller, Out holds the unpacked return values once it was made
+type BatchCall struct {
+	To     common.Address
+	ABI    *abi.ABI
+	Method string
+	Args   []any
+	Out    []any
+}
+
+// BatchCaller groups contract calls and receipt lookups into batched json-rpc requests to cut the round trips of
+// reading many withdrawals. Endpoints rejecting batch requests are called once per request instead, from the first
+// rejected batch on.
+type BatchCaller struct {
+	client      *rpc.Client
+	blockNumber *big.Int
+
+	mu         sync.Mutex
+	sequential bool
+}
+
+// NewBatchCaller returns a BatchCaller for client making its contract calls at blockNumber, or the latest block when
+// nil
+func NewBatchCaller(client *ethclient.Client, blockNumber *big.Int) *BatchCaller {
+	return &BatchCaller{client: client.Client(), blockNumber: blockNumber}
+}
+
+// Call makes the contract calls in batches and unpacks the return values of each into its Out
+func (b *BatchCaller) Call(ctx context.Context, calls []*BatchCall) error {
+	block := "latest"
+	if b.blockNumber != nil {
+		block = hexutil.EncodeBig(b.blockNumber)
+	}
+
+	elems := make([]rpc.BatchElem, len(calls))
+	for i, call := range calls {
+		data, err := call.ABI.Pack(call.Method, call.Args...)
+		if err != nil {
+			return fmt.Errorf("could not pack %s call: %w", call.Method, err)
+		}
+		elems[i] = rpc.BatchElem{
+			Method: "eth_call",
+			Args:   []any{map[string]any{"to": call.To, "data": hexutil.Bytes(data)}, block},
+			Result: new(hexutil.Bytes),
+		}
+	}
+
+	if err := b.batch(ctx, elems); err != nil {
+		return err
+	}
+
+	for i, call := range calls {
+		if elems[i].Error != nil {
+			return fmt.Errorf("could not call %s on %s: %w", call.Method, call.To.Hex(), elems[i].Error)
+		}
+		out, err := call.ABI.Unpack(call.Method, *elems[i].Result.(*hexutil.Bytes))
+		if err != nil {
+			return fmt.Errorf("could not unpack %s result of %s: %w", call.Method, call.To.Hex(), err)
+		}
+		call.Out = out
+	}
+	return nil
+}
+
+// Receipts looks up the receipts of the transactions in batches, failing when any of them is not found
+func (b *BatchCaller) Receipts(ctx context.Context, txHashes []common.Hash) ([]*types.Receipt, error) {
+	receipts := make([]*types.Receipt, len(txHashes))
+	elems := make([]rpc.BatchElem, len(txHashes))
+	for i, txHash := range txHashes {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_getTransactionReceipt",
+			Args:   []any{txHash},
+			Result: &receipts[i],
+		}
+	}
+
+	if err := b.batch(ctx, elems); err != nil {
+		return nil, err
+	}
+
+	for i, txHash := range txHashes {
+		if elems[i].Error != nil {
+			return nil, fmt.Errorf("could not get receipt of transaction %s: %w", txHash.Hex(), elems[i].Error)
+		}
+		if receipts[i] == nil {
+			return nil, fmt.Errorf("could not get receipt of transaction %s: not found", txHash.Hex())
+		}
+	}
+	return receipts, nil
+}
+
+// batch sends elems in batches of RPCBatchSize, or one by one once the endpoint has rejected a batch. Errors of single
+// requests are left on their elem.
+func (b *BatchCaller) batch(ctx context.Context, elems []rpc.BatchElem) error {
+	for start := 0; start < len(elems); start += RPCBatchSize {
+		chunk := elems[start:min(start+RPCBatchSize, len(elems))]
+
+		if !b.isSequential() {
+			err := b.client.BatchCallContext(ctx, chunk)
+			if err == nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Warn("rpc endpoint rejected a batch request, falling back to sequential calls", "error", err)
+			b.setSequential()
+		}
+
+		for i := range chunk {
+			chunk[i].Error = b.client.CallContext(ctx, chunk[i].Result, chunk[i].Method, chunk[i].Args...)
+		}
+	}
+	return nil
+}
+
+func (b *BatchCaller) isSequential() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sequential
+}
+
+func (b *BatchCaller) setSequential() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sequential = true
+}
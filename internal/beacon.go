@@ -0,0 +1,26 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/urfave/cli/v2"
+)
+
+// CheckL1BeaconURL validates the --l1-beacon-url global flag. No command reads blob data yet: deposits are verified
+// from L1 receipts and withdrawal proofs are built from eth_getProof of the L2 node. A valid url is therefore rejected
+// as unsupported rather than accepted and silently ignored.
+func CheckL1BeaconURL(c *cli.Context) error {
+	if !c.IsSet("l1-beacon-url") {
+		return nil
+	}
+	rawUrl := c.String("l1-beacon-url")
+	endpoint, err := url.Parse(rawUrl)
+	if err != nil {
+		return fmt.Errorf("could not parse l1-beacon-url %s: %w", rawUrl, err)
+	}
+	if (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return fmt.Errorf("l1-beacon-url %s must be an http or https url", rawUrl)
+	}
+	return fmt.Errorf("l1-beacon-url is not supported yet, no command reads blob data from the beacon node")
+}
@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Golem-Base/op-probe/bindings"
+	e2eBindings "github.com/ethereum-optimism/optimism/op-e2e/bindings"
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// callMetaData are the contracts whose methods ParseCallSignature recognises, so that their return values are decoded
+// without spelling out the return types
+var callMetaData = []*bind.MetaData{
+	bindingspreview.OptimismPortal2MetaData,
+	e2eBindings.OptimismPortalMetaData,
+	opNodeBindings.DisputeGameFactoryMetaData,
+	opNodeBindings.L2OutputOracleMetaData,
+	bindings.PermissionedDisputeGameMetaData,
+	e2eBindings.SystemConfigMetaData,
+	e2eBindings.L1StandardBridgeMetaData,
+	e2eBindings.L2StandardBridgeMetaData,
+	e2eBindings.L2ToL1MessagePasserMetaData,
+}
+
+// ParseCallSignature parses the method of the call command, given as name(inputs) with optional return types as
+// name(inputs)(outputs), or as a bare name. A bare name and a signature without return types are looked up in the
+// methods of the known contracts, a signature none of them has is called without decoding its return data.
+func ParseCallSignature(sig string) (abi.Method, error) {
+	sig = strings.ReplaceAll(sig, " ", "")
+	open := strings.Index(sig, "(")
+	if open == -1 {
+		return findKnownMethod(sig, func(method abi.Method) bool { return method.RawName == sig })
+	}
+
+	name := sig[:open]
+	inputsEnd := matchingParen(sig, open)
+	if name == "" || inputsEnd == -1 {
+		return abi.Method{}, fmt.Errorf("invalid signature %q, expected name(types)", sig)
+	}
+	inputs, err := parseArguments(sig[open+1 : inputsEnd])
+	if err != nil {
+		return abi.Method{}, fmt.Errorf("invalid signature %q: %w", sig, err)
+	}
+
+	rest := sig[inputsEnd+1:]
+	if rest == "" {
+		canonical := abi.NewMethod(name, name, abi.Function, "view", false, false, inputs, nil)
+		if known, err := findKnownMethod(canonical.Sig, func(method abi.Method) bool { return method.Sig == canonical.Sig }); err == nil {
+			return known, nil
+		}
+		return canonical, nil
+	}
+
+	if !strings.HasPrefix(rest, "(") || matchingParen(rest, 0) != len(rest)-1 {
+		return abi.Method{}, fmt.Errorf("invalid signature %q, expected name(types)(types)", sig)
+	}
+	outputs, err := parseArguments(rest[1 : len(rest)-1])
+	if err != nil {
+		return abi.Method{}, fmt.Errorf("invalid signature %q: %w", sig, err)
+	}
+	return abi.NewMethod(name, name, abi.Function, "view", false, false, inputs, outputs), nil
+}
+
+// findKnownMethod returns the single method of the known contracts matched by match, contracts sharing a method such
+// as the two portals count once
+func findKnownMethod(description string, match func(method abi.Method) bool) (abi.Method, error) {
+	var found []abi.Method
+	for _, metaData := range callMetaData {
+		contractABI, err := metaData.GetAbi()
+		if err != nil {
+			continue
+		}
+		for _, method := range contractABI.Methods {
+			if !match(method) {
+				continue
+			}
+			duplicate := false
+			for _, other := range found {
+				duplicate = duplicate || (other.Sig == method.Sig && reflect.DeepEqual(other.Outputs, method.Outputs))
+			}
+			if !duplicate {
+				found = append(found, method)
+			}
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return abi.Method{}, fmt.Errorf("unknown method %s, pass its signature as name(types)(types)", description)
+	case 1:
+		return found[0], nil
+	default:
+		sigs := make([]string, len(found))
+		for i, method := range found {
+			sigs[i] = method.Sig
+		}
+		return abi.Method{}, fmt.Errorf("method %s is ambiguous, pass one of %s", description, strings.Join(sigs, ", "))
+	}
+}
+
+// matchingParen returns the index of the parenthesis closing the one at open in s, or -1
+func matchingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseArguments parses a comma separated list of elementary ABI types, tuples are not supported
+func parseArguments(types string) (abi.Arguments, error) {
+	if types == "" {
+		return nil, nil
+	}
+	var arguments abi.Arguments
+	for _, typeName := range strings.Split(types, ",") {
+		if strings.ContainsAny(typeName, "()") {
+			return nil, fmt.Errorf("tuple types are not supported")
+		}
+		typ, err := abi.NewType(typeName, "", nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid type %q: %w", typeName, err)
+		}
+		arguments = append(arguments, abi.Argument{Type: typ})
+	}
+	return arguments, nil
+}
+
+// PackCall ABI encodes the call of method with args, given as strings in the format of the call command flags
+func PackCall(method abi.Method, args []string) ([]byte, error) {
+	if len(args) != len(method.Inputs) {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", method.Sig, len(method.Inputs), len(args))
+	}
+	values := make([]any, len(args))
+	for i, arg := range args {
+		value, err := parseCallArgument(method.Inputs[i].Type, arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %d of %s: %w", i, method.Sig, err)
+		}
+		values[i] = value
+	}
+
+	data, err := method.Inputs.Pack(values...)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode the arguments of %s: %w", method.Sig, err)
+	}
+	return append(method.ID, data...), nil
+}
+
+// parseCallArgument converts value to the Go type the abi package encodes typ from
+func parseCallArgument(typ abi.Type, value string) (any, error) {
+	switch typ.T {
+	case abi.AddressTy:
+		return SafeParseAddress(value)
+	case abi.BoolTy:
+		return strconv.ParseBool(value)
+	case abi.StringTy:
+		return value, nil
+	case abi.BytesTy:
+		return hexutil.Decode(value)
+	case abi.FixedBytesTy:
+		data, err := hexutil.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != typ.Size {
+			return nil, fmt.Errorf("expected %d bytes, got %d", typ.Size, len(data))
+		}
+		array := reflect.New(typ.GetType()).Elem()
+		reflect.Copy(array, reflect.ValueOf(data))
+		return array.Interface(), nil
+	case abi.UintTy, abi.IntTy:
+		n, ok := new(big.Int).SetString(value, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", value)
+		}
+		if typ.T == abi.UintTy && (n.Sign() < 0 || n.BitLen() > typ.Size) || typ.T == abi.IntTy && n.BitLen() >= typ.Size {
+			return nil, fmt.Errorf("%s does not fit in %s", value, typ)
+		}
+		if typ.Size > 64 {
+			return n, nil
+		}
+		if typ.T == abi.UintTy {
+			return reflect.ValueOf(n.Uint64()).Convert(typ.GetType()).Interface(), nil
+		}
+		return reflect.ValueOf(n.Int64()).Convert(typ.GetType()).Interface(), nil
+	default:
+		return nil, fmt.Errorf("arguments of type %s are not supported, only elementary types are", typ)
+	}
+}
+
+// FormatCallValue formats a value decoded from return data the way the call command prints it: addresses
+// checksummed, integers in decimal and bytes as hex
+func FormatCallValue(value any) string {
+	switch v := value.(type) {
+	case common.Address:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case *big.Int:
+		return v.String()
+	}
+
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8:
+		data := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(data), rv)
+		return hexutil.Encode(data)
+	case rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array:
+		elements := make([]string, rv.Len())
+		for i := range elements {
+			elements[i] = FormatCallValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// ProductionChains are the chain ids of production networks that commands meant for devnets must not run against by
+// accident
+var ProductionChains = map[uint64]string{
+	1:       "Ethereum Mainnet",
+	10:      "OP Mainnet",
+	130:     "Unichain",
+	252:     "Fraxtal",
+	480:     "World Chain",
+	1135:    "Lisk",
+	1868:    "Soneium",
+	8453:    "Base",
+	34443:   "Mode",
+	57073:   "Ink",
+	60808:   "BOB",
+	7777777: "Zora",
+}
+
+var (
+	confirmedChainsMu sync.Mutex
+	confirmedChains   = make(map[uint64]bool)
+)
+
+// ConfirmProductionChain guards against running a command against a production network. Unless --yes is set, the user
+// has to confirm interactively when chainId is a known production chain, and the command is aborted when stdin is
+// not a terminal. Each chain is only confirmed once per run.
+func ConfirmProductionChain(c *cli.Context, chainId *big.Int) error {
+	if !chainId.IsUint64() {
+		return nil
+	}
+	name, ok := ProductionChains[chainId.Uint64()]
+	if !ok {
+		return nil
+	}
+	if c.Bool("yes") {
+		log.Warn("running against a production chain", "chainId", chainId, "chain", name)
+		return nil
+	}
+
+	confirmedChainsMu.Lock()
+	defer confirmedChainsMu.Unlock()
+	if confirmedChains[chainId.Uint64()] {
+		return nil
+	}
+
+	stat, err := os.Stdin.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("chain id %s is %s, a production chain; pass --yes to run against it non-interactively", chainId, name)
+	}
+
+	// The prompt goes to stderr since stdout carries the command output
+	fmt.Fprintf(os.Stderr, "chain id %s is %s, a production chain. Continue? [y/N] ", chainId, name)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("could not read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		confirmedChains[chainId.Uint64()] = true
+		return nil
+	default:
+		return fmt.Errorf("aborted, not running against %s", name)
+	}
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Values of the --time-source flag, the clock the proof maturity and finality delays are compared against
+const (
+	// TimeSourceWall uses the local clock, which on production chains follows the L1 block time closely
+	TimeSourceWall = "wall"
+	// TimeSourceChain uses the timestamp of the latest L1 block, for dev chains whose time is skewed or fast-forwarded
+	// with evm_increaseTime
+	TimeSourceChain = "chain"
+)
+
+// ValidateTimeSource checks the value of the --time-source flag
+func ValidateTimeSource(timeSource string) error {
+	switch timeSource {
+	case TimeSourceWall, TimeSourceChain:
+		return nil
+	default:
+		return fmt.Errorf("unknown time-source %q, expected %s or %s", timeSource, TimeSourceWall, TimeSourceChain)
+	}
+}
+
+// Now returns the current time of timeSource, reading the latest L1 block from l1Client for the chain time
+func Now(ctx context.Context, l1Client *ethclient.Client, timeSource string) (time.Time, error) {
+	if timeSource != TimeSourceChain {
+		return time.Now(), nil
+	}
+	header, err := l1Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not fetch latest L1 header: %w", err)
+	}
+	return time.Unix(int64(header.Time), 0), nil
+}
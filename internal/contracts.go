@@ -25,13 +25,8 @@ type DepositContracts struct {
 	L2StandardBridge        *bindings.L2StandardBridge
 }
 
-func NewDepositContracts(ctx context.Context, l1Client, l2Client *ethclient.Client, optimismPortalAddressHex, l1StandardBridgeAddressHex string) (*DepositContracts, error) {
-
-	optimismPortalAddress, err := SafeParseAddress(optimismPortalAddressHex)
-	if err != nil {
-		return nil, fmt.Errorf("could not parse OptimismPortal address: %w", err)
-	}
-	if _, ok := l1Client.CodeAt(ctx, optimismPortalAddress, nil); ok != nil {
+func NewDepositContracts(ctx context.Context, l1Client, l2Client *ethclient.Client, optimismPortalAddress, l1StandardBridgeAddress common.Address) (*DepositContracts, error) {
+	if _, err := l1Client.CodeAt(ctx, optimismPortalAddress, nil); err != nil {
 		return nil, fmt.Errorf("no code found at OptimismPortal address: %w", err)
 	}
 	optimismPortalABI, err := bindings.L1StandardBridgeMetaData.GetAbi()
@@ -43,11 +38,7 @@ func NewDepositContracts(ctx context.Context, l1Client, l2Client *ethclient.Clie
 		return nil, fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
 	}
 
-	l1StandardBridgeAddress, err := SafeParseAddress(l1StandardBridgeAddressHex)
-	if err != nil {
-		return nil, fmt.Errorf("could not parse L1StandardBridge address: %w", err)
-	}
-	if _, ok := l1Client.CodeAt(ctx, l1StandardBridgeAddress, nil); ok != nil {
+	if _, err := l1Client.CodeAt(ctx, l1StandardBridgeAddress, nil); err != nil {
 		return nil, fmt.Errorf("no code found at l1StandardBridge address: %w", err)
 	}
 	l1StandardBridgeABI, err := bindings.L1StandardBridgeMetaData.GetAbi()
@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum-optimism/optimism/op-e2e/bindings"
+	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/receipts"
+	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/wait"
+	"github.com/ethereum-optimism/optimism/op-node/rollup/derive"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ErrDepositOutOfGas is returned when the L2 deposit transaction, or the message it relays to the recipient, ran out of
+// gas on L2. The deposit has to be sent again with a higher gas limit.
+var ErrDepositOutOfGas = errors.New("deposit ran out of L2 gas; increase --l2-gas-limit")
+
+// DepositTimings records when each stage of a deposit completed, so that a slow L1 inclusion can be told from a slow
+// derivation and L2 inclusion. The L2 stage is only set once the L2 deposit transaction was waited for.
+type DepositTimings struct {
+	L1SentAt  time.Time  `json:"l1SentAt"`
+	L1MinedAt *time.Time `json:"l1MinedAt,omitempty"`
+	L2MinedAt *time.Time `json:"l2MinedAt,omitempty"`
+	// L1Inclusion is the time from sending the L1 transaction to its receipt, L2Inclusion from the L1 receipt to the
+	// receipt of the derived L2 deposit transaction
+	L1Inclusion time.Duration `json:"l1Inclusion,omitempty"`
+	L2Inclusion time.Duration `json:"l2Inclusion,omitempty"`
+}
+
+// NewDepositTimings starts the timings of a deposit whose L1 transaction is about to be sent
+func NewDepositTimings() *DepositTimings {
+	return &DepositTimings{L1SentAt: time.Now()}
+}
+
+// L1Mined records the receipt of the L1 deposit transaction
+func (t *DepositTimings) L1Mined() {
+	now := time.Now()
+	t.L1MinedAt = &now
+	t.L1Inclusion = now.Sub(t.L1SentAt)
+	log.Info("deposit stage completed", "stage", "l1-receipt", "l1Inclusion", t.L1Inclusion)
+}
+
+// L2Mined records the receipt of the derived L2 deposit transaction
+func (t *DepositTimings) L2Mined() {
+	now := time.Now()
+	t.L2MinedAt = &now
+	if t.L1MinedAt != nil {
+		t.L2Inclusion = now.Sub(*t.L1MinedAt)
+	}
+	log.Info("deposit stage completed", "stage", "l2-receipt", "l2Inclusion", t.L2Inclusion, "total", now.Sub(t.L1SentAt))
+}
+
+// DecodedDeposit holds the fields of the L2 deposit transaction decoded from the opaque data of a TransactionDeposited
+// event
+type DecodedDeposit struct {
+	L2TxHash   common.Hash     `json:"l2TxHash"`
+	SourceHash common.Hash     `json:"sourceHash"`
+	From       common.Address  `json:"from"`
+	To         *common.Address `json:"to"`
+	Mint       *big.Int        `json:"mint"`
+	Value      *big.Int        `json:"value"`
+	GasLimit   uint64          `json:"gasLimit"`
+	IsCreation bool            `json:"isCreation"`
+	Data       hexutil.Bytes   `json:"data"`
+}
+
+// DecodeL2Deposit derives the L2 deposit transaction from the TransactionDeposited event in the L1 receipt and returns
+// its hash and decoded fields
+func DecodeL2Deposit(optimismPortal *bindings.OptimismPortal, l1Receipt *types.Receipt) (*DecodedDeposit, error) {
+	transactionDepositedEvent, err := receipts.FindLog(l1Receipt.Logs, optimismPortal.ParseTransactionDeposited)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse OptimismPortal.TransactionDeposited event from the receipt logs: %w", err)
+	}
+
+	log.Info("found TransactionDeposited event in receiptLog", "event", transactionDepositedEvent.Raw)
+
+	// The L2 special deposit transaction can be dervied from the TransactionDeposited logs
+	depositTx, err := derive.UnmarshalDepositLogEvent(&transactionDepositedEvent.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("encountered error deriving the deposit transaction type from the OptimismPortal.TransactionDeposited event: %w", err)
+	}
+
+	deposit := &DecodedDeposit{
+		L2TxHash:   types.NewTx(depositTx).Hash(),
+		SourceHash: depositTx.SourceHash,
+		From:       depositTx.From,
+		To:         depositTx.To,
+		Mint:       depositTx.Mint,
+		Value:      depositTx.Value,
+		GasLimit:   depositTx.Gas,
+		IsCreation: depositTx.To == nil,
+		Data:       depositTx.Data,
+	}
+	// The mint is credited to the sender on L2 before the value is transferred, a mint differing from the value stays
+	// with the sender
+	log.Info("successfully derived the L2 deposit transaction",
+		"l2Tx", deposit.L2TxHash.Hex(),
+		"from", deposit.From,
+		"to", deposit.To,
+		"mint", FormatWei(deposit.Mint),
+		"value", FormatWei(deposit.Value),
+		"gasLimit", deposit.GasLimit,
+		"isCreation", deposit.IsCreation,
+		"data", deposit.Data,
+	)
+
+	return deposit, nil
+}
+
+// WaitForL2Deposit decodes the L2 deposit transaction from the TransactionDeposited event in the L1 receipt and waits
+// for its successful L2 receipt, returning the decoded deposit alongside it
+func WaitForL2Deposit(ctx context.Context, l2Client *ethclient.Client, optimismPortal *bindings.OptimismPortal, l1Receipt *types.Receipt) (*DecodedDeposit, *types.Receipt, error) {
+	deposit, err := DecodeL2Deposit(optimismPortal, l1Receipt)
+	if err != nil {
+		return nil, nil, err
+	}
+	depositTxHash := deposit.L2TxHash
+
+	log.Info("waiting for deposit transaction reciept on L2", "tx", depositTxHash)
+
+	receipt, err := WaitForReceiptOK(ctx, l2Client, depositTxHash)
+	if err != nil {
+		if receipt != nil {
+			logCallTrace(ctx, l2Client, "deposit", depositTxHash)
+		}
+		// A failed deposit that used its whole gas limit ran out of gas rather than reverting
+		if receipt != nil && receipt.GasUsed >= deposit.GasLimit {
+			log.Error("deposit transaction ran out of gas on L2", "tx", depositTxHash.Hex(), "gasLimit", deposit.GasLimit, "gasUsed", receipt.GasUsed)
+			return deposit, nil, fmt.Errorf("%w: used all of its %d gas", ErrDepositOutOfGas, deposit.GasLimit)
+		}
+		if statusErr, ok := err.(*wait.ReceiptStatusError); ok {
+			log.Error("deposit transaction trace", "tx", depositTxHash.Hex(), "trace", statusErr.TxTrace)
+			return deposit, nil, fmt.Errorf("failure in deposit execution: %w", err)
+		}
+		return deposit, nil, fmt.Errorf("found error waiting for deposit receipt: %w", err)
+	}
+
+	// The L2CrossDomainMessenger does not revert when the message of a bridge deposit fails, it records the message
+	// as failed so that it can be replayed, leaving the recipient without the funds
+	if deposit.To != nil && *deposit.To == predeploys.L2CrossDomainMessengerAddr {
+		messenger, err := bindings.NewL2CrossDomainMessengerFilterer(predeploys.L2CrossDomainMessengerAddr, l2Client)
+		if err != nil {
+			return deposit, nil, fmt.Errorf("could not instantiate L2CrossDomainMessenger filterer: %w", err)
+		}
+		if failed, err := receipts.FindLog(receipt.Logs, messenger.ParseFailedRelayedMessage); err == nil {
+			log.Error("L2CrossDomainMessenger failed to relay the deposit, it can be replayed with a higher gas limit", "tx", depositTxHash.Hex(), "msgHash", common.Hash(failed.MsgHash).Hex())
+			return deposit, receipt, fmt.Errorf("%w: the message to the recipient failed to relay", ErrDepositOutOfGas)
+		}
+	}
+
+	log.Info("deposit transaction successfully propogated to L2", "receipt", receipt)
+
+	return deposit, receipt, nil
+}
+
+// estimateGasOverride is the state override of eth_estimateGas funding the sender of the estimated call
+type estimateGasOverride struct {
+	Balance *hexutil.Big `json:"balance"`
+}
+
+// CheckDepositGasLimit estimates the gas recipient takes to receive value and warns when it exceeds gasLimit, the
+// minimum gas limit the bridge deposit message is relayed with, since such a deposit fails to relay on L2. The estimate
+// leaves out the overhead of the L2StandardBridge, so it only catches recipients that could never receive the deposit.
+// An estimate that fails is only logged.
+func CheckDepositGasLimit(ctx context.Context, l2Client *ethclient.Client, recipient common.Address, value *big.Int, gasLimit uint32) {
+	args := map[string]any{
+		"from":  predeploys.L2StandardBridgeAddr,
+		"to":    recipient,
+		"value": (*hexutil.Big)(value),
+	}
+	// The bridge is funded by the mint of the deposit, which the estimate has to be given explicitly
+	overrides := map[common.Address]estimateGasOverride{
+		predeploys.L2StandardBridgeAddr: {Balance: (*hexutil.Big)(value)},
+	}
+
+	var estimate hexutil.Uint64
+	if err := l2Client.Client().CallContext(ctx, &estimate, "eth_estimateGas", args, "latest", overrides); err != nil {
+		log.Warn("could not estimate the L2 gas of the deposit, not checking its gas limit", "recipient", recipient, "error", err)
+		return
+	}
+	// The bridge calls the recipient from within the relayed message, which pays no intrinsic gas
+	executionGas := uint64(estimate) - min(uint64(estimate), params.TxGas)
+	if executionGas > uint64(gasLimit) {
+		log.Warn("deposit L2 gas limit looks insufficient, the deposit will likely fail to relay on L2; increase --l2-gas-limit", "l2GasLimit", gasLimit, "estimate", executionGas, "recipient", recipient)
+		return
+	}
+	log.Debug("deposit L2 gas limit covers the estimate", "l2GasLimit", gasLimit, "estimate", executionGas)
+}
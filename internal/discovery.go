@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum-optimism/optimism/op-e2e/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// L1Addresses are the L1 contracts of a chain as registered in its SystemConfig
+type L1Addresses struct {
+	SystemConfig       common.Address
+	OptimismPortal     common.Address
+	DisputeGameFactory common.Address
+	L1StandardBridge   common.Address
+}
+
+var (
+	discoveredMu sync.Mutex
+	discovered   *L1Addresses
+)
+
+// L1ContractAddress returns the address passed with the contract address flag, checked with CheckProxy, or with
+// --autodiscover the one registered in the SystemConfig when the flag is omitted
+func L1ContractAddress(ctx context.Context, c *cli.Context, l1Client *ethclient.Client, flag string) (common.Address, error) {
+	if c.String(flag) != "" {
+		address, err := SafeParseAddress(c.String(flag))
+		if err != nil {
+			return ZeroAddress, err
+		}
+		CheckProxy(ctx, l1Client, flag, address)
+		return address, nil
+	}
+	if !c.Bool("autodiscover") {
+		return ZeroAddress, fmt.Errorf("%s is required unless --autodiscover is set", flag)
+	}
+
+	addresses, err := DiscoverL1Addresses(ctx, c, l1Client)
+	if err != nil {
+		return ZeroAddress, err
+	}
+
+	switch flag {
+	case "optimism-portal-address":
+		return addresses.OptimismPortal, nil
+	case "dispute-game-factory-address":
+		return addresses.DisputeGameFactory, nil
+	case "l1-standard-bridge-address":
+		return addresses.L1StandardBridge, nil
+	default:
+		return ZeroAddress, fmt.Errorf("%s cannot be discovered from the SystemConfig", flag)
+	}
+}
+
+// DiscoverL1Addresses reads the L1 contract addresses from the SystemConfig passed with --system-config-address, or
+// from the one the portal passed with --optimism-portal-address points to. The L2 predeploys do not expose the
+// SystemConfig address, so one of the two is needed. The result is kept for the rest of the run.
+func DiscoverL1Addresses(ctx context.Context, c *cli.Context, l1Client *ethclient.Client) (*L1Addresses, error) {
+	discoveredMu.Lock()
+	defer discoveredMu.Unlock()
+	if discovered != nil {
+		return discovered, nil
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+	opts := &bind.CallOpts{Context: readCtx}
+
+	var systemConfigAddress common.Address
+	switch {
+	case c.String("system-config-address") != "":
+		address, err := SafeParseAddress(c.String("system-config-address"))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse SystemConfig address: %w", err)
+		}
+		systemConfigAddress = address
+	case c.String("optimism-portal-address") != "":
+		portalAddress, err := SafeParseAddress(c.String("optimism-portal-address"))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse OptimismPortal address: %w", err)
+		}
+		portal, err := bindingspreview.NewOptimismPortal2Caller(portalAddress, l1Client)
+		if err != nil {
+			return nil, fmt.Errorf("could not instantiate OptimismPortal contract: %w", err)
+		}
+		systemConfigAddress, err = portal.SystemConfig(opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch OptimismPortal.systemConfig: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("autodiscover needs either --system-config-address or --optimism-portal-address")
+	}
+
+	systemConfig, err := bindings.NewSystemConfigCaller(systemConfigAddress, l1Client)
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate SystemConfig contract: %w", err)
+	}
+
+	addresses := &L1Addresses{SystemConfig: systemConfigAddress}
+	if addresses.OptimismPortal, err = systemConfig.OptimismPortal(opts); err != nil {
+		return nil, fmt.Errorf("could not fetch SystemConfig.optimismPortal: %w", err)
+	}
+	if addresses.DisputeGameFactory, err = systemConfig.DisputeGameFactory(opts); err != nil {
+		return nil, fmt.Errorf("could not fetch SystemConfig.disputeGameFactory: %w", err)
+	}
+	if addresses.L1StandardBridge, err = systemConfig.L1StandardBridge(opts); err != nil {
+		return nil, fmt.Errorf("could not fetch SystemConfig.l1StandardBridge: %w", err)
+	}
+
+	log.Info("discovered L1 contract addresses",
+		"systemConfig", addresses.SystemConfig,
+		"optimismPortal", addresses.OptimismPortal,
+		"disputeGameFactory", addresses.DisputeGameFactory,
+		"l1StandardBridge", addresses.L1StandardBridge,
+	)
+
+	discovered = addresses
+	return addresses, nil
+}
@@ -0,0 +1,34 @@
+package internal
+
+import "errors"
+
+// Errors returned by the withdraw commands for the states a withdrawal can be in that stop it from progressing, so
+// that callers can tell the ones to wait out from permanent failures with errors.Is
+var (
+	ErrNoGames            = errors.New("no dispute games have been proposed yet")
+	ErrGameNotProposed    = errors.New("no dispute game covering the withdrawal has been proposed yet")
+	ErrOutputNotProposed  = errors.New("no L2 output covering the withdrawal has been proposed yet")
+	ErrNotProven          = errors.New("withdrawal has not been proven")
+	ErrGameNotResolved    = errors.New("dispute game has not been resolved")
+	ErrProofNotMatured    = errors.New("withdrawal proof has not matured")
+	ErrFinalityNotElapsed = errors.New("dispute game finality delay has not elapsed")
+	ErrAlreadyFinalized   = errors.New("withdrawal has already been finalized")
+	ErrL1NotFinalized     = errors.New("L1 block of the dispute game proposal is not finalized yet")
+	// ErrGameChallenged is permanent, the proof has to be made again against another game
+	ErrGameChallenged = errors.New("game resolved against the proposer; re-prove required")
+	// ErrOutputRootMismatch means the L2 node computes another output root than the game claims, so proofs built from
+	// it revert
+	ErrOutputRootMismatch = errors.New("output root computed from the L2 node does not match the root claim of the game")
+)
+
+// IsRetryable reports whether err only means that the withdrawal is not ready yet, so that the operation succeeds
+// when retried later
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrNoGames) ||
+		errors.Is(err, ErrGameNotProposed) ||
+		errors.Is(err, ErrOutputNotProposed) ||
+		errors.Is(err, ErrGameNotResolved) ||
+		errors.Is(err, ErrProofNotMatured) ||
+		errors.Is(err, ErrFinalityNotElapsed) ||
+		errors.Is(err, ErrL1NotFinalized)
+}
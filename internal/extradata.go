@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Game types of the DisputeGameFactory, as defined by the GameTypes library of the contracts
+const (
+	GameTypeCannon       uint32 = 0
+	GameTypePermissioned uint32 = 1
+	GameTypeAsterisc     uint32 = 2
+	GameTypeAsteriscKona uint32 = 3
+	GameTypeSuperCannon  uint32 = 4
+	GameTypeSuperPerm    uint32 = 5
+	GameTypeOPSuccinct   uint32 = 6
+	GameTypeFast         uint32 = 254
+	GameTypeAlphabet     uint32 = 255
+)
+
+// SearchResultGameType returns the game type packed into the GameId metadata of a game search result
+func SearchResultGameType(game *opNodeBindings.IDisputeGameFactoryGameSearchResult) uint32 {
+	return binary.BigEndian.Uint32(game.Metadata[0:4])
+}
+
+// SearchResultGameProxy returns the game proxy address packed into the GameId metadata of a game search result
+func SearchResultGameProxy(game *opNodeBindings.IDisputeGameFactoryGameSearchResult) common.Address {
+	return common.BytesToAddress(game.Metadata[12:32])
+}
+
+// GameL2BlockNumber decodes the L2 block number a game proposes an output root for from its extra data, whose layout
+// depends on the game type:
+//   - the output root games (cannon, permissioned, asterisc, fast and alphabet) hold only the 32 byte block number
+//   - OP Succinct appends the 4 byte index of the parent game to the block number
+//
+// Super root games commit to an L2 timestamp instead and, like any other layout, are reported as an error rather
+// than read as a block number.
+func GameL2BlockNumber(gameType uint32, extraData []byte) (*big.Int, error) {
+	var length int
+	switch gameType {
+	case GameTypeCannon, GameTypePermissioned, GameTypeAsterisc, GameTypeAsteriscKona, GameTypeFast, GameTypeAlphabet:
+		length = 32
+	case GameTypeOPSuccinct:
+		length = 36
+	case GameTypeSuperCannon, GameTypeSuperPerm:
+		return nil, fmt.Errorf("game type %d proposes super roots for an L2 timestamp, withdrawals can only be proven against output root games", gameType)
+	default:
+		return nil, fmt.Errorf("extra data layout of game type %d is unknown", gameType)
+	}
+
+	if len(extraData) != length {
+		return nil, fmt.Errorf("extra data of game type %d is %d bytes, expected %d", gameType, len(extraData), length)
+	}
+	return new(big.Int).SetBytes(extraData[0:32]), nil
+}
+
+// SearchResultL2BlockNumber decodes the L2 block number of a game search result with GameL2BlockNumber
+func SearchResultL2BlockNumber(game *opNodeBindings.IDisputeGameFactoryGameSearchResult) (*big.Int, error) {
+	l2BlockNumber, err := GameL2BlockNumber(SearchResultGameType(game), game.ExtraData)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the L2 block number of game %d: %w", game.Index, err)
+	}
+	return l2BlockNumber, nil
+}
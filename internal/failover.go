@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SplitRPCUrls splits an rpc url flag holding a comma separated list of urls to fail over between
+func SplitRPCUrls(rpcUrl string) []string {
+	var urls []string
+	for _, u := range strings.Split(rpcUrl, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// failoverTransport sends every request to the active endpoint and moves on to the next one, in order, when the
+// active endpoint fails with a connection error or reports itself unavailable. The endpoint that answered stays
+// active for the following requests, so a command keeps running on it until it fails in turn. Each attempt is bounded
+// by timeout, so that a hanging endpoint is failed over as well.
+type failoverTransport struct {
+	base      http.RoundTripper
+	endpoints []*url.URL
+	timeout   time.Duration
+
+	mu     sync.Mutex
+	active int
+}
+
+// newFailoverTransport returns a transport failing over between the http(s) urls, websocket urls cannot be rewritten
+// per request and are rejected
+func newFailoverTransport(base http.RoundTripper, urls []string, timeout time.Duration) (*failoverTransport, error) {
+	endpoints := make([]*url.URL, 0, len(urls))
+	for _, rawUrl := range urls {
+		endpoint, err := url.Parse(rawUrl)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse rpc url %s: %w", rawUrl, err)
+		}
+		if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+			return nil, fmt.Errorf("rpc url %s cannot be failed over to, only http and https urls are supported with several urls", rawUrl)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return &failoverTransport{base: base, endpoints: endpoints, timeout: timeout}, nil
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	start := t.active
+	t.mu.Unlock()
+
+	var errs []error
+	for i := range t.endpoints {
+		index := (start + i) % len(t.endpoints)
+		endpoint := t.endpoints[index]
+
+		var attemptCtx context.Context
+		var cancel context.CancelFunc
+		if t.timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(req.Context(), t.timeout)
+		} else {
+			attemptCtx, cancel = context.WithCancel(req.Context())
+		}
+		attempt := req.Clone(attemptCtx)
+		attempt.URL = endpoint
+		attempt.Host = endpoint.Host
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			attempt.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attempt)
+		if err == nil && !isUnavailableStatus(resp.StatusCode) {
+			t.setActive(index)
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("%s", resp.Status)
+		}
+		cancel()
+		// A cancelled request fails the same way on every endpoint, so it is not failed over
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint.Redacted(), err))
+
+		if i < len(t.endpoints)-1 {
+			next := t.endpoints[(index+1)%len(t.endpoints)]
+			log.Warn("rpc endpoint failed, failing over", "url", endpoint.Redacted(), "next", next.Redacted(), "error", err)
+		}
+	}
+
+	return nil, fmt.Errorf("all rpc endpoints failed: %w", errors.Join(errs...))
+}
+
+func (t *failoverTransport) setActive(index int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active = index
+}
+
+// isUnavailableStatus reports whether an http status means the endpoint itself is down rather than the request bad
+func isUnavailableStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// cancelOnClose releases the per attempt timeout of a response once its body has been read
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Golem-Base/op-probe/bindings"
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// CheckRespectedGameType compares gameType with the respected game type of the portal, which proofs have to be made
+// against for the portal to honour them. A mismatch is an error when the game type was requested explicitly, and
+// only logged when it was assumed.
+func CheckRespectedGameType(opts *bind.CallOpts, optimismPortal *bindingspreview.OptimismPortal2Caller, gameType uint32, explicit bool) error {
+	respectedGameType, err := optimismPortal.RespectedGameType(opts)
+	if err != nil {
+		return fmt.Errorf("could not fetch OptimismPortal.RespectedGameType: %w", err)
+	}
+	if respectedGameType == gameType {
+		return nil
+	}
+	if explicit {
+		return fmt.Errorf("game type %d is not the respected game type %d of the portal, withdrawals proven against it will not be honoured", gameType, respectedGameType)
+	}
+	log.Warn("the portal respects a different game type than assumed, withdrawals proven against the assumed game type will not be honoured", "gameType", gameType, "respectedGameType", respectedGameType)
+	return nil
+}
+
+// GameStatus mirrors the GameStatus enum of the dispute game contracts
+type GameStatus uint8
+
+const (
+	GameStatusInProgress GameStatus = iota
+	GameStatusChallengerWins
+	GameStatusDefenderWins
+)
+
+func (s GameStatus) String() string {
+	switch s {
+	case GameStatusInProgress:
+		return "IN_PROGRESS"
+	case GameStatusChallengerWins:
+		return "CHALLENGER_WINS"
+	case GameStatusDefenderWins:
+		return "DEFENDER_WINS"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", uint8(s))
+	}
+}
+
+// InvalidGameReason reports why the portal would no longer honour proofs made against the given dispute game.
+// An empty reason means the game is still valid.
+func InvalidGameReason(opts *bind.CallOpts, optimismPortal *bindingspreview.OptimismPortal2, gameProxy common.Address, game *bindings.PermissionedDisputeGame) (string, error) {
+	blacklisted, err := optimismPortal.DisputeGameBlacklist(opts, gameProxy)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch OptimismPortal.DisputeGameBlacklist: %w", err)
+	}
+	if blacklisted {
+		return "the dispute game has been blacklisted by the portal", nil
+	}
+
+	status, err := game.Status(opts)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch PermissionedDisputeGame.Status: %w", err)
+	}
+	if GameStatus(status) == GameStatusChallengerWins {
+		return "the dispute game resolved as CHALLENGER_WINS", nil
+	}
+
+	respectedGameType, err := optimismPortal.RespectedGameType(opts)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch OptimismPortal.RespectedGameType: %w", err)
+	}
+	gameType, err := game.GameType(opts)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch PermissionedDisputeGame.GameType: %w", err)
+	}
+	if gameType != respectedGameType {
+		return fmt.Sprintf("the dispute game type %d is not the respected game type %d", gameType, respectedGameType), nil
+	}
+
+	respectedGameTypeUpdatedAt, err := optimismPortal.RespectedGameTypeUpdatedAt(opts)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch OptimismPortal.RespectedGameTypeUpdatedAt: %w", err)
+	}
+	createdAt, err := game.CreatedAt(opts)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch PermissionedDisputeGame.CreatedAt: %w", err)
+	}
+	if createdAt < respectedGameTypeUpdatedAt {
+		return "the dispute game was created before the respected game type was last updated", nil
+	}
+
+	return "", nil
+}
+
+// CheckGameNotChallenged reads the status of the resolved game at gameProxy and fails with ErrGameChallenged when the
+// challenger won it. Such a game invalidates every proof made against it, so waiting or retrying never finalizes them.
+func CheckGameNotChallenged(opts *bind.CallOpts, gameProxy common.Address, game *bindings.PermissionedDisputeGame) (GameStatus, error) {
+	status, err := game.Status(opts)
+	if err != nil {
+		return 0, fmt.Errorf("could not fetch PermissionedDisputeGame.Status(): %w", err)
+	}
+	if GameStatus(status) == GameStatusChallengerWins {
+		return GameStatus(status), fmt.Errorf("%w, game %s resolved as %s", ErrGameChallenged, gameProxy.Hex(), GameStatus(status))
+	}
+	return GameStatus(status), nil
+}
+
+// FindLatestGame returns the latest game of the respected game type like withdrawals.FindLatestGame, but reads
+// through opts so the lookup can be pinned to a block. ErrNoGames is returned while the factory holds no game of the
+// respected game type, such as right after it was deployed.
+func FindLatestGame(opts *bind.CallOpts, disputeGameFactory *opNodeBindings.DisputeGameFactoryCaller, optimismPortal *bindingspreview.OptimismPortal2Caller) (*opNodeBindings.IDisputeGameFactoryGameSearchResult, error) {
+	respectedGameType, err := optimismPortal.RespectedGameType(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get respected game type: %w", err)
+	}
+
+	gameCount, err := disputeGameFactory.GameCount(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game count: %w", err)
+	}
+	if gameCount.Sign() == 0 {
+		return nil, ErrNoGames
+	}
+
+	searchStart := new(big.Int).Sub(gameCount, common.Big1)
+	latestGames, err := disputeGameFactory.FindLatestGames(opts, respectedGameType, searchStart, common.Big1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest games: %w", err)
+	}
+	if len(latestGames) == 0 {
+		return nil, fmt.Errorf("%w of the respected game type %d", ErrNoGames, respectedGameType)
+	}
+
+	return &latestGames[0], nil
+}
+
+// FindLatestGameRetries is how often FindLatestGameCovering looks up the latest game before concluding that no game
+// covers the L2 block yet
+const FindLatestGameRetries = 3
+
+// FindLatestGameRetryDelay is the delay between the lookups of FindLatestGameCovering
+const FindLatestGameRetryDelay = 2 * time.Second
+
+// FindLatestGameCovering returns the latest game like FindLatestGame, retrying a few times while the game is below
+// l2Block since a lookup right after a proposal can still return the previous game. The last game found is returned
+// even when it does not cover l2Block, leaving the caller to report it. Lookups pinned to a block are not retried.
+func FindLatestGameCovering(opts *bind.CallOpts, disputeGameFactory *opNodeBindings.DisputeGameFactoryCaller, optimismPortal *bindingspreview.OptimismPortal2Caller, l2Block uint64) (*opNodeBindings.IDisputeGameFactoryGameSearchResult, error) {
+	var game *opNodeBindings.IDisputeGameFactoryGameSearchResult
+	attempt := 0
+	err := PollUntil(opts.Context, FindLatestGameRetryDelay, FindLatestGameRetryDelay, func() (bool, error) {
+		attempt++
+		latest, err := FindLatestGame(opts, disputeGameFactory, optimismPortal)
+		if err != nil {
+			return false, err
+		}
+		game = latest
+
+		gameL2BlockNumber, err := SearchResultL2BlockNumber(game)
+		if err != nil {
+			return false, err
+		}
+		if gameL2BlockNumber.Uint64() >= l2Block || attempt >= FindLatestGameRetries || opts.BlockNumber != nil {
+			return true, nil
+		}
+
+		log.Info("latest game does not cover the L2 block yet, retrying...", "game", game.Index, "gameL2Block", gameL2BlockNumber, "l2Block", l2Block, "attempt", attempt)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// FindGamesBatchSize is how many games FindGame looks up per call to DisputeGameFactory.FindLatestGames
+const FindGamesBatchSize = 100
+
+// FindGame returns the latest game of the respected game type proposed at l2Block and claiming outputRoot, skipping
+// either check when it is nil, searching the factory back from its latest game
+func FindGame(opts *bind.CallOpts, disputeGameFactory *opNodeBindings.DisputeGameFactoryCaller, optimismPortal *bindingspreview.OptimismPortal2Caller, l2Block *big.Int, outputRoot *common.Hash) (*opNodeBindings.IDisputeGameFactoryGameSearchResult, error) {
+	respectedGameType, err := optimismPortal.RespectedGameType(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get respected game type: %w", err)
+	}
+
+	gameCount, err := disputeGameFactory.GameCount(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game count: %w", err)
+	}
+
+	searchStart := new(big.Int).Sub(gameCount, common.Big1)
+	for searchStart.Sign() >= 0 {
+		games, err := disputeGameFactory.FindLatestGames(opts, respectedGameType, searchStart, big.NewInt(FindGamesBatchSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest games: %w", err)
+		}
+
+		for i := range games {
+			game := &games[i]
+			if outputRoot != nil && common.Hash(game.RootClaim) != *outputRoot {
+				continue
+			}
+			if l2Block != nil {
+				gameL2BlockNumber, err := SearchResultL2BlockNumber(game)
+				if err != nil {
+					return nil, err
+				}
+				if gameL2BlockNumber.Cmp(l2Block) != 0 {
+					continue
+				}
+			}
+			return game, nil
+		}
+
+		if len(games) < FindGamesBatchSize {
+			break
+		}
+		searchStart = new(big.Int).Sub(games[len(games)-1].Index, common.Big1)
+	}
+
+	switch {
+	case l2Block != nil && outputRoot != nil:
+		return nil, fmt.Errorf("no dispute game of the respected game type %d claims output root %s at L2 block %d", respectedGameType, outputRoot.Hex(), l2Block)
+	case outputRoot != nil:
+		return nil, fmt.Errorf("no dispute game of the respected game type %d claims output root %s", respectedGameType, outputRoot.Hex())
+	default:
+		return nil, fmt.Errorf("no dispute game of the respected game type %d was proposed at L2 block %d", respectedGameType, l2Block)
+	}
+}
+
+// CheckGameL1Finalized checks that the L1 block game was created in is finalized, so that an L1 reorg cannot drop the
+// game and the proofs made against it. The creation block is finalized once the finalized L1 head is at least as
+// recent as the creation timestamp of the game.
+func CheckGameL1Finalized(ctx context.Context, l1Client *ethclient.Client, game *opNodeBindings.IDisputeGameFactoryGameSearchResult) error {
+	finalized, err := l1Client.HeaderByNumber(ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
+	if err != nil {
+		return fmt.Errorf("could not fetch the finalized L1 block: %w", err)
+	}
+	if finalized.Time < game.Timestamp {
+		return fmt.Errorf("%w, game %d was created at %s and the finalized L1 block %d at %s", ErrL1NotFinalized, game.Index, time.Unix(int64(game.Timestamp), 0), finalized.Number, time.Unix(int64(finalized.Time), 0))
+	}
+	return nil
+}
+
+// GameAtIndex returns the game at index of the dispute game factory in the form returned by FindLatestGame, reading
+// its extra data and root claim from the game itself
+func GameAtIndex(opts *bind.CallOpts, disputeGameFactory *opNodeBindings.DisputeGameFactoryCaller, backend bind.ContractBackend, index *big.Int) (*opNodeBindings.IDisputeGameFactoryGameSearchResult, error) {
+	gameCount, err := disputeGameFactory.GameCount(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game count: %w", err)
+	}
+	if index.Cmp(gameCount) >= 0 {
+		return nil, fmt.Errorf("game index %d is out of range, the factory has %d games", index, gameCount)
+	}
+
+	gameAtIndex, err := disputeGameFactory.GameAtIndex(opts, index)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch DisputeGameFactory.GameAtIndex: %w", err)
+	}
+	game, err := bindings.NewPermissionedDisputeGame(gameAtIndex.Proxy, backend)
+	if err != nil {
+		return nil, fmt.Errorf("could not construct permissioned dispute game")
+	}
+	extraData, err := game.ExtraData(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch PermissionedDisputeGame.ExtraData: %w", err)
+	}
+	rootClaim, err := game.RootClaim(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch PermissionedDisputeGame.RootClaim: %w", err)
+	}
+
+	// The metadata is packed like the GameId of the factory, so the game type can be read back from it
+	var metadata [32]byte
+	binary.BigEndian.PutUint32(metadata[0:4], gameAtIndex.GameType)
+	binary.BigEndian.PutUint64(metadata[4:12], gameAtIndex.Timestamp)
+	copy(metadata[12:32], gameAtIndex.Proxy[:])
+
+	return &opNodeBindings.IDisputeGameFactoryGameSearchResult{
+		Index:     index,
+		Metadata:  metadata,
+		Timestamp: gameAtIndex.Timestamp,
+		RootClaim: rootClaim,
+		ExtraData: extraData,
+	}, nil
+}
+
+// ProveWithdrawalParametersForGame generates the withdrawal proof against the given game like
+// withdrawals.ProveWithdrawalParametersFaultProofs does against the latest game, using the given proof variant
+func ProveWithdrawalParametersForGame(ctx context.Context, variant string, proofCl withdrawals.ProofClient, l2ReceiptCl withdrawals.ReceiptClient, l2HeaderCl withdrawals.HeaderClient, txHash common.Hash, game *opNodeBindings.IDisputeGameFactoryGameSearchResult) (withdrawals.ProvenWithdrawalParameters, error) {
+	l2BlockNumber, err := SearchResultL2BlockNumber(game)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, err
+	}
+	return ProveWithdrawalParametersAtBlock(ctx, variant, proofCl, l2ReceiptCl, l2HeaderCl, txHash, l2BlockNumber, game)
+}
+
+// ProveWithdrawalParametersAtBlock generates the withdrawal proof for game at l2BlockNumber instead of the L2 block of
+// the game, for reproducing proofs at other states. The portal only accepts it when the output root of the proof is
+// the root claim of the game, see OutputRootOfProof.
+func ProveWithdrawalParametersAtBlock(ctx context.Context, variant string, proofCl withdrawals.ProofClient, l2ReceiptCl withdrawals.ReceiptClient, l2HeaderCl withdrawals.HeaderClient, txHash common.Hash, l2BlockNumber *big.Int, game *opNodeBindings.IDisputeGameFactoryGameSearchResult) (withdrawals.ProvenWithdrawalParameters, error) {
+	l2Header, err := l2HeaderCl.HeaderByNumber(ctx, l2BlockNumber)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("failed to get l2Block: %w", err)
+	}
+
+	return proveWithdrawalParametersForBlock(ctx, variant, proofCl, l2ReceiptCl, txHash, l2Header, game.Index)
+}
+
+// proveWithdrawalParametersForBlock generates the withdrawal proof against the output of l2Header at l2OutputIndex,
+// the index of the dispute game or of the L2OutputOracle output, using the given proof variant
+func proveWithdrawalParametersForBlock(ctx context.Context, variant string, proofCl withdrawals.ProofClient, l2ReceiptCl withdrawals.ReceiptClient, txHash common.Hash, l2Header *types.Header, l2OutputIndex *big.Int) (withdrawals.ProvenWithdrawalParameters, error) {
+	switch variant {
+	case ProofVariantFaultProofs:
+		return withdrawals.ProveWithdrawalParametersForBlock(ctx, proofCl, l2ReceiptCl, txHash, l2Header, l2OutputIndex)
+	case ProofVariantWithdrawalsRoot:
+		return proveWithdrawalParametersWithdrawalsRoot(ctx, proofCl, l2ReceiptCl, txHash, l2Header, l2OutputIndex)
+	default:
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("unknown proof variant %q", variant)
+	}
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	stubFactoryAddress = common.HexToAddress("0x00000000000000000000000000000000000f0001")
+	stubPortalAddress  = common.HexToAddress("0x00000000000000000000000000000000000f0002")
+)
+
+// stubGameCaller answers the DisputeGameFactory and OptimismPortal2 reads of FindLatestGame for a factory holding
+// gameCount games, none of which has the respected game type
+type stubGameCaller struct {
+	gameCount         int64
+	respectedGameType uint32
+}
+
+func (s *stubGameCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{0x00}, nil
+}
+
+func (s *stubGameCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var metaData *bind.MetaData
+	switch *call.To {
+	case stubFactoryAddress:
+		metaData = opNodeBindings.DisputeGameFactoryMetaData
+	case stubPortalAddress:
+		metaData = bindingspreview.OptimismPortal2MetaData
+	default:
+		return nil, fmt.Errorf("unexpected call to %s", call.To.Hex())
+	}
+	contractABI, err := metaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	method, err := contractABI.MethodById(call.Data[:4])
+	if err != nil {
+		return nil, err
+	}
+
+	switch method.RawName {
+	case "respectedGameType":
+		return method.Outputs.Pack(s.respectedGameType)
+	case "gameCount":
+		return method.Outputs.Pack(big.NewInt(s.gameCount))
+	case "findLatestGames":
+		return method.Outputs.Pack([]opNodeBindings.IDisputeGameFactoryGameSearchResult{})
+	}
+	return nil, fmt.Errorf("unexpected call to %s", method.Sig)
+}
+
+// findLatestGameWith runs FindLatestGame against caller
+func findLatestGameWith(t *testing.T, caller bind.ContractCaller) error {
+	t.Helper()
+	factory, err := opNodeBindings.NewDisputeGameFactoryCaller(stubFactoryAddress, caller)
+	if err != nil {
+		t.Fatalf("could not instantiate DisputeGameFactory caller: %v", err)
+	}
+	portal, err := bindingspreview.NewOptimismPortal2Caller(stubPortalAddress, caller)
+	if err != nil {
+		t.Fatalf("could not instantiate OptimismPortal2 caller: %v", err)
+	}
+	game, err := FindLatestGame(&bind.CallOpts{Context: context.Background()}, factory, portal)
+	if err == nil {
+		t.Fatalf("FindLatestGame returned game %d", game.Index)
+	}
+	return err
+}
+
+func TestFindLatestGameEmptyFactory(t *testing.T) {
+	err := findLatestGameWith(t, &stubGameCaller{gameCount: 0, respectedGameType: GameTypePermissioned})
+	if !errors.Is(err, ErrNoGames) {
+		t.Errorf("expected ErrNoGames, got %v", err)
+	}
+}
+
+func TestFindLatestGameNoRespectedGameType(t *testing.T) {
+	err := findLatestGameWith(t, &stubGameCaller{gameCount: 3, respectedGameType: GameTypePermissioned})
+	if !errors.Is(err, ErrNoGames) {
+		t.Errorf("expected ErrNoGames, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// impersonationMethods are the dev node RPC methods that let eth_sendTransaction send from any account
+var impersonationMethods = []string{"anvil_impersonateAccount", "hardhat_impersonateAccount"}
+
+// impersonatingKey marks the context of the transactors created by NewImpersonatingTransactor
+type impersonatingKey struct{}
+
+// NewImpersonatingTransactor creates a transactor sending as from without its key, for dev nodes that support account
+// impersonation or hold from as an unlocked account. Its transactions are built with NoSend and a no-op signer, and
+// sent unsigned with eth_sendTransaction by SendTransaction and the value transfer helpers. The transactor is told
+// apart from the --build-only one, also built with NoSend, by a value of its context, which copies of it keep.
+func NewImpersonatingTransactor(ctx context.Context, c *cli.Context, client *ethclient.Client, from common.Address) (*bind.TransactOpts, error) {
+	if err := impersonate(ctx, client, from); err != nil {
+		return nil, err
+	}
+
+	opts := &bind.TransactOpts{
+		From: from,
+		Signer: func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			return tx, nil
+		},
+		Context: context.WithValue(ctx, impersonatingKey{}, true),
+		NoSend:  true,
+	}
+
+	if err := setNonceOverride(ctx, c, client, opts); err != nil {
+		return nil, err
+	}
+	if err := setTxTypeOverride(ctx, c, client, opts); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// impersonate asks the node to accept eth_sendTransaction from account, succeeding without impersonation when the
+// node already holds account unlocked, as geth dev nodes do for their developer account
+func impersonate(ctx context.Context, client *ethclient.Client, account common.Address) error {
+	readCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+
+	for _, method := range impersonationMethods {
+		err := client.Client().CallContext(readCtx, nil, method, account)
+		if err == nil {
+			log.Info("impersonating account", "account", account, "method", method)
+			return nil
+		}
+		log.Debug("account impersonation not available", "method", method, "error", err)
+	}
+
+	var accounts []common.Address
+	if err := client.Client().CallContext(readCtx, &accounts, "eth_accounts"); err == nil {
+		for _, unlocked := range accounts {
+			if unlocked == account {
+				log.Info("sending from unlocked node account", "account", account)
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("rpc does not support impersonating %s: neither %v succeeded and it is not an unlocked node account", account.Hex(), impersonationMethods)
+}
+
+// sendUnsigned sends tx, built by an impersonating transactor, with eth_sendTransaction and returns the transaction as
+// signed by the node
+func sendUnsigned(ctx context.Context, client *ethclient.Client, from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	args := map[string]any{
+		"from":  from,
+		"to":    tx.To(),
+		"gas":   hexutil.Uint64(tx.Gas()),
+		"value": (*hexutil.Big)(tx.Value()),
+		"input": hexutil.Bytes(tx.Data()),
+		"nonce": hexutil.Uint64(tx.Nonce()),
+	}
+	if tx.Type() == types.LegacyTxType {
+		args["gasPrice"] = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		args["maxFeePerGas"] = (*hexutil.Big)(tx.GasFeeCap())
+		args["maxPriorityFeePerGas"] = (*hexutil.Big)(tx.GasTipCap())
+	}
+
+	var hash common.Hash
+	if err := client.Client().CallContext(ctx, &hash, "eth_sendTransaction", args); err != nil {
+		return nil, fmt.Errorf("eth_sendTransaction failed: %w", err)
+	}
+
+	sent, _, err := client.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch sent transaction %s: %w", hash.Hex(), err)
+	}
+	return sent, nil
+}
+
+// isImpersonating reports whether opts was created by NewImpersonatingTransactor
+func isImpersonating(opts *bind.TransactOpts) bool {
+	return opts.Context != nil && opts.Context.Value(impersonatingKey{}) != nil
+}
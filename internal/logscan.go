@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// LogScanBlockRange is the number of blocks ScanLogs filters per request, below the eth_getLogs range limits of
+// common providers
+const LogScanBlockRange = 10_000
+
+// ScanLogs calls scan for consecutive ranges of at most LogScanBlockRange blocks, from the block from up to the head
+// of client when the scan starts, until scan reports done. Each range is filtered under its own CallTimeout deadline,
+// so that scanning a long chain is not bounded by a single read.
+func ScanLogs(ctx context.Context, client *ethclient.Client, from uint64, scan func(opts *bind.FilterOpts) (bool, error)) error {
+	headCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	head, err := client.BlockNumber(headCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("could not fetch block number: %w", err)
+	}
+
+	for start := from; start <= head; start += LogScanBlockRange {
+		end := min(start+LogScanBlockRange-1, head)
+		done, err := scanRange(ctx, start, end, scan)
+		if err != nil {
+			return fmt.Errorf("could not scan blocks %d to %d: %w", start, end, err)
+		}
+		if done {
+			return nil
+		}
+	}
+	return nil
+}
+
+func scanRange(ctx context.Context, start, end uint64, scan func(opts *bind.FilterOpts) (bool, error)) (bool, error) {
+	readCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+	return scan(&bind.FilterOpts{Context: readCtx, Start: start, End: &end})
+}
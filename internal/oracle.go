@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// UseL2OutputOracle reports whether the command was given --l2-output-oracle-address, selecting the legacy
+// OptimismPortal proving against the L2OutputOracle of chains without fault proofs over DisputeGameFactory and
+// OptimismPortal2
+func UseL2OutputOracle(c *cli.Context) (bool, error) {
+	if !c.IsSet("l2-output-oracle-address") {
+		return false, nil
+	}
+	if c.IsSet("dispute-game-factory-address") {
+		return false, fmt.Errorf("dispute-game-factory-address and l2-output-oracle-address cannot be combined, the first selects fault proofs and the second the legacy L2OutputOracle")
+	}
+	return true, nil
+}
+
+// ProveWithdrawalParametersForOutput generates the withdrawal proof against the first L2OutputOracle output covering
+// withdrawalBlock, using the given proof variant. ErrOutputNotProposed is returned until such an output is proposed.
+func ProveWithdrawalParametersForOutput(ctx context.Context, opts *bind.CallOpts, variant string, proofCl withdrawals.ProofClient, l2ReceiptCl withdrawals.ReceiptClient, l2HeaderCl withdrawals.HeaderClient, txHash common.Hash, oracle *opNodeBindings.L2OutputOracleCaller, withdrawalBlock uint64) (withdrawals.ProvenWithdrawalParameters, error) {
+	latestBlockNumber, err := oracle.LatestBlockNumber(opts)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("could not fetch L2OutputOracle.LatestBlockNumber: %w", err)
+	}
+	if latestBlockNumber.Uint64() < withdrawalBlock {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("%w, %d blocks remaining", ErrOutputNotProposed, withdrawalBlock-latestBlockNumber.Uint64())
+	}
+
+	l2OutputIndex, err := oracle.GetL2OutputIndexAfter(opts, new(big.Int).SetUint64(withdrawalBlock))
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("could not fetch L2OutputOracle.GetL2OutputIndexAfter: %w", err)
+	}
+	output, err := oracle.GetL2Output(opts, l2OutputIndex)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("could not fetch L2OutputOracle.GetL2Output: %w", err)
+	}
+
+	l2Header, err := l2HeaderCl.HeaderByNumber(ctx, output.L2BlockNumber)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("failed to get l2Block: %w", err)
+	}
+	log.Info("proving against the L2OutputOracle output", "index", l2OutputIndex, "l2Block", output.L2BlockNumber)
+
+	return proveWithdrawalParametersForBlock(ctx, variant, proofCl, l2ReceiptCl, txHash, l2Header, l2OutputIndex)
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// Output is the JSON document written to --output-file describing a single command run
+type Output struct {
+	Command    string         `json:"command"`
+	Success    bool           `json:"success"`
+	Error      string         `json:"error,omitempty"`
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	Inputs     map[string]any `json:"inputs"`
+	Result     any            `json:"result,omitempty"`
+	// Primary is the single value of the result printed to stdout with --quiet, such as the hash of the sent transaction
+	Primary any `json:"-"`
+	// Account, Amount and TxHashes are the outcome fields of the summary logged once the command completes
+	Account  *common.Address `json:"-"`
+	Amount   *big.Int        `json:"-"`
+	TxHashes []common.Hash   `json:"-"`
+}
+
+// SetAccount records the account the command acted for in the summary
+func (o *Output) SetAccount(account common.Address) {
+	o.Account = &account
+}
+
+// AddTx records a transaction sent by the command in the summary
+func (o *Output) AddTx(txHash common.Hash) {
+	o.TxHashes = append(o.TxHashes, txHash)
+}
+
+// logSummary logs a single line with the outcome of the command run, using the same keys for every command so that
+// runs can be scraped and alerted on
+func (o *Output) logSummary(err error) {
+	fields := []any{
+		"operation", o.Command,
+		"account", o.Account,
+		"amount", o.Amount,
+		"txs", o.TxHashes,
+		"duration", o.FinishedAt.Sub(o.StartedAt),
+	}
+	if err != nil {
+		log.Error("operation failed", append(fields, "result", "failure", "error", err)...)
+		return
+	}
+	log.Info("operation complete", append(fields, "result", "success")...)
+}
+
+// WithOutput wraps a command action so that a summary of its outcome is logged once it completes and, when
+// --output-file is set, the inputs, the result built by the action and any returned error are written as JSON
+func WithOutput(action func(c *cli.Context, output *Output) error) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		output := &Output{
+			Command:   c.Command.FullName(),
+			StartedAt: time.Now(),
+			Inputs:    make(map[string]any),
+		}
+		// Every flag of the command is recorded, including the ones left at their default value
+		for _, flag := range c.Command.Flags {
+			name := flag.Names()[0]
+			// Never persist secrets to the output file
+			if strings.Contains(name, "private-key") {
+				continue
+			}
+			value := c.Value(name)
+			if slice, ok := value.(*cli.StringSlice); ok {
+				value = slice.Value()
+			}
+			output.Inputs[name] = value
+		}
+
+		err := action(c, output)
+		output.FinishedAt = time.Now()
+		output.logSummary(err)
+
+		if err == nil && c.Bool("quiet") && output.Primary != nil {
+			if stringer, ok := output.Primary.(fmt.Stringer); ok {
+				fmt.Println(stringer.String())
+			} else if printErr := PrintJSON(output.Primary); printErr != nil {
+				return printErr
+			}
+		}
+
+		path := c.String("output-file")
+		if path == "" {
+			return err
+		}
+
+		output.Success = err == nil
+		if err != nil {
+			output.Error = err.Error()
+		}
+
+		if writeErr := WriteJSONFile(path, output); writeErr != nil {
+			return errors.Join(err, writeErr)
+		}
+		return err
+	}
+}
+
+// WriteJSONFile writes value as indented JSON to the file at path
+func WriteJSONFile(path string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteJSONFileAtomic writes value as indented JSON to path like WriteJSONFile, replacing the file atomically so that
+// an interrupted write leaves the previous content in place
+func WriteJSONFileAtomic(path string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %w", path, err)
+	}
+	return writeFileAtomic(path, data)
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum-optimism/optimism/op-service/eth"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ComputedOutput is the output of an L2 block as computed from the L2 node, with the fields its output root commits to
+type ComputedOutput struct {
+	L2Block                  *big.Int    `json:"l2Block"`
+	BlockHash                common.Hash `json:"blockHash"`
+	StateRoot                common.Hash `json:"stateRoot"`
+	MessagePasserStorageRoot common.Hash `json:"messagePasserStorageRoot"`
+	// WithdrawalsRoot is the message passer storage root committed in the block header from Isthmus on
+	WithdrawalsRoot *common.Hash `json:"withdrawalsRoot,omitempty"`
+	OutputRoot      common.Hash  `json:"outputRoot"`
+}
+
+// ComputeOutputRoot computes the output root of l2Block from the L2 node, taking the storage root of the
+// L2ToL1MessagePasser from its account proof like the fault-proofs proof variant
+func ComputeOutputRoot(ctx context.Context, proofCl withdrawals.ProofClient, l2HeaderCl withdrawals.HeaderClient, l2Block *big.Int) (*ComputedOutput, error) {
+	header, err := l2HeaderCl.HeaderByNumber(ctx, l2Block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get L2 block %d: %w", l2Block, err)
+	}
+	proof, err := proofCl.GetProof(ctx, predeploys.L2ToL1MessagePasserAddr, nil, l2Block)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the L2ToL1MessagePasser proof at L2 block %d: %w", l2Block, err)
+	}
+
+	output := &ComputedOutput{
+		L2Block:                  l2Block,
+		BlockHash:                header.Hash(),
+		StateRoot:                header.Root,
+		MessagePasserStorageRoot: proof.StorageHash,
+		OutputRoot:               outputRoot(header.Root, proof.StorageHash, header.Hash()),
+	}
+	if header.WithdrawalsHash != nil && *header.WithdrawalsHash != types.EmptyWithdrawalsHash {
+		withdrawalsRoot := *header.WithdrawalsHash
+		output.WithdrawalsRoot = &withdrawalsRoot
+	}
+	return output, nil
+}
+
+// OutputRootOfProof computes the output root the output root proof of params commits to, which the portal compares
+// with the root claim of the game proven against
+func OutputRootOfProof(params withdrawals.ProvenWithdrawalParameters) common.Hash {
+	return outputRoot(params.OutputRootProof.StateRoot, params.OutputRootProof.MessagePasserStorageRoot, params.OutputRootProof.LatestBlockhash)
+}
+
+// outputRoot computes the version 0 output root of an L2 block
+func outputRoot(stateRoot common.Hash, messagePasserStorageRoot common.Hash, blockHash common.Hash) common.Hash {
+	output := &eth.OutputV0{
+		StateRoot:                eth.Bytes32(stateRoot),
+		MessagePasserStorageRoot: eth.Bytes32(messagePasserStorageRoot),
+		BlockHash:                blockHash,
+	}
+	return common.Hash(eth.OutputRoot(output))
+}
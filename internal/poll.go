@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// PollUntil calls fn right away and then after every interval until it reports done, returns an error or ctx is done.
+// When maxInterval is above interval, the interval doubles after every unsuccessful call up to maxInterval, otherwise
+// fn is called at a fixed interval.
+func PollUntil(ctx context.Context, interval time.Duration, maxInterval time.Duration, fn func() (bool, error)) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		done, err := fn()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		timer.Reset(interval)
+		if interval < maxInterval {
+			interval = min(interval*2, maxInterval)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// Values of the --portal-version flag
+const (
+	// PortalVersionAuto detects the deployed portal by probing it
+	PortalVersionAuto = "auto"
+	// PortalVersionLegacy is the OptimismPortal proving withdrawals against the L2OutputOracle
+	PortalVersionLegacy = "legacy"
+	// PortalVersionFaultProofs is the OptimismPortal2 proving withdrawals against dispute games
+	PortalVersionFaultProofs = "fault-proofs"
+)
+
+// DetectPortalVersion tells the legacy portal from OptimismPortal2 by probing respectedGameType, which only exists on
+// the latter. The semver reported by the portal is returned for logging.
+func DetectPortalVersion(opts *bind.CallOpts, caller *bindingspreview.OptimismPortal2Caller) (string, string, error) {
+	semver, err := caller.Version(opts)
+	if err != nil {
+		return "", "", fmt.Errorf("could not fetch OptimismPortal.version: %w", err)
+	}
+
+	if _, err := caller.RespectedGameType(opts); err != nil {
+		if strings.Contains(err.Error(), "execution reverted") {
+			return PortalVersionLegacy, semver, nil
+		}
+		return "", "", fmt.Errorf("could not fetch OptimismPortal.respectedGameType: %w", err)
+	}
+
+	return PortalVersionFaultProofs, semver, nil
+}
+
+// NewOptimismPortal2 instantiates the OptimismPortal2 binding used by the withdraw commands, checking first that the
+// portal selected by --portal-version is one it can talk to, rather than failing later on mismatched method signatures
+func NewOptimismPortal2(ctx context.Context, c *cli.Context, client *ethclient.Client, address common.Address) (*bindingspreview.OptimismPortal2, error) {
+	optimismPortal, err := bindingspreview.NewOptimismPortal2(address, client)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := selectedPortalVersion(ctx, c, &optimismPortal.OptimismPortal2Caller, address)
+	if err != nil {
+		return nil, err
+	}
+	if version == PortalVersionLegacy {
+		return nil, fmt.Errorf("OptimismPortal at %s is the legacy portal proving against the L2OutputOracle, pass --l2-output-oracle-address to prove and finalize against it", address)
+	}
+
+	return optimismPortal, nil
+}
+
+// NewLegacyOptimismPortal instantiates the binding of the legacy OptimismPortal used with --l2-output-oracle-address,
+// checking first that the portal selected by --portal-version is not OptimismPortal2
+func NewLegacyOptimismPortal(ctx context.Context, c *cli.Context, client *ethclient.Client, address common.Address) (*opNodeBindings.OptimismPortal, error) {
+	caller, err := bindingspreview.NewOptimismPortal2Caller(address, client)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := selectedPortalVersion(ctx, c, caller, address)
+	if err != nil {
+		return nil, err
+	}
+	if version == PortalVersionFaultProofs {
+		return nil, fmt.Errorf("OptimismPortal at %s is OptimismPortal2 proving against dispute games, pass --dispute-game-factory-address instead of --l2-output-oracle-address", address)
+	}
+
+	return opNodeBindings.NewOptimismPortal(address, client)
+}
+
+// selectedPortalVersion returns the portal version given with --portal-version, detecting it with auto
+func selectedPortalVersion(ctx context.Context, c *cli.Context, caller *bindingspreview.OptimismPortal2Caller, address common.Address) (string, error) {
+	version := c.String("portal-version")
+	switch version {
+	case PortalVersionFaultProofs, PortalVersionLegacy:
+		return version, nil
+	case PortalVersionAuto:
+		readCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+		defer cancel()
+
+		version, semver, err := DetectPortalVersion(&bind.CallOpts{Context: readCtx}, caller)
+		if err != nil {
+			return "", fmt.Errorf("could not detect the OptimismPortal version, set --portal-version to skip detection: %w", err)
+		}
+		log.Debug("detected OptimismPortal version", "address", address, "portal", version, "version", semver)
+		return version, nil
+	default:
+		return "", fmt.Errorf("unknown portal-version %q, expected one of %s, %s or %s", version, PortalVersionAuto, PortalVersionLegacy, PortalVersionFaultProofs)
+	}
+}
+
+// CheckNotFinalized returns ErrAlreadyFinalized when the portal has already finalized the withdrawal, so that the
+// withdraw commands can stop before doing any redundant work and report it as success
+func CheckNotFinalized(opts *bind.CallOpts, caller *bindingspreview.OptimismPortal2Caller, withdrawalHash common.Hash) error {
+	finalized, err := caller.FinalizedWithdrawals(opts, withdrawalHash)
+	if err != nil {
+		return fmt.Errorf("could not fetch OptimismPortal.FinalizedWithdrawals: %w", err)
+	}
+	if finalized {
+		return ErrAlreadyFinalized
+	}
+	return nil
+}
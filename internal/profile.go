@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// ciProfile are the global wait flags changed by --ci, tuned for containerized devnets on shared CI runners that
+// produce blocks late and throttle tight polling:
+//   - rpc-timeout 30s -> 1m, slow nodes answer heavy calls such as eth_getProof late
+//   - startup-timeout 2m -> 10m, devnets take minutes to start producing blocks
+//   - startup-poll-interval 1s -> 5s
+//   - resubmission-timeout 48s -> 3m, blocks are produced irregularly rather than the transaction being underpriced
+//   - wait-timeout unbounded -> 10m, a transaction that is never mined fails the job instead of hanging it
+//   - receipt-poll-interval 1s -> 4s
+var ciProfile = []struct {
+	name  string
+	value string
+}{
+	{"rpc-timeout", "1m"},
+	{"startup-timeout", "10m"},
+	{"startup-poll-interval", "5s"},
+	{"resubmission-timeout", "3m"},
+	{"wait-timeout", "10m"},
+	{"receipt-poll-interval", "4s"},
+}
+
+// ApplyCIProfile changes the defaults of the global wait flags to those of ciProfile when --ci is set. Flags set
+// explicitly keep their value, so single waits can still be tuned on top of the profile.
+func ApplyCIProfile(c *cli.Context) error {
+	if !c.Bool("ci") {
+		return nil
+	}
+	for _, flag := range ciProfile {
+		if c.IsSet(flag.name) {
+			continue
+		}
+		if err := c.Set(flag.name, flag.value); err != nil {
+			return fmt.Errorf("could not apply the ci profile to %s: %w", flag.name, err)
+		}
+	}
+	return nil
+}
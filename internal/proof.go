@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Values of the --proof-variant flag. Both produce proofs for OptimismPortal2 against a dispute game, the legacy
+// portal proving against the L2OutputOracle is not supported.
+const (
+	// ProofVariantFaultProofs is op-node's withdrawal proof, whose account proof of the L2ToL1MessagePasser is checked
+	// against the state root of the L2 block. It works on every fault proofs chain and is the default.
+	ProofVariantFaultProofs = "fault-proofs"
+	// ProofVariantWithdrawalsRoot takes the L2ToL1MessagePasser storage root from the withdrawalsRoot of the L2 block
+	// header and only checks the storage proof against it. It needs Isthmus, which commits that root in the header, and
+	// is meant for L2 nodes whose account proofs do not verify against the state root.
+	ProofVariantWithdrawalsRoot = "withdrawals-root"
+)
+
+// ValidateProofVariant checks the value of the --proof-variant flag
+func ValidateProofVariant(variant string) error {
+	switch variant {
+	case ProofVariantFaultProofs, ProofVariantWithdrawalsRoot:
+		return nil
+	default:
+		return fmt.Errorf("unknown proof-variant %q, expected %s or %s", variant, ProofVariantFaultProofs, ProofVariantWithdrawalsRoot)
+	}
+}
+
+// proveWithdrawalParametersWithdrawalsRoot generates the withdrawal proof like withdrawals.ProveWithdrawalParametersForBlock,
+// with the message passer storage root taken from the withdrawalsRoot of l2Header
+func proveWithdrawalParametersWithdrawalsRoot(ctx context.Context, proofCl withdrawals.ProofClient, l2ReceiptCl withdrawals.ReceiptClient, txHash common.Hash, l2Header *types.Header, l2OutputIndex *big.Int) (withdrawals.ProvenWithdrawalParameters, error) {
+	if l2Header.WithdrawalsHash == nil || *l2Header.WithdrawalsHash == types.EmptyWithdrawalsHash {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("L2 block %d has no withdrawals root, the %s proof variant needs Isthmus to be active", l2Header.Number, ProofVariantWithdrawalsRoot)
+	}
+	storageRoot := *l2Header.WithdrawalsHash
+
+	receipt, err := l2ReceiptCl.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, err
+	}
+	ev, err := withdrawals.ParseMessagePassed(receipt)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, err
+	}
+	withdrawalHash, err := withdrawals.WithdrawalHash(ev)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, err
+	}
+	if withdrawalHash != ev.WithdrawalHash {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("computed withdrawal hash %s does not match the emitted %s", withdrawalHash, common.Hash(ev.WithdrawalHash))
+	}
+
+	slot := withdrawals.StorageSlotOfWithdrawalHash(withdrawalHash)
+	p, err := proofCl.GetProof(ctx, predeploys.L2ToL1MessagePasserAddr, []string{slot.String()}, l2Header.Number)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, err
+	}
+	if len(p.StorageProof) != 1 {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("invalid amount of storage proofs")
+	}
+	if err := withdrawals.VerifyStorageProof(storageRoot, p.StorageProof[0]); err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("storage proof does not verify against the withdrawals root: %w", err)
+	}
+
+	trieNodes := make([][]byte, len(p.StorageProof[0].Proof))
+	for i, node := range p.StorageProof[0].Proof {
+		trieNodes[i] = common.FromHex(node)
+	}
+
+	return withdrawals.ProvenWithdrawalParameters{
+		Nonce:         ev.Nonce,
+		Sender:        ev.Sender,
+		Target:        ev.Target,
+		Value:         ev.Value,
+		GasLimit:      ev.GasLimit,
+		L2OutputIndex: l2OutputIndex,
+		Data:          ev.Data,
+		OutputRootProof: bindings.TypesOutputRootProof{
+			StateRoot:                l2Header.Root,
+			MessagePasserStorageRoot: storageRoot,
+			LatestBlockhash:          l2Header.Hash(),
+		},
+		WithdrawalProof: trieNodes,
+	}, nil
+}
+
+// ProofDump is the JSON encoding of the withdrawal proof parameters written with --dump-proof
+type ProofDump struct {
+	WithdrawalTxHash common.Hash     `json:"withdrawalTxHash"`
+	Nonce            *hexutil.Big    `json:"nonce"`
+	Sender           common.Address  `json:"sender"`
+	Target           common.Address  `json:"target"`
+	Value            *hexutil.Big    `json:"value"`
+	GasLimit         *hexutil.Big    `json:"gasLimit"`
+	Data             hexutil.Bytes   `json:"data"`
+	L2OutputIndex    *hexutil.Big    `json:"l2OutputIndex"`
+	OutputRootProof  outputRootProof `json:"outputRootProof"`
+	WithdrawalProof  []hexutil.Bytes `json:"withdrawalProof"`
+}
+
+type outputRootProof struct {
+	Version                  common.Hash `json:"version"`
+	StateRoot                common.Hash `json:"stateRoot"`
+	MessagePasserStorageRoot common.Hash `json:"messagePasserStorageRoot"`
+	LatestBlockhash          common.Hash `json:"latestBlockhash"`
+}
+
+// DumpProof writes the proof parameters generated for the withdrawal withdrawalTxHash to a JSON file in dir, so that
+// the exact inputs of a reverted prove or finalize can be shared. The path of the file is returned.
+func DumpProof(dir string, withdrawalTxHash common.Hash, params withdrawals.ProvenWithdrawalParameters) (string, error) {
+	dump := &ProofDump{
+		WithdrawalTxHash: withdrawalTxHash,
+		Nonce:            (*hexutil.Big)(params.Nonce),
+		Sender:           params.Sender,
+		Target:           params.Target,
+		Value:            (*hexutil.Big)(params.Value),
+		GasLimit:         (*hexutil.Big)(params.GasLimit),
+		Data:             params.Data,
+		L2OutputIndex:    (*hexutil.Big)(params.L2OutputIndex),
+		OutputRootProof: outputRootProof{
+			Version:                  params.OutputRootProof.Version,
+			StateRoot:                params.OutputRootProof.StateRoot,
+			MessagePasserStorageRoot: params.OutputRootProof.MessagePasserStorageRoot,
+			LatestBlockhash:          params.OutputRootProof.LatestBlockhash,
+		},
+		WithdrawalProof: make([]hexutil.Bytes, len(params.WithdrawalProof)),
+	}
+	for i, node := range params.WithdrawalProof {
+		dump.WithdrawalProof[i] = node
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("could not create proof dump directory %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("proof-%s.json", withdrawalTxHash.Hex()))
+	if err := WriteJSONFile(path, dump); err != nil {
+		return "", err
+	}
+
+	log.Info("wrote withdrawal proof parameters", "path", path)
+	return path, nil
+}
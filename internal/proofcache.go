@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"context"
+	"sync"
+
+	opNodeBindings "github.com/ethereum-optimism/optimism/op-node/bindings"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// WithdrawalProofCache is an in-process cache of the withdrawal proofs generated during a single command run, keyed by
+// the withdrawal transaction and the index of the game the proof was generated against, so that the eth_getProof
+// calls behind a proof are made once per withdrawal and game
+type WithdrawalProofCache struct {
+	mu     sync.Mutex
+	proofs map[common.Hash]*cachedWithdrawalProof
+}
+
+// cachedWithdrawalProof is the proof of a withdrawal against a single game, generated with the given proof variant
+type cachedWithdrawalProof struct {
+	gameIndex uint64
+	variant   string
+	params    withdrawals.ProvenWithdrawalParameters
+}
+
+func NewWithdrawalProofCache() *WithdrawalProofCache {
+	return &WithdrawalProofCache{proofs: make(map[common.Hash]*cachedWithdrawalProof)}
+}
+
+// ProveWithdrawalParametersForGame returns the proof of the withdrawal txHash against game, generating it with
+// ProveWithdrawalParametersForGame on a miss. Only the proof against the last requested game is kept per withdrawal: a
+// proof against another game is dropped, since the withdrawal has to be proven again against the new game anyway.
+func (c *WithdrawalProofCache) ProveWithdrawalParametersForGame(ctx context.Context, variant string, proofCl withdrawals.ProofClient, l2ReceiptCl withdrawals.ReceiptClient, l2HeaderCl withdrawals.HeaderClient, txHash common.Hash, game *opNodeBindings.IDisputeGameFactoryGameSearchResult) (withdrawals.ProvenWithdrawalParameters, error) {
+	gameIndex := game.Index.Uint64()
+
+	c.mu.Lock()
+	cached, ok := c.proofs[txHash]
+	c.mu.Unlock()
+	if ok && cached.gameIndex == gameIndex && cached.variant == variant {
+		log.Debug("reusing withdrawal proof", "tx", txHash.Hex(), "gameIndex", gameIndex)
+		return cached.params, nil
+	}
+	if ok {
+		log.Info("dispute game changed since the withdrawal proof was generated, generating it again", "tx", txHash.Hex(), "previousGameIndex", cached.gameIndex, "gameIndex", gameIndex)
+	}
+
+	params, err := ProveWithdrawalParametersForGame(ctx, variant, proofCl, l2ReceiptCl, l2HeaderCl, txHash, game)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, err
+	}
+
+	c.mu.Lock()
+	c.proofs[txHash] = &cachedWithdrawalProof{gameIndex: gameIndex, variant: variant, params: params}
+	c.mu.Unlock()
+	return params, nil
+}
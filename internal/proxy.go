@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// eip1967ImplementationSlot is the storage slot EIP-1967 proxies keep their implementation address in,
+// bytes32(uint256(keccak256("eip1967.proxy.implementation")) - 1)
+var eip1967ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+
+// CheckProxy checks the contract address passed with flag, logging the EIP-1967 implementation it points to. An address
+// without code and one that is not a proxy are warned about, the latter usually being the implementation passed in
+// place of the proxy holding the state. Failed reads are only logged, the commands report them on first use.
+func CheckProxy(ctx context.Context, client *ethclient.Client, flag string, address common.Address) {
+	readCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+
+	code, err := client.CodeAt(readCtx, address, nil)
+	if err != nil {
+		log.Warn("could not fetch the code of the contract address", "flag", flag, "address", address, "error", err)
+		return
+	}
+	if len(code) == 0 {
+		log.Warn("contract address has no code, check that it is deployed on this chain", "flag", flag, "address", address)
+		return
+	}
+
+	slot, err := client.StorageAt(readCtx, address, eip1967ImplementationSlot, nil)
+	if err != nil {
+		log.Warn("could not read the EIP-1967 implementation slot of the contract address", "flag", flag, "address", address, "error", err)
+		return
+	}
+	implementation := common.BytesToAddress(slot)
+	if implementation == ZeroAddress {
+		log.Warn("contract address is not an EIP-1967 proxy, pass the proxy address if this is its implementation", "flag", flag, "address", address)
+		return
+	}
+
+	implementationCode, err := client.CodeAt(readCtx, implementation, nil)
+	if err != nil {
+		log.Warn("could not fetch the code of the proxy implementation", "flag", flag, "proxy", address, "implementation", implementation, "error", err)
+		return
+	}
+	if len(implementationCode) == 0 {
+		log.Warn("proxy points at an implementation without code", "flag", flag, "proxy", address, "implementation", implementation)
+		return
+	}
+	log.Info("contract address is a proxy", "flag", flag, "proxy", address, "implementation", implementation)
+}
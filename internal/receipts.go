@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultReceiptCacheSize bounds the number of receipts a ReceiptCache keeps for a single command run
+const DefaultReceiptCacheSize = 128
+
+// ReceiptClient is the subset of the ethclient used to fetch transaction receipts
+type ReceiptClient interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// ReceiptCache is a bounded, in-process cache of transaction receipts for a single command run. It satisfies the
+// receipt client interface of the op-node withdrawals package, so the proof helpers reuse already fetched receipts
+type ReceiptCache struct {
+	client ReceiptClient
+	size   int
+
+	mu       sync.Mutex
+	receipts map[common.Hash]*types.Receipt
+	order    []common.Hash
+}
+
+func NewReceiptCache(client ReceiptClient, size int) *ReceiptCache {
+	return &ReceiptCache{
+		client:   client,
+		size:     size,
+		receipts: make(map[common.Hash]*types.Receipt, size),
+	}
+}
+
+// TransactionReceipt returns the cached receipt for txHash, fetching it from the client on a miss. Once the cache is
+// full the oldest receipt is evicted.
+func (c *ReceiptCache) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	c.mu.Lock()
+	receipt, ok := c.receipts[txHash]
+	c.mu.Unlock()
+	if ok {
+		return receipt, nil
+	}
+
+	receipt, err := c.client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.receipts[txHash]; !ok {
+		if len(c.order) >= c.size {
+			delete(c.receipts, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, txHash)
+	}
+	c.receipts[txHash] = receipt
+
+	return receipt, nil
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/Golem-Base/op-probe/bindings"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RootClaimIndex is the index of the root claim in the claim data of a dispute game. OptimismPortal2 proves
+// withdrawals against the root claim of the game, the output root it proposes, so the subgame of the root claim
+// decides the outcome for every withdrawal proven against the game, whatever counter claims the game holds. How long
+// the root claim takes to resolve does depend on those counter claims, see PlanSubgameResolution.
+var RootClaimIndex = common.Big0
+
+// SubgameResolution is what can be resolved of the subgames of a dispute game at the time it was planned
+type SubgameResolution struct {
+	// Resolvable holds the indices of the unresolved claims that can be resolved now, deepest first so that every
+	// claim is resolved after the claims countering it
+	Resolvable []*big.Int
+	// RootResolved is set when the root claim is resolved, or will be once the resolvable claims are
+	RootResolved bool
+	// Remaining is how long until the last challenger clock blocking the root claim expires, 0 when none is
+	Remaining time.Duration
+}
+
+// PlanSubgameResolution works out which claims of game can be resolved with ResolveClaim. A claim can be resolved
+// once its challenger clock has expired and every claim countering it is resolved, so the claims are walked from the
+// last to the root and a claim that cannot be resolved yet blocks its parent as well.
+func PlanSubgameResolution(opts *bind.CallOpts, game *bindings.PermissionedDisputeGame) (*SubgameResolution, error) {
+	_maxClockDuration, err := game.MaxClockDuration(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch PermissionedDisputeGame.MaxClockDuration: %w", err)
+	}
+	maxClockDuration := time.Duration(_maxClockDuration * uint64(time.Second))
+
+	claimCount, err := game.ClaimDataLen(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch PermissionedDisputeGame.ClaimDataLen: %w", err)
+	}
+
+	plan := &SubgameResolution{}
+	blocked := make([]bool, claimCount.Uint64())
+	for i := len(blocked) - 1; i >= 0; i-- {
+		index := big.NewInt(int64(i))
+
+		resolved, err := game.ResolvedSubgames(opts, index)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch PermissionedDisputeGame.ResolvedSubgames of claim %d: %w", i, err)
+		}
+		if !resolved {
+			_challengerDuration, err := game.GetChallengerDuration(opts, index)
+			if err != nil {
+				return nil, fmt.Errorf("could not fetch PermissionedDisputeGame.GetChallengerDuration of claim %d: %w", i, err)
+			}
+			challengerDuration := time.Duration(_challengerDuration * uint64(time.Second))
+			if challengerDuration < maxClockDuration {
+				blocked[i] = true
+				plan.Remaining = max(plan.Remaining, maxClockDuration-challengerDuration)
+			}
+			if !blocked[i] {
+				plan.Resolvable = append(plan.Resolvable, index)
+			}
+		}
+
+		if i == 0 || !blocked[i] {
+			continue
+		}
+		claim, err := game.ClaimData(opts, index)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch PermissionedDisputeGame.ClaimData of claim %d: %w", i, err)
+		}
+		if claim.ParentIndex != math.MaxUint32 && int(claim.ParentIndex) < len(blocked) {
+			blocked[claim.ParentIndex] = true
+		}
+	}
+	plan.RootResolved = len(blocked) > 0 && !blocked[0]
+
+	return plan, nil
+}
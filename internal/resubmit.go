@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// MinFeeBumpPercent is the smallest fee bump nodes accept for replacing a pending transaction
+const MinFeeBumpPercent = 10
+
+// ResubmitConfig controls how pending transactions are waited for and re-priced. The defaults follow the txmgr of
+// the op-service batcher and proposer.
+type ResubmitConfig struct {
+	// ResubmissionTimeout is how long a transaction may stay pending before it is resubmitted with bumped fees, 0
+	// disables resubmission
+	ResubmissionTimeout time.Duration
+	// FeeBumpPercent is the percentage the tip and fee cap are raised by on every resubmission
+	FeeBumpPercent uint64
+	// FeeLimitMultiplier caps the fee cap of resubmissions at this multiple of the fee cap first sent
+	FeeLimitMultiplier uint64
+	// WaitTimeout bounds the wait for a transaction to be mined, resubmissions included, 0 waits until the transaction
+	// is mined or the command is interrupted
+	WaitTimeout time.Duration
+	// ReceiptPollInterval is the interval between receipt checks while waiting for a transaction to be mined
+	ReceiptPollInterval time.Duration
+}
+
+var resubmitConfig = ResubmitConfig{
+	ResubmissionTimeout: 48 * time.Second,
+	FeeBumpPercent:      MinFeeBumpPercent,
+	FeeLimitMultiplier:  5,
+	WaitTimeout:         0,
+	ReceiptPollInterval: time.Second,
+}
+
+// SetResubmitConfig reads the --resubmission-timeout, --fee-bump-percent, --fee-limit-multiplier, --wait-timeout and
+// --receipt-poll-interval global flags used by every transaction sent afterwards
+func SetResubmitConfig(c *cli.Context) error {
+	cfg := ResubmitConfig{
+		ResubmissionTimeout: c.Duration("resubmission-timeout"),
+		FeeBumpPercent:      c.Uint64("fee-bump-percent"),
+		FeeLimitMultiplier:  c.Uint64("fee-limit-multiplier"),
+		WaitTimeout:         c.Duration("wait-timeout"),
+		ReceiptPollInterval: c.Duration("receipt-poll-interval"),
+	}
+	if cfg.FeeBumpPercent < MinFeeBumpPercent {
+		return fmt.Errorf("fee-bump-percent must be at least %d, nodes reject smaller replacements", MinFeeBumpPercent)
+	}
+	if cfg.FeeLimitMultiplier < 1 {
+		return fmt.Errorf("fee-limit-multiplier must be at least 1")
+	}
+	if cfg.WaitTimeout < 0 {
+		return fmt.Errorf("wait-timeout must not be negative")
+	}
+	if cfg.ReceiptPollInterval <= 0 {
+		return fmt.Errorf("receipt-poll-interval must be greater than 0")
+	}
+	resubmitConfig = cfg
+	return nil
+}
+
+// waitForMined waits up to the wait timeout, or for as long as ctx allows without one, until one of the transactions
+// returned by sent is mined and returns its index. onPending is called on every check that found none of them mined.
+func waitForMined(ctx context.Context, client *ethclient.Client, sent func() []common.Hash, onPending func()) (int, error) {
+	waitCtx := ctx
+	if resubmitConfig.WaitTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, resubmitConfig.WaitTimeout)
+		defer cancel()
+	}
+
+	mined := -1
+	err := PollUntil(waitCtx, resubmitConfig.ReceiptPollInterval, resubmitConfig.ReceiptPollInterval, func() (bool, error) {
+		for i, txHash := range sent() {
+			_, err := client.TransactionReceipt(waitCtx, txHash)
+			if err == nil {
+				mined = i
+				return true, nil
+			}
+			if !errors.Is(err, ethereum.NotFound) && !strings.Contains(err.Error(), "transaction indexing is in progress") {
+				log.Warn("could not fetch receipt, retrying", "tx", txHash.Hex(), "error", err)
+			}
+		}
+		onPending()
+		return false, nil
+	})
+	if err != nil && resubmitConfig.WaitTimeout > 0 {
+		return -1, fmt.Errorf("timed out after %s waiting for the transaction to be mined: %w", resubmitConfig.WaitTimeout, err)
+	}
+	if err != nil {
+		return -1, fmt.Errorf("stopped waiting for the transaction to be mined: %w", err)
+	}
+	return mined, nil
+}
+
+// waitResubmitting waits for tx to be mined, resubmitting it under the same nonce with bumped fees every resubmission
+// timeout it stays pending, and returns whichever of the submissions was mined. Transactions of an impersonating
+// transactor are signed by the node and only waited for.
+func waitResubmitting(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, name string, tx *types.Transaction) (*types.Transaction, error) {
+	sent := []*types.Transaction{tx}
+	hashes := []common.Hash{tx.Hash()}
+	lastSent := time.Now()
+
+	resubmit := resubmitConfig.ResubmissionTimeout > 0 && !isImpersonating(opts)
+	mined, err := waitForMined(ctx, client, func() []common.Hash { return hashes }, func() {
+		if !resubmit || time.Since(lastSent) < resubmitConfig.ResubmissionTimeout {
+			return
+		}
+		lastSent = time.Now()
+
+		bumped, err := bumpFees(ctx, client, opts, tx, sent[len(sent)-1])
+		if err != nil {
+			log.Warn("could not resubmit pending transaction, waiting for it", "call", name, "tx", sent[len(sent)-1].Hash().Hex(), "error", err)
+			return
+		}
+		if bumped == nil {
+			log.Warn("pending transaction reached the fee limit, waiting for it without resubmitting", "call", name, "tx", sent[len(sent)-1].Hash().Hex())
+			return
+		}
+		if err := client.SendTransaction(ctx, bumped); err != nil {
+			// The error is expected when one of the previous submissions was mined in the meantime
+			log.Warn("could not resubmit pending transaction, waiting for the previous submissions", "call", name, "tx", bumped.Hash().Hex(), "error", err)
+			return
+		}
+		sent = append(sent, bumped)
+		hashes = append(hashes, bumped.Hash())
+		log.Info("resubmitted pending transaction with bumped fees", "call", name, "tx", bumped.Hash().Hex(), "replaces", sent[len(sent)-2].Hash().Hex(), "nonce", bumped.Nonce(), "gasTipCap", FormatWei(bumped.GasTipCap()), "gasFeeCap", FormatWei(bumped.GasFeeCap()))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s receipt: %w", name, err)
+	}
+	return sent[mined], nil
+}
+
+// bumpFees signs a copy of the pending transaction with its tip and fee cap raised by the fee bump percentage, and at
+// least to the currently suggested fees. The fee cap is limited to the fee limit multiplier times the fee cap of the
+// first submission; nil is returned once pending has reached it.
+func bumpFees(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, first *types.Transaction, pending *types.Transaction) (*types.Transaction, error) {
+	readCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+
+	limit := new(big.Int).Mul(first.GasFeeCap(), new(big.Int).SetUint64(resubmitConfig.FeeLimitMultiplier))
+	if pending.GasFeeCap().Cmp(limit) >= 0 {
+		return nil, nil
+	}
+
+	var replacement types.TxData
+	switch pending.Type() {
+	case types.LegacyTxType:
+		gasPrice, err := client.SuggestGasPrice(readCtx)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch gas price: %w", err)
+		}
+		gasPrice = bigMax(bumpFee(pending.GasPrice()), gasPrice)
+		replacement = &types.LegacyTx{
+			Nonce:    pending.Nonce(),
+			GasPrice: bigMin(gasPrice, limit),
+			Gas:      pending.Gas(),
+			To:       pending.To(),
+			Value:    pending.Value(),
+			Data:     pending.Data(),
+		}
+	case types.DynamicFeeTxType:
+		tip, err := client.SuggestGasTipCap(readCtx)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch gas tip cap: %w", err)
+		}
+		header, err := client.HeaderByNumber(readCtx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch latest header: %w", err)
+		}
+		if header.BaseFee == nil {
+			return nil, fmt.Errorf("chain has no base fee, dynamic fee transactions cannot be resubmitted")
+		}
+		tip = bigMax(bumpFee(pending.GasTipCap()), tip)
+		// Like bind, the fee cap leaves room for the base fee to double
+		feeCap := new(big.Int).Add(new(big.Int).Mul(header.BaseFee, big.NewInt(2)), tip)
+		feeCap = bigMin(bigMax(bumpFee(pending.GasFeeCap()), feeCap), limit)
+		replacement = &types.DynamicFeeTx{
+			ChainID:    pending.ChainId(),
+			Nonce:      pending.Nonce(),
+			GasTipCap:  bigMin(tip, feeCap),
+			GasFeeCap:  feeCap,
+			Gas:        pending.Gas(),
+			To:         pending.To(),
+			Value:      pending.Value(),
+			Data:       pending.Data(),
+			AccessList: pending.AccessList(),
+		}
+	default:
+		return nil, fmt.Errorf("transactions of type %d are not resubmitted", pending.Type())
+	}
+
+	signed, err := opts.Signer(opts.From, types.NewTx(replacement))
+	if err != nil {
+		return nil, fmt.Errorf("could not sign resubmission: %w", err)
+	}
+	return signed, nil
+}
+
+// bumpFee raises fee by the fee bump percentage, rounding up
+func bumpFee(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, new(big.Int).SetUint64(100+resubmitConfig.FeeBumpPercent))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+func bigMax(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+func bigMin(a, b *big.Int) *big.Int {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
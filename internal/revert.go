@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/Golem-Base/op-probe/bindings"
+	e2eBindings "github.com/ethereum-optimism/optimism/op-e2e/bindings"
+	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/transactions"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// SimulationGasLimit is the gas limit transactions are built with by SimulateTransaction, which only uses their
+// calldata
+const SimulationGasLimit = 30_000_000
+
+// revertMetaData are the contracts the commands send transactions to, whose custom errors DecodeRevert recognises
+var revertMetaData = []*bind.MetaData{
+	bindingspreview.OptimismPortal2MetaData,
+	e2eBindings.OptimismPortalMetaData,
+	e2eBindings.L1StandardBridgeMetaData,
+	e2eBindings.L2StandardBridgeMetaData,
+	bindings.PermissionedDisputeGameMetaData,
+}
+
+// RevertReason replays the failed transaction txHash with eth_call at the block before its inclusion and returns the
+// decoded revert reason, for RPC providers that do not return transaction traces
+func RevertReason(ctx context.Context, client *ethclient.Client, txHash common.Hash, receipt *types.Receipt) (string, error) {
+	tx, _, err := client.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch transaction: %w", err)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return "", fmt.Errorf("could not recover transaction sender: %w", err)
+	}
+
+	msg := ethereum.CallMsg{
+		From:  from,
+		To:    tx.To(),
+		Gas:   tx.Gas(),
+		Value: tx.Value(),
+		Data:  tx.Data(),
+	}
+	blockNumber := new(big.Int).Sub(receipt.BlockNumber, common.Big1)
+	_, err = client.CallContract(ctx, msg, blockNumber)
+	if err == nil {
+		return "", fmt.Errorf("replay at block %d did not revert", blockNumber)
+	}
+
+	return callErrorReason(err), nil
+}
+
+// SimulateTransaction runs the transaction built by builder as an eth_call from the account of opts against the
+// latest state, so that a transaction that would revert is caught with its decoded reason before any gas is spent
+func SimulateTransaction(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, name string, builder transactions.TxBuilder) error {
+	callOpts := *opts
+	callOpts.Context = ctx
+	callOpts.NoSend = true
+	// The transaction is only built for its calldata. A preset gas limit skips the gas estimate, whose error would not
+	// carry the revert data.
+	callOpts.GasLimit = SimulationGasLimit
+
+	tx, err := builder(&callOpts)
+	if err != nil {
+		return fmt.Errorf("failed to build %s: %w", name, err)
+	}
+
+	msg := ethereum.CallMsg{
+		From:  opts.From,
+		To:    tx.To(),
+		Value: tx.Value(),
+		Data:  tx.Data(),
+	}
+	if _, err := client.CallContract(ctx, msg, nil); err != nil {
+		reason := callErrorReason(err)
+		log.Error("simulated transaction reverted", "call", name, "reason", reason)
+		return fmt.Errorf("%s would revert with %s", name, reason)
+	}
+
+	log.Info("simulated transaction succeeded", "call", name)
+	return nil
+}
+
+// callErrorReason returns the decoded revert reason of a failed eth_call, falling back to the error message when the
+// node returned no revert data
+func callErrorReason(err error) string {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return err.Error()
+	}
+	dataHex, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return err.Error()
+	}
+	data, decodeErr := hexutil.Decode(dataHex)
+	if decodeErr != nil {
+		return err.Error()
+	}
+
+	return DecodeRevert(data)
+}
+
+// DecodeRevert decodes revert data as an Error(string) or Panic(uint256) reason, or as a custom error of one of the
+// known contracts, falling back to the hex encoded data
+func DecodeRevert(data []byte) string {
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		return reason
+	}
+
+	if len(data) >= 4 {
+		for _, metaData := range revertMetaData {
+			contractABI, err := metaData.GetAbi()
+			if err != nil {
+				continue
+			}
+			for _, abiError := range contractABI.Errors {
+				if !bytes.Equal(abiError.ID[:4], data[:4]) {
+					continue
+				}
+				args, err := abiError.Unpack(data)
+				if err != nil {
+					return abiError.Name
+				}
+				return fmt.Sprintf("%s%v", abiError.Name, args)
+			}
+		}
+	}
+
+	return hexutil.Encode(data)
+}
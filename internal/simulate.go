@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// SimulatedCall is a call run by SimulateCall or SimulateBlocks, named to identify it in logs and results
+type SimulatedCall struct {
+	Name string
+	From common.Address
+	To   common.Address
+	Data []byte
+}
+
+// SimulatedBlock is a block of calls run by SimulateBlocks on top of the state left by the previous blocks, mined at
+// the given timestamp
+type SimulatedBlock struct {
+	Time  uint64
+	Calls []SimulatedCall
+}
+
+// SimulationResult is the outcome of a simulated call, with the decoded revert reason of a failed one
+type SimulationResult struct {
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	GasUsed uint64 `json:"gasUsed,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// SimulateCall runs call with eth_call against the latest state. A call that fails is reported with its decoded
+// revert reason rather than returned as an error.
+func SimulateCall(ctx context.Context, client *ethclient.Client, call SimulatedCall) *SimulationResult {
+	to := call.To
+	msg := ethereum.CallMsg{From: call.From, To: &to, Data: call.Data}
+	if _, err := client.CallContract(ctx, msg, nil); err != nil {
+		return &SimulationResult{Name: call.Name, Reason: callErrorReason(err)}
+	}
+	return &SimulationResult{Name: call.Name, Success: true}
+}
+
+type simulateCallArgs struct {
+	From  common.Address `json:"from"`
+	To    common.Address `json:"to"`
+	Input hexutil.Bytes  `json:"input"`
+}
+
+type simulateBlockOverrides struct {
+	Time hexutil.Uint64 `json:"time"`
+}
+
+type simulateBlockStateCalls struct {
+	BlockOverrides simulateBlockOverrides `json:"blockOverrides"`
+	Calls          []simulateCallArgs     `json:"calls"`
+}
+
+type simulateOpts struct {
+	BlockStateCalls []simulateBlockStateCalls `json:"blockStateCalls"`
+	Validation      bool                      `json:"validation"`
+}
+
+type simulateCallError struct {
+	Message string `json:"message"`
+}
+
+type simulateCallResult struct {
+	ReturnData hexutil.Bytes      `json:"returnData"`
+	GasUsed    hexutil.Uint64     `json:"gasUsed"`
+	Status     hexutil.Uint64     `json:"status"`
+	Error      *simulateCallError `json:"error"`
+}
+
+type simulateBlockResult struct {
+	Calls []simulateCallResult `json:"calls"`
+}
+
+// SimulateBlocks runs the calls of blocks with eth_simulateV1 on top of the latest state, each block seeing the state
+// changes of the ones before it. Overriding the timestamps of the blocks lets calls that wait out on-chain delays be
+// simulated right away. Failed calls are reported with their decoded revert reason, the results are returned in the
+// order of blocks and their calls.
+func SimulateBlocks(ctx context.Context, client *ethclient.Client, blocks []SimulatedBlock) ([]*SimulationResult, error) {
+	opts := simulateOpts{}
+	for _, block := range blocks {
+		stateCalls := simulateBlockStateCalls{
+			BlockOverrides: simulateBlockOverrides{Time: hexutil.Uint64(block.Time)},
+			Calls:          make([]simulateCallArgs, 0, len(block.Calls)),
+		}
+		for _, call := range block.Calls {
+			stateCalls.Calls = append(stateCalls.Calls, simulateCallArgs{From: call.From, To: call.To, Input: call.Data})
+		}
+		opts.BlockStateCalls = append(opts.BlockStateCalls, stateCalls)
+	}
+
+	var blockResults []simulateBlockResult
+	if err := client.Client().CallContext(ctx, &blockResults, "eth_simulateV1", opts, "latest"); err != nil {
+		return nil, fmt.Errorf("could not simulate with eth_simulateV1: %w", err)
+	}
+	if len(blockResults) != len(blocks) {
+		return nil, fmt.Errorf("eth_simulateV1 returned %d blocks, expected %d", len(blockResults), len(blocks))
+	}
+
+	var results []*SimulationResult
+	for i, block := range blocks {
+		if len(blockResults[i].Calls) != len(block.Calls) {
+			return nil, fmt.Errorf("eth_simulateV1 returned %d calls for block %d, expected %d", len(blockResults[i].Calls), i, len(block.Calls))
+		}
+		for j, call := range block.Calls {
+			callResult := blockResults[i].Calls[j]
+			result := &SimulationResult{Name: call.Name, GasUsed: uint64(callResult.GasUsed), Success: callResult.Status == 1}
+			if !result.Success {
+				switch {
+				case len(callResult.ReturnData) > 0:
+					result.Reason = DecodeRevert(callResult.ReturnData)
+				case callResult.Error != nil:
+					result.Reason = callResult.Error.Message
+				default:
+					result.Reason = "reverted without data"
+				}
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,360 @@
+// Package testutil serves fake execution clients in process, so that the logic of the commands can be tested against
+// contracts whose behaviour a test scripts, without a devnet. A Chain mines every transaction it is sent into its own
+// block right away, charging its sender a fixed amount of gas.
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// GasUsed is the gas every transaction mined by a Chain uses, and the estimate it returns for any call
+const GasUsed = 100_000
+
+// TxHandler executes a transaction sent to the address it is registered for, appending the logs it emits to receipt
+// with Chain.AppendLog. An error fails the receipt without transferring the value of the transaction.
+type TxHandler func(from common.Address, tx *types.Transaction, receipt *types.Receipt) error
+
+// CallHandler answers an eth_call to the address it is registered for with the returned data, an error reverts
+type CallHandler func(from common.Address, data []byte) ([]byte, error)
+
+// Chain is a fake execution client holding balances, nonces, code and receipts
+type Chain struct {
+	ChainID *big.Int
+	BaseFee *big.Int
+	Tip     *big.Int
+	// BalanceErr fails the balance reads of the accounts it returns an error for when set
+	BalanceErr func(account common.Address) error
+
+	mu           sync.Mutex
+	head         uint64
+	balances     map[common.Address]*big.Int
+	nonces       map[common.Address]uint64
+	code         map[common.Address][]byte
+	receipts     map[common.Hash]*types.Receipt
+	txHandlers   map[common.Address]TxHandler
+	callHandlers map[common.Address]CallHandler
+	sent         []*types.Transaction
+
+	client *ethclient.Client
+}
+
+// NewChain starts a fake chain with chainID, which is stopped when the test finishes
+func NewChain(t testing.TB, chainID *big.Int) *Chain {
+	t.Helper()
+	chain := &Chain{
+		ChainID:      chainID,
+		BaseFee:      big.NewInt(params.GWei),
+		Tip:          big.NewInt(params.GWei),
+		head:         1,
+		balances:     make(map[common.Address]*big.Int),
+		nonces:       make(map[common.Address]uint64),
+		code:         make(map[common.Address][]byte),
+		receipts:     make(map[common.Hash]*types.Receipt),
+		txHandlers:   make(map[common.Address]TxHandler),
+		callHandlers: make(map[common.Address]CallHandler),
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &ethService{chain: chain}); err != nil {
+		t.Fatalf("could not register the eth service: %v", err)
+	}
+	chain.client = ethclient.NewClient(rpc.DialInProc(server))
+	t.Cleanup(func() {
+		chain.client.Close()
+		server.Stop()
+	})
+	return chain
+}
+
+// Client returns a client connected to the chain
+func (c *Chain) Client() *ethclient.Client {
+	return c.client
+}
+
+// SetBalance sets the balance of account
+func (c *Chain) SetBalance(account common.Address, balance *big.Int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.balances[account] = new(big.Int).Set(balance)
+}
+
+// Balance returns the balance of account
+func (c *Chain) Balance(account common.Address) *big.Int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.balance(account)
+}
+
+func (c *Chain) balance(account common.Address) *big.Int {
+	if balance, ok := c.balances[account]; ok {
+		return new(big.Int).Set(balance)
+	}
+	return new(big.Int)
+}
+
+// SetCode sets the code of address, which the contract bindings check before calling it
+func (c *Chain) SetCode(address common.Address, code []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.code[address] = code
+}
+
+// HandleTransactions executes the transactions sent to address with handler, setting placeholder code at address
+func (c *Chain) HandleTransactions(address common.Address, handler TxHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.txHandlers[address] = handler
+	c.setPlaceholderCode(address)
+}
+
+// HandleCalls answers the eth_calls to address with handler, setting placeholder code at address
+func (c *Chain) HandleCalls(address common.Address, handler CallHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.callHandlers[address] = handler
+	c.setPlaceholderCode(address)
+}
+
+func (c *Chain) setPlaceholderCode(address common.Address) {
+	if len(c.code[address]) == 0 {
+		c.code[address] = []byte{0x00}
+	}
+}
+
+// Sent returns the transactions the chain was sent, in order
+func (c *Chain) Sent() []*types.Transaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*types.Transaction(nil), c.sent...)
+}
+
+// AddReceipt mines receipt in a block of its own, for transactions the chain derives rather than is sent such as
+// deposits
+func (c *Chain) AddReceipt(receipt *types.Receipt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mine(receipt)
+}
+
+// AppendLog appends log to the logs of receipt, filling in the position of the log like a node would
+func (c *Chain) AppendLog(receipt *types.Receipt, log *types.Log) {
+	log.BlockNumber = receipt.BlockNumber.Uint64()
+	log.BlockHash = receipt.BlockHash
+	log.TxHash = receipt.TxHash
+	log.TxIndex = receipt.TransactionIndex
+	log.Index = uint(len(receipt.Logs))
+	receipt.Logs = append(receipt.Logs, log)
+}
+
+// mine sets the block of receipt to the next block and records it
+func (c *Chain) mine(receipt *types.Receipt) {
+	c.head++
+	receipt.BlockNumber = new(big.Int).SetUint64(c.head)
+	receipt.BlockHash = blockHash(c.head)
+	if receipt.Logs == nil {
+		receipt.Logs = []*types.Log{}
+	}
+	for _, log := range receipt.Logs {
+		log.BlockNumber = c.head
+		log.BlockHash = receipt.BlockHash
+	}
+	c.receipts[receipt.TxHash] = receipt
+}
+
+// send executes the signed transaction tx and mines its receipt
+func (c *Chain) send(tx *types.Transaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tx.To() == nil {
+		return errors.New("contract creation is not supported")
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(c.ChainID), tx)
+	if err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+	if tx.Nonce() != c.nonces[from] {
+		return fmt.Errorf("invalid nonce %d, expected %d", tx.Nonce(), c.nonces[from])
+	}
+	gasPrice := c.effectiveGasPrice(tx)
+	maxCost := new(big.Int).Add(tx.Value(), new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas())))
+	if c.balance(from).Cmp(maxCost) < 0 {
+		return fmt.Errorf("insufficient funds for gas * price + value")
+	}
+	if tx.Gas() < GasUsed {
+		return fmt.Errorf("intrinsic gas too low")
+	}
+	c.sent = append(c.sent, tx)
+	c.nonces[from]++
+
+	fee := new(big.Int).Mul(gasPrice, big.NewInt(GasUsed))
+	c.balances[from] = new(big.Int).Sub(c.balance(from), fee)
+
+	receipt := &types.Receipt{
+		Type:              tx.Type(),
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: GasUsed,
+		GasUsed:           GasUsed,
+		EffectiveGasPrice: gasPrice,
+		TxHash:            tx.Hash(),
+		BlockNumber:       new(big.Int).SetUint64(c.head + 1),
+		BlockHash:         blockHash(c.head + 1),
+		Logs:              []*types.Log{},
+	}
+	if handler, ok := c.txHandlers[*tx.To()]; ok {
+		// Handlers may call back into the chain, such as to mine a derived transaction on another chain
+		c.mu.Unlock()
+		err = handler(from, tx, receipt)
+		c.mu.Lock()
+		if err != nil {
+			receipt.Status = types.ReceiptStatusFailed
+			receipt.Logs = []*types.Log{}
+		}
+	}
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		c.balances[from] = new(big.Int).Sub(c.balance(from), tx.Value())
+		c.balances[*tx.To()] = new(big.Int).Add(c.balance(*tx.To()), tx.Value())
+	}
+	c.mine(receipt)
+	return nil
+}
+
+func (c *Chain) effectiveGasPrice(tx *types.Transaction) *big.Int {
+	if tx.Type() == types.LegacyTxType {
+		return tx.GasPrice()
+	}
+	return new(big.Int).Add(c.BaseFee, tx.EffectiveGasTipValue(c.BaseFee))
+}
+
+// blockHash is the hash of the fake block at number
+func blockHash(number uint64) common.Hash {
+	return crypto.Keccak256Hash(new(big.Int).SetUint64(number).Bytes())
+}
+
+// callArgs are the fields of eth_call and eth_estimateGas requests a Chain looks at
+type callArgs struct {
+	From  *common.Address `json:"from"`
+	To    *common.Address `json:"to"`
+	Input hexutil.Bytes   `json:"input"`
+	Data  hexutil.Bytes   `json:"data"`
+}
+
+func (a callArgs) data() []byte {
+	if len(a.Input) > 0 {
+		return a.Input
+	}
+	return a.Data
+}
+
+// ethService implements the eth namespace methods used by the commands
+type ethService struct {
+	chain *Chain
+}
+
+func (s *ethService) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(s.chain.ChainID)
+}
+
+func (s *ethService) BlockNumber() hexutil.Uint64 {
+	s.chain.mu.Lock()
+	defer s.chain.mu.Unlock()
+	return hexutil.Uint64(s.chain.head)
+}
+
+func (s *ethService) GetBlockByNumber(number rpc.BlockNumber, full bool) *types.Header {
+	s.chain.mu.Lock()
+	defer s.chain.mu.Unlock()
+	head := s.chain.head
+	if number >= 0 && uint64(number) < head {
+		head = uint64(number)
+	}
+	return &types.Header{
+		Number:     new(big.Int).SetUint64(head),
+		Difficulty: new(big.Int),
+		BaseFee:    s.chain.BaseFee,
+		GasLimit:   30_000_000,
+		Time:       uint64(time.Now().Unix()),
+	}
+}
+
+func (s *ethService) GetBalance(account common.Address, block rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	if s.chain.BalanceErr != nil {
+		if err := s.chain.BalanceErr(account); err != nil {
+			return nil, err
+		}
+	}
+	return (*hexutil.Big)(s.chain.Balance(account)), nil
+}
+
+func (s *ethService) GetTransactionCount(account common.Address, block rpc.BlockNumberOrHash) hexutil.Uint64 {
+	s.chain.mu.Lock()
+	defer s.chain.mu.Unlock()
+	return hexutil.Uint64(s.chain.nonces[account])
+}
+
+func (s *ethService) GetCode(address common.Address, block rpc.BlockNumberOrHash) hexutil.Bytes {
+	s.chain.mu.Lock()
+	defer s.chain.mu.Unlock()
+	return s.chain.code[address]
+}
+
+func (s *ethService) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(new(big.Int).Add(s.chain.BaseFee, s.chain.Tip))
+}
+
+func (s *ethService) MaxPriorityFeePerGas() *hexutil.Big {
+	return (*hexutil.Big)(s.chain.Tip)
+}
+
+func (s *ethService) EstimateGas(args callArgs, block *rpc.BlockNumberOrHash, overrides *json.RawMessage) hexutil.Uint64 {
+	return GasUsed
+}
+
+func (s *ethService) Call(args callArgs, block *rpc.BlockNumberOrHash, overrides *json.RawMessage) (hexutil.Bytes, error) {
+	if args.To == nil {
+		return nil, errors.New("contract creation is not supported")
+	}
+	s.chain.mu.Lock()
+	handler, ok := s.chain.callHandlers[*args.To]
+	s.chain.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	var from common.Address
+	if args.From != nil {
+		from = *args.From
+	}
+	return handler(from, args.data())
+}
+
+func (s *ethService) SendRawTransaction(input hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	if err := s.chain.send(tx); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
+func (s *ethService) GetTransactionReceipt(ctx context.Context, txHash common.Hash) *types.Receipt {
+	s.chain.mu.Lock()
+	defer s.chain.mu.Unlock()
+	return s.chain.receipts[txHash]
+}
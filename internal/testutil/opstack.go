@@ -0,0 +1,105 @@
+package testutil
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-chain-ops/crossdomain"
+	"github.com/ethereum-optimism/optimism/op-e2e/bindings"
+	"github.com/ethereum-optimism/optimism/op-node/rollup/derive"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Addresses of the L1 contracts of an OPStack
+var (
+	OptimismPortalAddress         = common.HexToAddress("0x00000000000000000000000000000000000a0001")
+	L1StandardBridgeAddress       = common.HexToAddress("0x00000000000000000000000000000000000a0002")
+	L1CrossDomainMessengerAddress = common.HexToAddress("0x00000000000000000000000000000000000a0003")
+)
+
+// OPStack is a fake L1 and L2 pair whose L1StandardBridge deposits ETH: an ETH deposit sent to the bridge on L1 emits
+// the TransactionDeposited event of the portal and mines the derived deposit transaction on L2, crediting the recipient
+type OPStack struct {
+	L1 *Chain
+	L2 *Chain
+}
+
+// NewOPStack starts a fake L1 and L2 pair, which are stopped when the test finishes
+func NewOPStack(t testing.TB) *OPStack {
+	t.Helper()
+	stack := &OPStack{
+		L1: NewChain(t, big.NewInt(900)),
+		L2: NewChain(t, big.NewInt(901)),
+	}
+	stack.L1.SetCode(OptimismPortalAddress, []byte{0x00})
+	stack.L1.HandleTransactions(L1StandardBridgeAddress, stack.depositETH)
+	stack.L2.SetCode(predeploys.L2StandardBridgeAddr, []byte{0x00})
+	stack.L2.SetCode(predeploys.L2CrossDomainMessengerAddr, []byte{0x00})
+	return stack
+}
+
+// depositETH executes L1StandardBridge.depositETH and depositETHTo, sending the deposit through the messengers like
+// the bridge does
+func (s *OPStack) depositETH(from common.Address, tx *types.Transaction, receipt *types.Receipt) error {
+	bridgeABI, err := bindings.L1StandardBridgeMetaData.GetAbi()
+	if err != nil {
+		return err
+	}
+	if len(tx.Data()) < 4 {
+		return fmt.Errorf("missing method selector")
+	}
+	method, err := bridgeABI.MethodById(tx.Data()[:4])
+	if err != nil {
+		return err
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		return err
+	}
+
+	recipient := from
+	var minGasLimit uint32
+	switch method.RawName {
+	case "depositETH":
+		minGasLimit = args[0].(uint32)
+	case "depositETHTo":
+		recipient = args[0].(common.Address)
+		minGasLimit = args[1].(uint32)
+	default:
+		return fmt.Errorf("unsupported L1StandardBridge method %s", method.RawName)
+	}
+
+	l2Messenger := predeploys.L2CrossDomainMessengerAddr
+	deposit := &types.DepositTx{
+		From:  crossdomain.ApplyL1ToL2Alias(L1CrossDomainMessengerAddress),
+		To:    &l2Messenger,
+		Mint:  tx.Value(),
+		Value: tx.Value(),
+		Gas:   uint64(minGasLimit) + 200_000,
+		Data:  tx.Data(),
+	}
+	depositLog, err := derive.MarshalDepositLogEvent(OptimismPortalAddress, deposit)
+	if err != nil {
+		return err
+	}
+	s.L1.AppendLog(receipt, depositLog)
+
+	// The source hash of the L2 transaction depends on the position of the log, which is only known once appended
+	derived, err := derive.UnmarshalDepositLogEvent(depositLog)
+	if err != nil {
+		return err
+	}
+	s.L2.AddReceipt(&types.Receipt{
+		Type:              types.DepositTxType,
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: GasUsed,
+		GasUsed:           GasUsed,
+		EffectiveGasPrice: new(big.Int),
+		TxHash:            types.NewTx(derived).Hash(),
+	})
+	s.L2.SetBalance(recipient, new(big.Int).Add(s.L2.Balance(recipient), tx.Value()))
+	return nil
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Gauge is a metric written to a node_exporter textfile, with one sample per label set
+type Gauge struct {
+	Name    string
+	Help    string
+	Samples []GaugeSample
+}
+
+// GaugeSample is a single value of a gauge
+type GaugeSample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// WritePrometheusTextfile writes the gauges in the Prometheus text format to path, for the textfile collector of
+// node_exporter. The file is written to a temporary file in the same directory and renamed over path, so the collector
+// never reads a partially written file.
+func WritePrometheusTextfile(path string, gauges []Gauge) error {
+	var b strings.Builder
+	for _, gauge := range gauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n", gauge.Name, helpEscaper.Replace(gauge.Help))
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", gauge.Name)
+		for _, sample := range gauge.Samples {
+			fmt.Fprintf(&b, "%s%s %s\n", gauge.Name, formatLabels(sample.Labels), strconv.FormatFloat(sample.Value, 'g', -1, 64))
+		}
+	}
+
+	return writeFileAtomic(path, []byte(b.String()))
+}
+
+// writeFileAtomic writes data to a temporary file in the directory of path and renames it over path, so readers never
+// see a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file for %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not set permissions of %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return nil
+}
+
+// formatLabels formats labels sorted by name, so that the same label set is always written the same way
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
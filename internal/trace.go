@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// traceFailures is set with --trace to print the call tree of every failed transaction
+var traceFailures bool
+
+// SetTrace reads the --trace global flag used by every transaction sent afterwards
+func SetTrace(c *cli.Context) {
+	traceFailures = c.Bool("trace")
+}
+
+// CallFrame is a call of a transaction as returned by the callTracer of debug_traceTransaction, with the calls it made
+// nested under it. Unlike wait.TxTrace it keeps every level of the call tree.
+type CallFrame struct {
+	Type         string         `json:"type"`
+	From         common.Address `json:"from"`
+	To           common.Address `json:"to"`
+	Value        *hexutil.Big   `json:"value,omitempty"`
+	Gas          hexutil.Uint64 `json:"gas"`
+	GasUsed      hexutil.Uint64 `json:"gasUsed"`
+	Input        hexutil.Bytes  `json:"input"`
+	Output       hexutil.Bytes  `json:"output,omitempty"`
+	Error        string         `json:"error,omitempty"`
+	RevertReason string         `json:"revertReason,omitempty"`
+	Calls        []*CallFrame   `json:"calls,omitempty"`
+}
+
+// TraceTransaction fetches the call tree of the mined transaction txHash with the callTracer of debug_traceTransaction,
+// which fails on nodes that do not enable the debug namespace
+func TraceTransaction(ctx context.Context, client *ethclient.Client, txHash common.Hash) (*CallFrame, error) {
+	traceCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+
+	frame := new(CallFrame)
+	options := map[string]any{"tracer": "callTracer"}
+	if err := client.Client().CallContext(traceCtx, frame, "debug_traceTransaction", txHash, options); err != nil {
+		return nil, fmt.Errorf("could not call debug_traceTransaction: %w", err)
+	}
+	return frame, nil
+}
+
+// Reason returns why the call failed: the revert reason decoded by the tracer, the revert data decoded like
+// DecodeRevert, or the error of the tracer such as out of gas
+func (f *CallFrame) Reason() string {
+	if f.RevertReason != "" {
+		return f.RevertReason
+	}
+	if len(f.Output) > 0 {
+		return DecodeRevert(f.Output)
+	}
+	return f.Error
+}
+
+// RevertLocation returns the innermost failed call of the failed call tree f, following the last failed call at every
+// level since an earlier one may have been caught by its caller
+func (f *CallFrame) RevertLocation() (*CallFrame, int) {
+	frame, depth := f, 0
+	for {
+		var failed *CallFrame
+		for _, call := range frame.Calls {
+			if call.Error != "" {
+				failed = call
+			}
+		}
+		if failed == nil {
+			return frame, depth
+		}
+		frame, depth = failed, depth+1
+	}
+}
+
+// WriteCallTree writes the call tree of f to w, a line per call indented by its depth
+func WriteCallTree(w io.Writer, f *CallFrame) error {
+	return writeCallFrame(w, f, 0)
+}
+
+func writeCallFrame(w io.Writer, f *CallFrame, depth int) error {
+	line := fmt.Sprintf("%s%s %s -> %s %s", strings.Repeat("  ", depth), f.Type, f.From.Hex(), f.To.Hex(), callMethodName(f.Input))
+	if f.Value != nil && f.Value.ToInt().Sign() > 0 {
+		line += fmt.Sprintf(" value=%s", FormatWei((*big.Int)(f.Value)))
+	}
+	line += fmt.Sprintf(" gasUsed=%d", f.GasUsed)
+	if f.Error != "" {
+		line += fmt.Sprintf(" FAILED: %s", f.Reason())
+	}
+	if _, err := fmt.Fprintln(w, line); err != nil {
+		return err
+	}
+	for _, call := range f.Calls {
+		if err := writeCallFrame(w, call, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callMethodName names the method called with input by the methods of the known contracts, falling back to the
+// selector and to the receive function for calls without calldata
+func callMethodName(input []byte) string {
+	if len(input) == 0 {
+		return "receive()"
+	}
+	if len(input) < 4 {
+		return hexutil.Encode(input)
+	}
+	method, err := findKnownMethod(hexutil.Encode(input[:4]), func(method abi.Method) bool { return bytes.Equal(method.ID, input[:4]) })
+	if err != nil {
+		return hexutil.Encode(input[:4])
+	}
+	return method.Sig
+}
+
+// logCallTrace prints the call tree of the failed transaction txHash to stderr and logs where it reverted, with
+// --trace. Nodes without the debug namespace only have the failure logged.
+func logCallTrace(ctx context.Context, client *ethclient.Client, name string, txHash common.Hash) {
+	if !traceFailures {
+		return
+	}
+
+	frame, err := TraceTransaction(ctx, client, txHash)
+	if err != nil {
+		log.Warn("could not trace failed transaction, is the debug namespace enabled on the node?", "call", name, "tx", txHash.Hex(), "error", err)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "call tree of %s %s:\n", name, txHash.Hex())
+	if err := WriteCallTree(os.Stderr, frame); err != nil {
+		log.Warn("could not write call tree", "error", err)
+	}
+
+	location, depth := frame.RevertLocation()
+	log.Error("transaction reverted in call", "call", name, "tx", txHash.Hex(),
+		"contract", location.To,
+		"method", callMethodName(location.Input),
+		"depth", depth,
+		"reason", location.Reason(),
+	)
+}
@@ -0,0 +1,464 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/transactions"
+	"github.com/ethereum-optimism/optimism/op-e2e/e2eutils/wait"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// NewTransactor creates the transactor used to sign and send the transactions of a command. When the command was
+// given --nonce the first transaction uses it, warning when it does not follow the pending nonce of the sender.
+func NewTransactor(ctx context.Context, c *cli.Context, client *ethclient.Client, privateKey *ecdsa.PrivateKey, chainId *big.Int) (*bind.TransactOpts, error) {
+	opts, err := bind.NewKeyedTransactorWithChainID(privateKey, chainId)
+	if err != nil {
+		return nil, fmt.Errorf("could not setup transactor: %w", err)
+	}
+
+	if err := setNonceOverride(ctx, c, client, opts); err != nil {
+		return nil, err
+	}
+	if err := setTxTypeOverride(ctx, c, client, opts); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// setNonceOverride sets the nonce given with --nonce on opts, warning when it does not follow the pending nonce
+func setNonceOverride(ctx context.Context, c *cli.Context, client *ethclient.Client, opts *bind.TransactOpts) error {
+	if c.IsSet("nonce") {
+		nonce := c.Uint64("nonce")
+		pendingNonce, err := client.PendingNonceAt(ctx, opts.From)
+		if err != nil {
+			return fmt.Errorf("could not fetch pending nonce: %w", err)
+		}
+		if nonce > pendingNonce {
+			log.Warn("nonce leaves a gap after the pending nonce, the transaction will not be mined until the gap is filled", "nonce", nonce, "pendingNonce", pendingNonce)
+		} else if nonce < pendingNonce {
+			log.Warn("nonce is below the pending nonce and will replace a pending transaction or be rejected", "nonce", nonce, "pendingNonce", pendingNonce)
+		}
+		opts.Nonce = new(big.Int).SetUint64(nonce)
+	}
+	return nil
+}
+
+// SendTransaction pads the gas estimate of the transaction built by builder, sends it and
+// waits for a successful receipt. The name is used to identify the call in logs and errors.
+func SendTransaction(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, name string, builder transactions.TxBuilder) (*types.Receipt, error) {
+	_, receipt, err := sendTransaction(ctx, client, opts, name, 1.5, builder)
+	return receipt, err
+}
+
+func sendTransaction(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, name string, paddingFactor float64, builder transactions.TxBuilder) (*types.Transaction, *types.Receipt, error) {
+	tx, err := broadcastTransaction(ctx, client, opts, name, paddingFactor, builder)
+	if err != nil {
+		return nil, nil, err
+	}
+	return waitForTransaction(ctx, client, opts, name, tx)
+}
+
+// BroadcastTransaction sends the transaction of SendTransaction without waiting for it to be mined, so that its hash
+// can be recorded before the wait
+func BroadcastTransaction(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, name string, builder transactions.TxBuilder) (*types.Transaction, error) {
+	return broadcastTransaction(ctx, client, opts, name, 1.5, builder)
+}
+
+// WaitForTransaction waits for the transaction tx sent with BroadcastTransaction from the account of opts to be mined
+// successfully, resubmitting it with bumped fees while it stays pending
+func WaitForTransaction(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, name string, tx *types.Transaction) (*types.Receipt, error) {
+	_, receipt, err := waitForTransaction(ctx, client, opts, name, tx)
+	return receipt, err
+}
+
+func waitForTransaction(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, name string, tx *types.Transaction) (*types.Transaction, *types.Receipt, error) {
+	log.Info("sent transaction, waiting for confirmation", "call", name, "tx", tx.Hash().Hex())
+	tx, err := waitResubmitting(ctx, client, opts, name, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	receipt, err := checkReceipt(ctx, client, name, tx.Hash())
+	if err != nil {
+		return tx, nil, err
+	}
+	return tx, receipt, nil
+}
+
+// broadcastTransaction sends the transaction of builder with its gas estimate padded by paddingFactor, once the sender
+// holds at least --min-balance. Transactions of an impersonating transactor are only built by builder and then sent
+// unsigned.
+func broadcastTransaction(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, name string, paddingFactor float64, builder transactions.TxBuilder) (*types.Transaction, error) {
+	if err := checkSenderBalance(ctx, client, opts.From); err != nil {
+		return nil, fmt.Errorf("not sending %s: %w", name, err)
+	}
+
+	var tx *types.Transaction
+	var err error
+	if isImpersonating(opts) {
+		// PadGasEstimate sends the padded transaction itself, so it is built here and sent unsigned instead
+		tx, err = builder(opts)
+		if err == nil {
+			padded := *opts
+			padded.GasLimit = uint64(float64(tx.Gas()) * paddingFactor)
+			tx, err = builder(&padded)
+		}
+		if err == nil {
+			tx, err = sendUnsigned(ctx, client, opts.From, tx)
+		}
+	} else {
+		tx, err = transactions.PadGasEstimate(opts, paddingFactor, builder)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", name, err)
+	}
+
+	// An explicit nonce is advanced so that the next transaction of the same transactor follows this one
+	if opts.Nonce != nil {
+		opts.Nonce = new(big.Int).Add(opts.Nonce, big.NewInt(1))
+	}
+
+	return tx, nil
+}
+
+// SentTx is a value transfer sent and mined through SendValueTx
+type SentTx struct {
+	Tx                *types.Transaction
+	Receipt           *types.Receipt
+	GasUsed           uint64
+	EffectiveGasPrice *big.Int
+}
+
+// SendValueTx transfers value to the address to from the account of opts, calling it with data when it is not
+// empty, and waits for a successful receipt. A gasLimit of 0 uses the padded gas estimate like SendTransaction,
+// otherwise the transaction is sent with gasLimit.
+func SendValueTx(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, to common.Address, value *big.Int, data []byte, gasLimit uint64) (*SentTx, error) {
+	tx, err := BroadcastValueTx(ctx, client, opts, to, value, data, gasLimit)
+	if err != nil {
+		return nil, err
+	}
+	return WaitForValueTx(ctx, client, opts, tx)
+}
+
+// BroadcastValueTx sends the value transfer of SendValueTx without waiting for it to be mined. The nonce of opts is
+// only advanced once the transaction was sent, so a failed transfer leaves no nonce gap.
+func BroadcastValueTx(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, to common.Address, value *big.Int, data []byte, gasLimit uint64) (*types.Transaction, error) {
+	transferOpts := *opts
+	transferOpts.Value = value
+	paddingFactor := 1.5
+	if gasLimit != 0 {
+		// A preset gas limit skips the estimate, so it is sent as given
+		transferOpts.GasLimit = gasLimit
+		paddingFactor = 1
+	}
+
+	// A bound contract without an ABI sends a plain value transfer, or the raw calldata
+	transfer := bind.NewBoundContract(to, abi.ABI{}, client, client, client)
+	tx, err := broadcastTransaction(ctx, client, &transferOpts, valueTxName(data), paddingFactor, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return transfer.RawTransact(opts, data)
+	})
+	opts.Nonce = transferOpts.Nonce
+	return tx, err
+}
+
+// WaitForValueTx waits for the value transfer tx sent with BroadcastValueTx from the account of opts to be mined
+// successfully, resubmitting it with bumped fees while it stays pending
+func WaitForValueTx(ctx context.Context, client *ethclient.Client, opts *bind.TransactOpts, tx *types.Transaction) (*SentTx, error) {
+	name := valueTxName(tx.Data())
+	log.Info("sent transaction, waiting for confirmation", "call", name, "tx", tx.Hash().Hex())
+	tx, err := waitResubmitting(ctx, client, opts, name, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt, err := checkReceipt(ctx, client, name, tx.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return &SentTx{
+		Tx:                tx,
+		Receipt:           receipt,
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: receipt.EffectiveGasPrice,
+	}, nil
+}
+
+// valueTxName names a value transaction in logs and errors by whether it carries calldata
+func valueTxName(data []byte) string {
+	if len(data) > 0 {
+		return "call"
+	}
+	return "transfer"
+}
+
+// WaitForReceipt waits up to --wait-timeout for a successful receipt of the sent transaction txHash, logging the
+// trace and revert reason of a failed one
+func WaitForReceipt(ctx context.Context, client *ethclient.Client, name string, txHash common.Hash) (*types.Receipt, error) {
+	log.Info("sent transaction, waiting for confirmation", "call", name, "tx", txHash.Hex())
+
+	if _, err := waitForMined(ctx, client, func() []common.Hash { return []common.Hash{txHash} }, func() {}); err != nil {
+		return nil, fmt.Errorf("failed to get %s receipt: %w", name, err)
+	}
+	return checkReceipt(ctx, client, name, txHash)
+}
+
+// WaitForReceiptOK waits up to --wait-timeout, checking every --receipt-poll-interval, for the receipt of txHash and
+// fails unless it succeeded. Each check is a single request bounded by the --rpc-timeout of client. Like
+// wait.ForReceiptOK a failed receipt is returned with a *wait.ReceiptStatusError holding the call trace of the
+// transaction, or with the tracing error when the node cannot trace it.
+func WaitForReceiptOK(ctx context.Context, client *ethclient.Client, txHash common.Hash) (*types.Receipt, error) {
+	if _, err := waitForMined(ctx, client, func() []common.Hash { return []common.Hash{txHash} }, func() {}); err != nil {
+		return nil, err
+	}
+	return receiptOK(ctx, client, txHash)
+}
+
+// receiptOK fetches the receipt of the mined transaction txHash and traces it when it did not succeed
+func receiptOK(ctx context.Context, client *ethclient.Client, txHash common.Hash) (*types.Receipt, error) {
+	receipt, err := client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipt for tx %s: %w", txHash.Hex(), err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		trace, err := wait.DebugTraceTx(ctx, client, txHash)
+		if err != nil {
+			return receipt, fmt.Errorf("unexpected receipt status %d, error tracing tx: %w", receipt.Status, err)
+		}
+		return receipt, &wait.ReceiptStatusError{Status: receipt.Status, TxTrace: trace}
+	}
+	return receipt, nil
+}
+
+// checkReceipt fetches the receipt of the mined transaction txHash and fails unless it succeeded, logging the trace
+// and revert reason of a failed one, and its call tree with --trace
+func checkReceipt(ctx context.Context, client *ethclient.Client, name string, txHash common.Hash) (*types.Receipt, error) {
+	receipt, err := receiptOK(ctx, client, txHash)
+	if err != nil {
+		if receipt != nil {
+			logCallTrace(ctx, client, name, txHash)
+		}
+		if statusErr, ok := err.(*wait.ReceiptStatusError); ok {
+			log.Error("transaction trace", "call", name, "tx", txHash.Hex(), "trace", statusErr.TxTrace)
+			reason, reasonErr := RevertReason(ctx, client, txHash, receipt)
+			if reasonErr != nil {
+				log.Warn("could not replay failed transaction for its revert reason", "call", name, "tx", txHash.Hex(), "error", reasonErr)
+				return nil, fmt.Errorf("failure in %s execution: %w", name, err)
+			}
+			log.Error("transaction reverted", "call", name, "tx", txHash.Hex(), "reason", reason)
+			return nil, fmt.Errorf("failure in %s execution, reverted with %s: %w", name, reason, err)
+		}
+		return nil, fmt.Errorf("failed to get %s receipt: %w", name, err)
+	}
+
+	LogGasReport(name, NewGasReport(receipt))
+
+	return receipt, nil
+}
+
+// PrivateKeyStdin is the value of --private-key that reads the key from stdin, keeping it out of argv and the
+// environment
+const PrivateKeyStdin = "-"
+
+// LoadSigner parses the hex private key of --private-key, read from the first line of stdin when the flag is
+// PrivateKeyStdin. Surrounding whitespace and a 0x prefix are trimmed.
+func LoadSigner(c *cli.Context) (*ecdsa.PrivateKey, error) {
+	keyHex := c.String("private-key")
+	if keyHex == PrivateKeyStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("could not read private-key from stdin: %w", err)
+		}
+		keyHex = line
+	}
+	keyHex = strings.TrimPrefix(strings.TrimSpace(keyHex), "0x")
+	if keyHex == "" {
+		return nil, fmt.Errorf("private-key is empty")
+	}
+
+	privateKey, err := crypto.HexToECDSA(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private-key: %w", err)
+	}
+	return privateKey, nil
+}
+
+// SenderAccount returns the account a sending command acts for together with its private key. With --build-only
+// the key may be omitted in favour of --from, so that it never has to be present on the machine building the
+// transaction, and with --impersonate the account is sent from without its key; the returned key is nil in both cases.
+func SenderAccount(c *cli.Context) (common.Address, *ecdsa.PrivateKey, error) {
+	if c.IsSet("impersonate") {
+		if c.IsSet("private-key") {
+			return ZeroAddress, nil, fmt.Errorf("--impersonate cannot be combined with --private-key")
+		}
+		account, err := SafeParseAddress(c.String("impersonate"))
+		if err != nil {
+			return ZeroAddress, nil, fmt.Errorf("could not parse --impersonate address: %w", err)
+		}
+		return account, nil, nil
+	}
+
+	if c.IsSet("private-key") {
+		privateKey, err := LoadSigner(c)
+		if err != nil {
+			return ZeroAddress, nil, err
+		}
+		return crypto.PubkeyToAddress(privateKey.PublicKey), privateKey, nil
+	}
+
+	if !c.Bool("build-only") {
+		for _, flag := range c.Command.Flags {
+			if flag.Names()[0] == "impersonate" {
+				return ZeroAddress, nil, fmt.Errorf("--private-key is required unless --impersonate is set")
+			}
+		}
+		return ZeroAddress, nil, fmt.Errorf("--private-key is required unless --build-only is set")
+	}
+	if !c.IsSet("from") {
+		return ZeroAddress, nil, fmt.Errorf("--from or --private-key is required with --build-only")
+	}
+	account, err := SafeParseAddress(c.String("from"))
+	if err != nil {
+		return ZeroAddress, nil, fmt.Errorf("could not parse --from address: %w", err)
+	}
+	return account, nil, nil
+}
+
+// UnsignedTransaction is a transaction built with --build-only, encoded like a JSON-RPC transaction object so it can
+// be handed to an offline signer
+type UnsignedTransaction struct {
+	Type                 hexutil.Uint64  `json:"type"`
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to"`
+	Data                 hexutil.Bytes   `json:"data"`
+	Value                *hexutil.Big    `json:"value"`
+	ChainId              *hexutil.Big    `json:"chainId"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+}
+
+// NewUnsignedTransactor creates a transactor for --build-only that fills in the transactions built through it, gas
+// estimate and fees included, without signing or sending them
+func NewUnsignedTransactor(ctx context.Context, c *cli.Context, client *ethclient.Client, from common.Address) (*bind.TransactOpts, error) {
+	opts := &bind.TransactOpts{
+		From: from,
+		Signer: func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			return tx, nil
+		},
+		Context: ctx,
+		NoSend:  true,
+	}
+
+	if err := setNonceOverride(ctx, c, client, opts); err != nil {
+		return nil, err
+	}
+	if err := setTxTypeOverride(ctx, c, client, opts); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// BuildUnsignedTransaction builds the transaction of builder with an unsigned transactor and pads its gas estimate
+// like SendTransaction. The nonce of opts is advanced so that further transactions built with it follow this one.
+func BuildUnsignedTransaction(opts *bind.TransactOpts, chainId *big.Int, name string, builder transactions.TxBuilder) (*UnsignedTransaction, error) {
+	tx, err := builder(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s: %w", name, err)
+	}
+
+	opts.Nonce = new(big.Int).SetUint64(tx.Nonce() + 1)
+
+	unsigned := &UnsignedTransaction{
+		Type:    hexutil.Uint64(tx.Type()),
+		From:    opts.From,
+		To:      tx.To(),
+		Data:    tx.Data(),
+		Value:   (*hexutil.Big)(tx.Value()),
+		ChainId: (*hexutil.Big)(chainId),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Gas:     hexutil.Uint64(float64(tx.Gas()) * 1.5),
+	}
+	if tx.Type() == types.LegacyTxType {
+		unsigned.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		unsigned.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		unsigned.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	}
+
+	log.Info("built unsigned transaction", "call", name, "to", tx.To(), "nonce", tx.Nonce(), "gas", uint64(unsigned.Gas))
+
+	return unsigned, nil
+}
+
+// PrintJSON writes value as indented JSON to stdout
+func PrintJSON(value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// GasReport summarises the gas economics of a mined transaction
+type GasReport struct {
+	GasUsed           uint64   `json:"gasUsed"`
+	EffectiveGasPrice *big.Int `json:"effectiveGasPrice"`
+	L1Fee             *big.Int `json:"l1Fee,omitempty"`
+	Fee               *big.Int `json:"fee"`
+}
+
+// NewGasReport computes the total fee paid for the transaction of receipt, including the L1 data fee of L2 transactions
+func NewGasReport(receipt *types.Receipt) *GasReport {
+	report := &GasReport{
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: new(big.Int),
+		L1Fee:             receipt.L1Fee,
+	}
+	if receipt.EffectiveGasPrice != nil {
+		report.EffectiveGasPrice.Set(receipt.EffectiveGasPrice)
+	}
+
+	report.Fee = new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), report.EffectiveGasPrice)
+	if receipt.L1Fee != nil {
+		report.Fee.Add(report.Fee, receipt.L1Fee)
+	}
+
+	return report
+}
+
+// LogGasReport logs the gas economics of the transaction sent for the call identified by name
+func LogGasReport(name string, report *GasReport) {
+	fields := []any{
+		"call", name,
+		"gasUsed", report.GasUsed,
+		"effectiveGasPrice", FormatWei(report.EffectiveGasPrice),
+	}
+	if report.L1Fee != nil {
+		fields = append(fields, "l1Fee", FormatWei(report.L1Fee))
+	}
+	fields = append(fields, "fee", FormatWei(report.Fee))
+	log.Info("transaction gas", fields...)
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// TxTypeLegacy sends pre-EIP-1559 transactions priced with a gas price
+	TxTypeLegacy = "legacy"
+	// TxTypeDynamic sends EIP-1559 dynamic fee transactions priced with a fee cap and a tip
+	TxTypeDynamic = "dynamic"
+)
+
+// setTxTypeOverride forces the transaction type given with --tx-type on opts, for nodes that only accept one of them.
+// Without it bind picks dynamic fee transactions once the chain has a base fee. The fees are taken from --gas-price,
+// --max-fee-per-gas and --max-priority-fee-per-gas, or suggested by the node when not given, and a fee flag without
+// --tx-type selects the type it applies to.
+func setTxTypeOverride(ctx context.Context, c *cli.Context, client *ethclient.Client, opts *bind.TransactOpts) error {
+	gasPrice, err := parseFeeFlag(c, "gas-price")
+	if err != nil {
+		return err
+	}
+	maxFee, err := parseFeeFlag(c, "max-fee-per-gas")
+	if err != nil {
+		return err
+	}
+	maxPriorityFee, err := parseFeeFlag(c, "max-priority-fee-per-gas")
+	if err != nil {
+		return err
+	}
+	if gasPrice != nil && (maxFee != nil || maxPriorityFee != nil) {
+		return fmt.Errorf("gas-price cannot be combined with max-fee-per-gas or max-priority-fee-per-gas")
+	}
+
+	txType := c.String("tx-type")
+	switch {
+	case txType == "" && gasPrice != nil:
+		txType = TxTypeLegacy
+	case txType == "" && (maxFee != nil || maxPriorityFee != nil):
+		txType = TxTypeDynamic
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+	defer cancel()
+
+	switch txType {
+	case "":
+		return nil
+	case TxTypeLegacy:
+		if maxFee != nil || maxPriorityFee != nil {
+			return fmt.Errorf("max-fee-per-gas and max-priority-fee-per-gas only apply to --tx-type %s", TxTypeDynamic)
+		}
+		if gasPrice == nil {
+			gasPrice, err = client.SuggestGasPrice(readCtx)
+			if err != nil {
+				return fmt.Errorf("could not fetch gas price: %w", err)
+			}
+		}
+		opts.GasPrice = gasPrice
+	case TxTypeDynamic:
+		if gasPrice != nil {
+			return fmt.Errorf("gas-price only applies to --tx-type %s", TxTypeLegacy)
+		}
+		if maxPriorityFee == nil {
+			maxPriorityFee, err = client.SuggestGasTipCap(readCtx)
+			if err != nil {
+				return fmt.Errorf("could not fetch gas tip cap: %w", err)
+			}
+		}
+		if maxFee == nil {
+			header, err := client.HeaderByNumber(readCtx, nil)
+			if err != nil {
+				return fmt.Errorf("could not fetch latest header: %w", err)
+			}
+			if header.BaseFee == nil {
+				return fmt.Errorf("chain has no base fee, set max-fee-per-gas to send dynamic fee transactions")
+			}
+			// The same fee cap bind sets, leaving room for the base fee to double
+			maxFee = new(big.Int).Add(new(big.Int).Mul(header.BaseFee, big.NewInt(2)), maxPriorityFee)
+		}
+		if maxFee.Cmp(maxPriorityFee) < 0 {
+			return fmt.Errorf("max-fee-per-gas %s is below max-priority-fee-per-gas %s", maxFee, maxPriorityFee)
+		}
+		opts.GasFeeCap = maxFee
+		opts.GasTipCap = maxPriorityFee
+	default:
+		return fmt.Errorf("tx-type must be %s or %s, got %q", TxTypeLegacy, TxTypeDynamic, txType)
+	}
+	return nil
+}
+
+// parseFeeFlag parses the fee in wei given with the flag name, nil when it is not set
+func parseFeeFlag(c *cli.Context, name string) (*big.Int, error) {
+	if !c.IsSet(name) {
+		return nil, nil
+	}
+	fee, err := ParseUint256BigInt(c.String(name))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", name, err)
+	}
+	return fee, nil
+}
@@ -3,22 +3,43 @@ package internal
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/big"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/holiman/uint256"
+	"github.com/urfave/cli/v2"
 )
 
 const ZeroAddressString string = "0x0000000000000000000000000000000000000000"
 
 var ZeroAddress common.Address = common.HexToAddress(ZeroAddressString)
 
+// RECEIVE_DEFAULT_GAS_LIMIT is the default minimum gas limit given to the standard bridges for executing the
+// receiving side of a deposit or withdrawal. It covers transfers to contracts with modest receive logic.
 const RECEIVE_DEFAULT_GAS_LIMIT uint32 = 200_000
 
+// CONTRACT_RECEIVE_DEFAULT_GAS_LIMIT is the default minimum gas limit used instead of RECEIVE_DEFAULT_GAS_LIMIT when the
+// receiving side is a contract, whose logic is unknown and may run out of gas with the smaller limit
+const CONTRACT_RECEIVE_DEFAULT_GAS_LIMIT uint32 = 500_000
+
+// HeaderCheckTimeout bounds each header query made by WaitForChainsStart
+const HeaderCheckTimeout = 5 * time.Second
+
+// ChainStartMaxPollInterval is the longest interval WaitForChainsStart backs off to between checks
+const ChainStartMaxPollInterval = 10 * time.Second
+
+// CallTimeout bounds each group of contract reads so that an unresponsive RPC cannot hang a command indefinitely
+const CallTimeout = 2 * time.Minute
+
 func ParseUint256BigInt(value string) (*big.Int, error) {
 	uint, err := uint256.FromDecimal(value)
 	if err != nil {
@@ -27,8 +48,35 @@ func ParseUint256BigInt(value string) (*big.Int, error) {
 	return uint.ToBig(), nil
 }
 
+// SafeParseUint32 converts a flag value to uint32, failing if it does not fit
+func SafeParseUint32(name string, value uint64) (uint32, error) {
+	if value > math.MaxUint32 {
+		return 0, fmt.Errorf("%s %d does not fit in uint32", name, value)
+	}
+	return uint32(value), nil
+}
+
+// strictAddressChecksum turns EIP-55 checksum mismatches of SafeParseAddress from warnings into errors
+var strictAddressChecksum bool
+
+// SetStrictAddressChecksum reads the --strict-address-checksum global flag used by every address parsed afterwards
+func SetStrictAddressChecksum(c *cli.Context) {
+	strictAddressChecksum = c.Bool("strict-address-checksum")
+}
+
+// SafeParseAddress parses a hex address, rejecting the zero address. Mixed case input is checked against its EIP-55
+// checksum to catch transcription typos, a mismatch is logged or with --strict-address-checksum returned as an error.
+// All lowercase and all uppercase input carries no checksum and is accepted as is.
 func SafeParseAddress(addressHex string) (common.Address, error) {
-	addressHex = strings.ToLower(strings.TrimSpace(addressHex))
+	addressHex = strings.TrimSpace(addressHex)
+	if err := checkAddressChecksum(addressHex); err != nil {
+		if strictAddressChecksum {
+			return common.Address{}, err
+		}
+		log.Warn("address does not match its checksum, check it for typos", "address", addressHex, "error", err)
+	}
+
+	addressHex = strings.ToLower(addressHex)
 	if !common.IsHexAddress(addressHex) {
 		return common.Address{}, fmt.Errorf("invalid Ethereum address: %s", addressHex)
 	}
@@ -41,63 +89,202 @@ func SafeParseAddress(addressHex string) (common.Address, error) {
 	return address, nil
 }
 
-func WaitForChainsStart(ctx context.Context, clients []*ethclient.Client) error {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+// checkAddressChecksum fails when addressHex is a mixed case address that differs from its EIP-55 checksummed form
+func checkAddressChecksum(addressHex string) error {
+	if !common.IsHexAddress(addressHex) {
+		return nil
+	}
+	body := addressHex
+	if len(body) == 2+2*common.AddressLength {
+		body = body[2:]
+	}
+	if body == strings.ToLower(body) || body == strings.ToUpper(body) {
+		return nil
+	}
+
+	checksummed := common.HexToAddress(body).Hex()
+	if body != checksummed[2:] {
+		return fmt.Errorf("invalid EIP-55 checksum for address %s, expected %s", addressHex, checksummed)
+	}
+	return nil
+}
+
+// SafeParseHash parses a 0x prefixed 32 byte hex hash, unlike common.HexToHash it rejects malformed input
+func SafeParseHash(hashHex string) (common.Hash, error) {
+	hashHex = strings.TrimSpace(hashHex)
+	if len(hashHex) != 2+2*common.HashLength || !strings.HasPrefix(hashHex, "0x") {
+		return common.Hash{}, fmt.Errorf("invalid hash %q, expected 0x followed by 64 hex characters", hashHex)
+	}
 
+	hash, err := hexutil.Decode(hashHex)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid hash %q: %w", hashHex, err)
+	}
+
+	return common.BytesToHash(hash), nil
+}
+
+// WaitForChainsStart waits until every client reports a block above genesis, checking the ones not ready yet every
+// pollInterval and backing off up to ChainStartMaxPollInterval
+func WaitForChainsStart(ctx context.Context, clients []*ethclient.Client, pollInterval time.Duration) error {
+	// readyClients is written by the checks of a poll while they run concurrently, so it is only accessed under mu
 	readyClients := make(map[*ethclient.Client]bool)
+	var mu sync.Mutex
 
-	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("timed out waiting for all clients to report block production")
+	err := PollUntil(ctx, pollInterval, ChainStartMaxPollInterval, func() (bool, error) {
+		// Skip clients that already reported block production
+		mu.Lock()
+		var pending []*ethclient.Client
+		for _, client := range clients {
+			if !readyClients[client] {
+				pending = append(pending, client)
+			}
+		}
+		mu.Unlock()
 
-		case <-ticker.C:
-			for _, client := range clients {
-				// Skip clients that already reported block production
-				if readyClients[client] {
-					continue
-				}
+		// Clients are checked concurrently so that a hanging client does not delay detecting the others
+		var wg sync.WaitGroup
+		for _, client := range pending {
+			wg.Add(1)
+			go func(client *ethclient.Client) {
+				defer wg.Done()
 
-				header, err := client.HeaderByNumber(ctx, nil)
+				headerCtx, cancel := context.WithTimeout(ctx, HeaderCheckTimeout)
+				defer cancel()
+
+				header, err := client.HeaderByNumber(headerCtx, nil)
 				if err != nil {
 					log.Error("received error fetching header", "error", err)
-					continue
+					return
 				}
 
 				if header.Number.Uint64() > 0 {
+					mu.Lock()
 					readyClients[client] = true
+					mu.Unlock()
 				}
-			}
+			}(client)
+		}
+		wg.Wait()
 
-			// If all clients have reported block production, exit
-			if len(readyClients) == len(clients) {
-				return nil
-			}
+		// If all clients have reported block production, exit
+		mu.Lock()
+		defer mu.Unlock()
+		return len(readyClients) == len(clients), nil
+	})
+	if err != nil {
+		return fmt.Errorf("timed out waiting for all clients to report block production")
+	}
+	return nil
+}
+
+// ParseRPCHeaders parses headers given in the "Key: Value" format into an http.Header
+func ParseRPCHeaders(headers []string) (http.Header, error) {
+	parsed := make(http.Header)
+	for _, header := range headers {
+		key, value, found := strings.Cut(header, ":")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid rpc header %q, expected \"Key: Value\"", header)
 		}
+		parsed.Add(key, strings.TrimSpace(value))
 	}
+	return parsed, nil
 }
 
-func ConnectClient(ctx context.Context, rpcUrl string) (*ethclient.Client, *big.Int, error) {
-	client, err := ethclient.Dial(rpcUrl)
+// ConnectClient dials rpcUrl with the --rpc-header and --rpc-timeout global flags and waits for the chain to produce
+// blocks. rpcUrl may be a comma separated list of http(s) urls, which are tried in order and failed over between for
+// the rest of the run. The chain id is read from the client unless it is overridden with the global chainIdFlag, which together
+// with --skip-chain-start-wait lets commands run against a stalled chain. Production chains have to be confirmed, both
+// the override and the chain id the client reports, see ConfirmProductionChain.
+func ConnectClient(ctx context.Context, c *cli.Context, rpcUrl string, chainIdFlag string) (*ethclient.Client, *big.Int, error) {
+	rpcHeaders, err := ParseRPCHeaders(c.StringSlice("rpc-header"))
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not dial rpc url at %s: %w", rpcUrl, err)
+		return nil, nil, err
 	}
 
-	log.Info("Successfully dialed client", "url", rpcUrl)
+	urls := SplitRPCUrls(rpcUrl)
+	if len(urls) == 0 {
+		return nil, nil, fmt.Errorf("no rpc url given")
+	}
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
-	defer cancel()
-	if err := WaitForChainsStart(timeoutCtx, []*ethclient.Client{client}); err != nil {
-		return nil, nil, fmt.Errorf("client has not started: %w", err)
+	// The http client timeout bounds every individual request, so a single hung call cannot wedge a command
+	httpClient := &http.Client{Timeout: c.Duration("rpc-timeout")}
+	if len(urls) > 1 {
+		// With several urls the timeout is applied to each attempt instead, so that a hung endpoint is failed over
+		transport, err := newFailoverTransport(http.DefaultTransport, urls, c.Duration("rpc-timeout"))
+		if err != nil {
+			return nil, nil, err
+		}
+		httpClient = &http.Client{Transport: transport}
 	}
 
-	chainId, err := client.ChainID(ctx)
+	rpcClient, err := rpc.DialOptions(ctx, urls[0], rpc.WithHeaders(rpcHeaders), rpc.WithHTTPClient(httpClient))
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not fetch l1 network id: %w", err)
+		return nil, nil, fmt.Errorf("could not dial rpc url at %s: %w", urls[0], err)
 	}
+	client := ethclient.NewClient(rpcClient)
 
-	log.Info("Successfully connected to chain", "chainId", chainId)
+	log.Info("Successfully dialed client", "url", rpcUrl)
+
+	if c.Bool("skip-chain-start-wait") {
+		log.Info("skipping the wait for block production", "url", rpcUrl)
+	} else {
+		if c.Duration("startup-poll-interval") <= 0 {
+			return nil, nil, fmt.Errorf("startup-poll-interval must be greater than 0")
+		}
+		timeoutCtx, cancel := context.WithTimeout(ctx, c.Duration("startup-timeout"))
+		defer cancel()
+		if err := WaitForChainsStart(timeoutCtx, []*ethclient.Client{client}, c.Duration("startup-poll-interval")); err != nil {
+			return nil, nil, fmt.Errorf("client has not started: %w", err)
+		}
+	}
+
+	var chainId *big.Int
+	if c.IsSet(chainIdFlag) {
+		chainId = new(big.Int).SetUint64(c.Uint64(chainIdFlag))
+		log.Info("Using chain id override", "flag", chainIdFlag, "chainId", chainId)
+
+		// The override must not hide a production chain behind the rpc, so the chain id the rpc reports is confirmed
+		// as well whenever it can be read
+		readCtx, cancel := context.WithTimeout(ctx, CallTimeout)
+		defer cancel()
+		reported, err := client.ChainID(readCtx)
+		if err != nil {
+			log.Warn("could not fetch the chain id reported by the rpc, only confirming the override", "url", rpcUrl, "error", err)
+		} else if reported.Cmp(chainId) != 0 {
+			log.Warn("chain id override differs from the chain id reported by the rpc", "flag", chainIdFlag, "chainId", chainId, "reported", reported)
+			if err := ConfirmProductionChain(c, reported); err != nil {
+				return nil, nil, err
+			}
+		}
+	} else {
+		chainId, err = client.ChainID(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not fetch l1 network id: %w", err)
+		}
+		log.Info("Successfully connected to chain", "chainId", chainId)
+	}
+
+	if err := ConfirmProductionChain(c, chainId); err != nil {
+		return nil, nil, err
+	}
 
 	return client, chainId, nil
 }
+
+// ValidateChainIds aborts when a connected chain does not report the chain id given with --expected-l1-chain-id or
+// --expected-l2-chain-id, and warns when L1 and L2 report the same chain id, which almost always means the RPC urls
+// were swapped. A nil chain id is skipped for commands that only connect to one of the chains.
+func ValidateChainIds(c *cli.Context, l1ChainId *big.Int, l2ChainId *big.Int) error {
+	if l1ChainId != nil && c.IsSet("expected-l1-chain-id") && l1ChainId.Uint64() != c.Uint64("expected-l1-chain-id") {
+		return fmt.Errorf("l1-rpc-url reports chain id %s but %d was expected, check that the L1 and L2 urls are not swapped", l1ChainId, c.Uint64("expected-l1-chain-id"))
+	}
+	if l2ChainId != nil && c.IsSet("expected-l2-chain-id") && l2ChainId.Uint64() != c.Uint64("expected-l2-chain-id") {
+		return fmt.Errorf("l2-rpc-url reports chain id %s but %d was expected, check that the L1 and L2 urls are not swapped", l2ChainId, c.Uint64("expected-l2-chain-id"))
+	}
+	if l1ChainId != nil && l2ChainId != nil && l1ChainId.Cmp(l2ChainId) == 0 {
+		log.Warn("L1 and L2 report the same chain id, the rpc urls are likely misconfigured", "chainId", l1ChainId)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// chainStartService serves eth_getBlockByNumber for a chain that reports its genesis block for the first startAfter
+// requests and a block above it afterwards, answering every request after delay
+type chainStartService struct {
+	startAfter int
+	delay      time.Duration
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *chainStartService) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, full bool) (*types.Header, error) {
+	s.mu.Lock()
+	s.calls++
+	calls := s.calls
+	s.mu.Unlock()
+
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	head := common.Big0
+	if calls > s.startAfter {
+		head = common.Big1
+	}
+	return &types.Header{Number: new(big.Int).Set(head), Difficulty: common.Big0}, nil
+}
+
+func (s *chainStartService) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// newChainStartClient returns a client connected in process to service
+func newChainStartClient(t *testing.T, service *chainStartService) *ethclient.Client {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatalf("could not register eth service: %v", err)
+	}
+	t.Cleanup(server.Stop)
+	client := ethclient.NewClient(rpc.DialInProc(server))
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestWaitForChainsStartFastAndSlowClient(t *testing.T) {
+	fast := &chainStartService{}
+	slow := &chainStartService{startAfter: 2, delay: 50 * time.Millisecond}
+	clients := []*ethclient.Client{newChainStartClient(t, fast), newChainStartClient(t, slow)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := WaitForChainsStart(ctx, clients, 10*time.Millisecond); err != nil {
+		t.Fatalf("WaitForChainsStart: %v", err)
+	}
+
+	// The fast client is ready on the first check and skipped afterwards, the slow one is checked until it starts
+	if calls := fast.callCount(); calls != 1 {
+		t.Errorf("fast client was checked %d times, want 1", calls)
+	}
+	if calls := slow.callCount(); calls != 3 {
+		t.Errorf("slow client was checked %d times, want 3", calls)
+	}
+}
+
+func TestWaitForChainsStartTimeout(t *testing.T) {
+	fast := &chainStartService{}
+	stalled := &chainStartService{startAfter: 1 << 30}
+	clients := []*ethclient.Client{newChainStartClient(t, fast), newChainStartClient(t, stalled)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := WaitForChainsStart(ctx, clients, 10*time.Millisecond); err == nil {
+		t.Fatal("WaitForChainsStart returned without the stalled client producing blocks")
+	}
+}
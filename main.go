@@ -1,23 +1,219 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"runtime/debug"
+	"time"
 
 	"github.com/Golem-Base/op-probe/cmd"
+	"github.com/Golem-Base/op-probe/internal"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/urfave/cli/v2"
 )
 
+// Build information, injected at build time with
+// -ldflags "-X main.Version=... -X main.GitCommit=... -X main.BuildDate=..."
+var (
+	Version   = ""
+	GitCommit = ""
+	BuildDate = ""
+)
+
+// buildInfo fills in the version, commit and build date from the embedded module information when they were not injected
+func buildInfo() (string, string, string) {
+	version, commit, date := Version, GitCommit, BuildDate
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version == "" {
+			version = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			if commit == "" && setting.Key == "vcs.revision" {
+				commit = setting.Value
+			}
+			if date == "" && setting.Key == "vcs.time" {
+				date = setting.Value
+			}
+		}
+	}
+	if version == "" {
+		version = "(devel)"
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+	if date == "" {
+		date = "unknown"
+	}
+	return version, commit, date
+}
+
 func main() {
-	log.SetDefault(log.NewLogger(log.JSONHandlerWithLevel(os.Stdout, log.LevelInfo)))
+	// Logs go to stderr so that stdout only carries the results of a command, --logs-to-stdout moves them back
+	log.SetDefault(log.NewLogger(log.JSONHandlerWithLevel(os.Stderr, log.LevelInfo)))
+	version, commit, date := buildInfo()
 	app := &cli.App{
-		Name:  "probe",
-		Usage: "Helper utilities for devnet",
+		Name:    "probe",
+		Usage:   "Helper utilities for devnet",
+		Version: fmt.Sprintf("%s (commit %s, built %s)", version, commit, date),
+		Metadata: map[string]interface{}{
+			"version": version,
+			"commit":  commit,
+			"date":    date,
+		},
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "ci",
+				Usage: "Use waits suited to containerized devnets on slow CI runners: rpc-timeout 1m, startup-timeout 10m, startup-poll-interval 5s, resubmission-timeout 3m, wait-timeout 10m and receipt-poll-interval 4s, unless set explicitly",
+			},
+			&cli.StringSliceFlag{
+				Name:  "rpc-header",
+				Usage: "Header to send with every L1 and L2 RPC request in the \"Key: Value\" format, may be repeated",
+			},
+			&cli.DurationFlag{
+				Name:  "rpc-timeout",
+				Usage: "Timeout of every individual RPC request made over http(s), 0 disables it",
+				Value: 30 * time.Second,
+			},
+			&cli.BoolFlag{
+				Name:  "skip-chain-start-wait",
+				Usage: "Do not wait for the chains to produce blocks before running the command, to inspect a stalled chain",
+			},
+			&cli.DurationFlag{
+				Name:  "startup-timeout",
+				Usage: "Maximum time to wait for each chain to produce blocks before running the command",
+				Value: 2 * time.Minute,
+			},
+			&cli.DurationFlag{
+				Name:  "startup-poll-interval",
+				Usage: "Interval between the first checks for block production, backing off up to 10s",
+				Value: time.Second,
+			},
+			&cli.Uint64Flag{
+				Name:  "chain-id",
+				Usage: "Chain id to use for the rpc-url of single chain commands instead of fetching it",
+			},
+			&cli.Uint64Flag{
+				Name:  "l1-chain-id",
+				Usage: "Chain id to use for the L1 rpc instead of fetching it",
+			},
+			&cli.Uint64Flag{
+				Name:  "l2-chain-id",
+				Usage: "Chain id to use for the L2 rpc instead of fetching it",
+			},
+			&cli.Uint64Flag{
+				Name:  "expected-l1-chain-id",
+				Usage: "Abort when the L1 rpc reports a different chain id",
+			},
+			&cli.Uint64Flag{
+				Name:  "expected-l2-chain-id",
+				Usage: "Abort when the L2 rpc reports a different chain id",
+			},
+			&cli.StringFlag{
+				Name:  "portal-version",
+				Usage: "OptimismPortal deployed on L1, one of auto, legacy or fault-proofs. auto detects it by probing the portal",
+				Value: "auto",
+			},
+			&cli.BoolFlag{
+				Name:  "autodiscover",
+				Usage: "Read the L1 contract addresses that are not passed explicitly from the SystemConfig",
+			},
+			&cli.StringFlag{
+				Name:  "system-config-address",
+				Usage: "Contract address for the SystemConfig (* or proxy) used by --autodiscover (default: read from the OptimismPortal)",
+			},
+			&cli.StringFlag{
+				Name:  "l1-beacon-url",
+				Usage: "Url of the L1 beacon node, for reading blob data on chains posting batches as blobs. Not supported yet, no command reads blob data",
+			},
+			&cli.DurationFlag{
+				Name:  "resubmission-timeout",
+				Usage: "Time a sent transaction may stay pending before it is resubmitted under the same nonce with bumped fees, 0 disables resubmission",
+				Value: 48 * time.Second,
+			},
+			&cli.Uint64Flag{
+				Name:  "fee-bump-percent",
+				Usage: "Percentage the tip and fee cap of a resubmitted transaction are raised by, at least 10",
+				Value: 10,
+			},
+			&cli.Uint64Flag{
+				Name:  "fee-limit-multiplier",
+				Usage: "Maximum fee cap of resubmissions as a multiple of the fee cap first sent",
+				Value: 5,
+			},
+			&cli.DurationFlag{
+				Name:  "wait-timeout",
+				Usage: "Maximum time to wait for a sent transaction to be mined, resubmissions included, 0 waits until it is mined",
+			},
+			&cli.DurationFlag{
+				Name:  "receipt-poll-interval",
+				Usage: "Interval between receipt checks while waiting for a sent transaction to be mined",
+				Value: time.Second,
+			},
+			&cli.StringFlag{
+				Name:    "min-balance",
+				Aliases: []string{"sender-balance-alert"},
+				Usage:   "Abort before sending a transaction from a sender whose balance in wei is below this threshold, so loops such as fund and finalize stop once funds run out",
+			},
+			&cli.BoolFlag{
+				Name:  "trace",
+				Usage: "Print the call tree and revert location of a failed transaction, traced with debug_traceTransaction on nodes enabling the debug namespace",
+			},
+			&cli.BoolFlag{
+				Name:  "strict-address-checksum",
+				Usage: "Reject mixed case addresses whose EIP-55 checksum does not match instead of only warning about them",
+			},
+			&cli.BoolFlag{
+				Name:  "yes",
+				Usage: "Run against known production chains such as Ethereum Mainnet or OP Mainnet without asking for confirmation",
+			},
+			&cli.BoolFlag{
+				Name:  "quiet",
+				Usage: "Only log warnings and errors, and print the primary result of the command (such as the transaction hash) to stdout",
+			},
+			&cli.BoolFlag{
+				Name:  "logs-to-stdout",
+				Usage: "Write the JSON logs to stdout along with the results, instead of to stderr",
+			},
+			&cli.StringFlag{
+				Name:  "output-file",
+				Usage: "Path to write a JSON document describing the command run (inputs, transactions, results and errors)",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			if err := internal.ApplyCIProfile(c); err != nil {
+				return err
+			}
+			logOutput, logLevel := os.Stderr, log.LevelInfo
+			if c.Bool("logs-to-stdout") {
+				logOutput = os.Stdout
+			}
+			if c.Bool("quiet") {
+				logLevel = log.LevelWarn
+			}
+			log.SetDefault(log.NewLogger(log.JSONHandlerWithLevel(logOutput, logLevel)))
+			internal.SetStrictAddressChecksum(c)
+			internal.SetTrace(c)
+			if err := internal.CheckL1BeaconURL(c); err != nil {
+				return err
+			}
+			if err := internal.SetMinBalance(c); err != nil {
+				return err
+			}
+			return internal.SetResubmitConfig(c)
+		},
 		Commands: []*cli.Command{
 			cmd.SendCommand,
+			cmd.FundCommand,
 			cmd.DepositCommand,
+			cmd.DepositTxCommand,
 			cmd.WithdrawCommand,
+			cmd.EstimateCommand,
+			cmd.SimulateWithdrawCommand,
+			cmd.CallCommand,
+			cmd.SubmitRawCommand,
+			cmd.VersionCommand,
 		},
 	}
 